@@ -3,31 +3,79 @@ package checkgrp
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
 
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/worker"
 	"go.uber.org/zap"
 )
 
-// Handlers manages the set of check endpoints.
+// Set of statuses reported for a check and for readiness as a whole.
+const (
+	StatusOK       = "ok"
+	StatusFailed   = "failed"
+	StatusDegraded = "degraded"
+	StatusUnknown  = "unknown"
+)
+
+// Check represents the outcome of a single readiness check. Readiness fails
+// when a critical check fails.
+type Check struct {
+	Name     string `json:"name"`
+	Critical bool   `json:"critical"`
+	Status   string `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// Handlers manages the set of check endpoints. The miner is nil when the
+// node doesn't mine.
 type Handlers struct {
-	Build string
-	Log   *zap.SugaredLogger
+	Build           string
+	Log             *zap.SugaredLogger
+	State           *state.State
+	Peers           *peer.PeerSet
+	Miner           *worker.Miner
+	ExpectPeers     bool
+	MaxBlocksBehind uint64
 }
 
-// Readiness checks if the database is ready and if not will return a 500 status.
-// Do not respond by just returning an error because further up in the call
-// stack it will interpret that as a non-trusted error.
+// Readiness runs the readiness checks and returns the outcome of each one.
+// It returns a 503 status when a critical check fails so the node doesn't
+// receive traffic. Do not respond by just returning an error because further
+// up in the call stack it will interpret that as a non-trusted error.
 func (h Handlers) Readiness(w http.ResponseWriter, r *http.Request) {
-	status := "ok"
-	statusCode := http.StatusOK
+	checks := []Check{
+		h.checkStorage(),
+		h.checkGenesis(),
+		h.checkSynced(),
+		h.checkPeers(),
+		h.checkMiner(),
+	}
 
-	// TODO: NOT SURE WHAT TO DO RIGHT NOW
+	status := StatusOK
+	statusCode := http.StatusOK
+	for _, check := range checks {
+		if check.Status != StatusFailed {
+			continue
+		}
+
+		if check.Critical {
+			status = StatusFailed
+			statusCode = http.StatusServiceUnavailable
+			break
+		}
+		status = StatusDegraded
+	}
 
 	data := struct {
-		Status string `json:"status"`
+		Status string  `json:"status"`
+		Checks []Check `json:"checks"`
 	}{
 		Status: status,
+		Checks: checks,
 	}
 
 	if err := response(w, statusCode, data); err != nil {
@@ -65,6 +113,124 @@ func (h Handlers) Liveness(w http.ResponseWriter, r *http.Request) {
 	h.Log.Infow("liveness", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
 }
 
+// =============================================================================
+
+// checkStorage validates the node can write to its database folder.
+func (h Handlers) checkStorage() Check {
+	check := Check{
+		Name:     "storage",
+		Critical: true,
+		Status:   StatusOK,
+	}
+
+	if err := h.State.CheckStorage(); err != nil {
+		check.Status = StatusFailed
+		check.Detail = err.Error()
+	}
+
+	return check
+}
+
+// checkGenesis validates the genesis of the chain was loaded.
+func (h Handlers) checkGenesis() Check {
+	check := Check{
+		Name:     "genesis",
+		Critical: true,
+		Status:   StatusOK,
+	}
+
+	gen := h.State.Genesis()
+	if gen.ChainID == 0 {
+		check.Status = StatusFailed
+		check.Detail = "genesis not loaded"
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("chain id %d", gen.ChainID)
+	return check
+}
+
+// checkSynced validates the node is not too many blocks behind the highest
+// block reported by its peers. The heights are reported by the peers
+// themselves and a single peer can claim any height, so this check is not
+// critical. It is unknown until a peer reported its height.
+func (h Handlers) checkSynced() Check {
+	check := Check{
+		Name:   "synced",
+		Status: StatusOK,
+	}
+
+	height := h.State.LatestBlockNumber()
+
+	peerHeight, ok := h.Peers.MaxHeight()
+	if !ok {
+		check.Status = StatusUnknown
+		check.Detail = fmt.Sprintf("height %d, no peer heights known", height)
+		return check
+	}
+
+	if peerHeight > height && peerHeight-height > h.MaxBlocksBehind {
+		check.Status = StatusFailed
+		check.Detail = fmt.Sprintf("height %d is %d blocks behind peers at %d, max %d", height, peerHeight-height, peerHeight, h.MaxBlocksBehind)
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("height %d, peers at %d", height, peerHeight)
+	return check
+}
+
+// checkPeers validates the node has at least one usable peer when it was
+// configured to connect to other nodes. A node without peers can still
+// serve its own state so this check is not critical.
+func (h Handlers) checkPeers() Check {
+	check := Check{
+		Name:   "peers",
+		Status: StatusOK,
+	}
+
+	if !h.ExpectPeers {
+		check.Detail = "no peers configured"
+		return check
+	}
+
+	peers := h.Peers.Copy("")
+	if len(peers) == 0 {
+		check.Status = StatusFailed
+		check.Detail = "no usable peers"
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("%d peers", len(peers))
+	return check
+}
+
+// checkMiner validates the miner is running when the node was configured
+// to mine. A mining node that stopped mining is not doing its job so this
+// check is critical.
+func (h Handlers) checkMiner() Check {
+	check := Check{
+		Name:     "miner",
+		Critical: true,
+		Status:   StatusOK,
+	}
+
+	if h.Miner == nil {
+		check.Detail = "mining disabled"
+		return check
+	}
+
+	if !h.Miner.Running() {
+		check.Status = StatusFailed
+		check.Detail = "miner not running"
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("hash rate %.0f/s", h.State.HashRate())
+	return check
+}
+
+// =============================================================================
+
 func response(w http.ResponseWriter, statusCode int, data any) error {
 
 	// Convert the response value to JSON.
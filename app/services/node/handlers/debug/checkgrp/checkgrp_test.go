@@ -0,0 +1,117 @@
+package checkgrp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers/debug/checkgrp"
+	"github.com/bruno-sartori/go-blockchain/app/services/node/nodetest"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/worker"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// Set of states of the miner of a node.
+const (
+	noMiner      = "no miner"
+	minerStopped = "stopped"
+	minerRunning = "running"
+)
+
+func Test_Readiness(t *testing.T) {
+	tt := []struct {
+		name       string
+		miner      string
+		peerHeight uint64
+		statusCode int
+		status     string
+		checks     map[string]string
+	}{
+		{"node without peer heights", noMiner, 0, http.StatusOK, checkgrp.StatusOK, map[string]string{"synced": checkgrp.StatusUnknown, "miner": checkgrp.StatusOK}},
+		{"node behind its peers", noMiner, 100, http.StatusOK, checkgrp.StatusDegraded, map[string]string{"synced": checkgrp.StatusFailed}},
+		{"node with a stopped miner", minerStopped, 0, http.StatusServiceUnavailable, checkgrp.StatusFailed, map[string]string{"miner": checkgrp.StatusFailed}},
+		{"node with a running miner", minerRunning, 0, http.StatusOK, checkgrp.StatusOK, map[string]string{"miner": checkgrp.StatusOK}},
+	}
+
+	t.Log("Given the need to report whether the node is ready.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					nw := nodetest.NewNetwork(t, 1, nodetest.Genesis)
+					node := nw.Nodes[0]
+
+					// A peer can claim any height it wants.
+					if tst.peerHeight > 0 {
+						pr := peer.New("0.0.0.0:9999")
+						node.Peers.Add(pr, "0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+						node.Peers.SetHeight(pr, tst.peerHeight)
+					}
+
+					var miner *worker.Miner
+					if tst.miner != noMiner {
+						miner = worker.NewMiner(worker.MinerConfig{State: node.State})
+					}
+					if tst.miner == minerRunning {
+						ctx, cancel := context.WithCancel(context.Background())
+						defer cancel()
+						go miner.Run(ctx, time.Hour)
+
+						for !miner.Running() {
+							time.Sleep(time.Millisecond)
+						}
+					}
+
+					cgh := checkgrp.Handlers{
+						Log:             zap.NewNop().Sugar(),
+						State:           node.State,
+						Peers:           node.Peers,
+						Miner:           miner,
+						MaxBlocksBehind: 2,
+					}
+
+					w := httptest.NewRecorder()
+					cgh.Readiness(w, httptest.NewRequest(http.MethodGet, "/debug/readiness", nil))
+
+					if w.Code != tst.statusCode {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected status code: got[%d] exp[%d]", failed, testID, w.Code, tst.statusCode)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected status code.", success, testID)
+
+					var resp struct {
+						Status string           `json:"status"`
+						Checks []checkgrp.Check `json:"checks"`
+					}
+					if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+						t.Fatalf("decoding response: %s", err)
+					}
+
+					if resp.Status != tst.status {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected status: got[%s] exp[%s]", failed, testID, resp.Status, tst.status)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected status.", success, testID)
+
+					for _, check := range resp.Checks {
+						if exp, exists := tst.checks[check.Name]; exists && check.Status != exp {
+							t.Fatalf("\t%s\tTest %d:\tShould get the expected %s check: got[%s] exp[%s]", failed, testID, check.Name, check.Status, exp)
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected checks.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package metricsgrp maintains the group of handlers for metrics scraping.
+package metricsgrp
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/bruno-sartori/go-blockchain/business/web/metrics"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/prometheus"
+	"go.uber.org/zap"
+)
+
+// Handlers manages the set of metrics endpoints.
+type Handlers struct {
+	State *state.State
+	Peers *peer.PeerSet
+	Log   *zap.SugaredLogger
+}
+
+// Prometheus returns the HTTP, runtime and chain metrics in the Prometheus
+// text format. Requests are not logged since they come from a scraper on
+// a fixed interval.
+func (h Handlers) Prometheus(w http.ResponseWriter, r *http.Request) {
+	var b bytes.Buffer
+	pw := prometheus.NewWriter(&b)
+
+	metrics.WritePrometheus(pw)
+	h.writeChain(pw)
+
+	if err := pw.Err(); err != nil {
+		h.Log.Errorw("metrics", "ERROR", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", prometheus.ContentType)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(b.Bytes()); err != nil {
+		h.Log.Errorw("metrics", "ERROR", err)
+	}
+}
+
+// writeChain writes the metrics describing the blockchain and the network.
+func (h Handlers) writeChain(pw *prometheus.Writer) {
+	var known, banned int
+	for _, status := range h.Peers.Statuses() {
+		switch {
+		case status.Banned:
+			banned++
+		default:
+			known++
+		}
+	}
+
+	gauges := []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"node_block_height", "Number of the latest block in the chain.", float64(h.State.LatestBlockNumber())},
+		{"node_last_block_timestamp_seconds", "Time the latest block in the chain was mined.", float64(h.State.LatestBlock().TimeStamp) / 1000},
+		{"node_hash_rate", "Hashes per second computed to mine the last block mined by this node.", h.State.HashRate()},
+		{"node_mempool_transactions", "Number of transactions in the mempool.", float64(h.State.QueryMempoolLength())},
+		{"node_peers", "Number of known peers that are not banned.", float64(known)},
+		{"node_peers_banned", "Number of peers currently banned.", float64(banned)},
+	}
+	for _, g := range gauges {
+		pw.Header(g.name, g.help, prometheus.TypeGauge)
+		pw.Sample(g.name, nil, g.value)
+	}
+}
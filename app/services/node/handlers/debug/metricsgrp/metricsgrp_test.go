@@ -0,0 +1,113 @@
+package metricsgrp_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers/debug/metricsgrp"
+	"github.com/bruno-sartori/go-blockchain/app/services/node/nodetest"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Prometheus(t *testing.T) {
+	tt := []struct {
+		name   string
+		mine   bool
+		height float64
+	}{
+		{"node that has not mined", false, 0},
+		{"node that mined a block", true, 1},
+	}
+
+	t.Log("Given the need to report the chain metrics.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					nw := nodetest.NewNetwork(t, 1, nodetest.Genesis)
+					node := nw.Nodes[0]
+
+					timestamp := float64(nodetest.Genesis.Date.UnixMilli()) / 1000
+					if tst.mine {
+						block := nw.Mine(context.Background(), 0)
+						timestamp = float64(block.Header.TimeStamp) / 1000
+					}
+
+					mgh := metricsgrp.Handlers{
+						State: node.State,
+						Peers: node.Peers,
+						Log:   zap.NewNop().Sugar(),
+					}
+
+					w := httptest.NewRecorder()
+					mgh.Prometheus(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+					if w.Code != http.StatusOK {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected status code: got[%d] exp[%d]", failed, testID, w.Code, http.StatusOK)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected status code.", success, testID)
+
+					samples := parseSamples(t, w.Body.String())
+
+					if got := samples["node_block_height"]; got != tst.height {
+						t.Fatalf("\t%s\tTest %d:\tShould report the block height: got[%v] exp[%v]", failed, testID, got, tst.height)
+					}
+					t.Logf("\t%s\tTest %d:\tShould report the block height.", success, testID)
+
+					if got := samples["node_last_block_timestamp_seconds"]; got != timestamp {
+						t.Fatalf("\t%s\tTest %d:\tShould report the last block time: got[%v] exp[%v]", failed, testID, got, timestamp)
+					}
+					t.Logf("\t%s\tTest %d:\tShould report the last block time.", success, testID)
+
+					if got := samples["node_hash_rate"]; (got > 0) != tst.mine {
+						t.Fatalf("\t%s\tTest %d:\tShould only report a hash rate after mining: got[%v]", failed, testID, got)
+					}
+					t.Logf("\t%s\tTest %d:\tShould only report a hash rate after mining.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+// =============================================================================
+
+// parseSamples returns the value of every sample without labels.
+func parseSamples(t *testing.T, body string) map[string]float64 {
+	t.Helper()
+
+	samples := make(map[string]float64)
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.Contains(line, "{") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			t.Fatalf("parsing sample %q: %s", line, err)
+		}
+		samples[fields[0]] = value
+	}
+
+	return samples
+}
@@ -0,0 +1,49 @@
+// Package peergrp maintains the group of handlers for peer inspection.
+package peergrp
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"go.uber.org/zap"
+)
+
+// Handlers manages the set of peer endpoints.
+type Handlers struct {
+	Peers *peer.PeerSet
+	Log   *zap.SugaredLogger
+}
+
+// Scores returns the current reputation of every known peer including
+// the ones that are banned.
+func (h Handlers) Scores(w http.ResponseWriter, r *http.Request) {
+	statusCode := http.StatusOK
+	if err := response(w, statusCode, h.Peers.Statuses()); err != nil {
+		h.Log.Errorw("peer scores", "ERROR", err)
+	}
+
+	h.Log.Infow("peer scores", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
+}
+
+func response(w http.ResponseWriter, statusCode int, data any) error {
+
+	// Convert the response value to JSON.
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	// Set the content type and headers once we know marshaling has succeeded.
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write the status code to the response.
+	w.WriteHeader(statusCode)
+
+	// Send the result back to the client.
+	if _, err := w.Write(jsonData); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -3,22 +3,48 @@ package handlers
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"expvar"
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"time"
 
 	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers/debug/checkgrp"
+	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers/debug/metricsgrp"
+	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers/debug/peergrp"
+	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers/rpc"
 	v1 "github.com/bruno-sartori/go-blockchain/app/services/node/handlers/v1"
 	"github.com/bruno-sartori/go-blockchain/business/web/v1/mid"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/worker"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+	"github.com/bruno-sartori/go-blockchain/foundation/openapi"
+	"github.com/bruno-sartori/go-blockchain/foundation/ratelimit"
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
 	"github.com/bruno-sartori/go-blockchain/foundation/web"
 	"go.uber.org/zap"
 )
 
 // MuxConfig contains all the mandatory systems required by handlers.
 type MuxConfig struct {
-	Shutdown chan os.Signal
-	Log      *zap.SugaredLogger
+	Build         string
+	Shutdown      chan os.Signal
+	Log           *zap.SugaredLogger
+	Exporter      tracing.Exporter
+	AllowedPeers  []database.AccountID
+	MaxRequestAge time.Duration
+	Peers         *peer.PeerSet
+	PrivateKey    *ecdsa.PrivateKey
+	State         *state.State
+	Evts          *events.Events
+	MaxBatchSize  int
+	MaxBatchBytes int64
+	QueryLimit    ratelimit.Limit
+	SubmitLimit   ratelimit.Limit
+	SenderLimit   ratelimit.Limit
 }
 
 // PublicMux constructs a http.Handler with all application routes defined.
@@ -30,6 +56,7 @@ func PublicMux(cfg MuxConfig) http.Handler {
 		mid.Logger(cfg.Log),
 		mid.Errors(cfg.Log),
 		mid.Metrics(),
+		mid.Tracing(cfg.Exporter),
 		mid.Cors("*"),
 		mid.Panics(),
 	)
@@ -42,9 +69,37 @@ func PublicMux(cfg MuxConfig) http.Handler {
 	}
 	app.Handle(http.MethodOptions, "", "/*", h, mid.Cors("*"))
 
+	// The limiters are shared by every route of the same group so a client
+	// has a single quota per group.
+	queryLimiter := ratelimit.New(cfg.QueryLimit)
+	submitLimiter := ratelimit.New(cfg.SubmitLimit)
+	senderLimiter := ratelimit.New(cfg.SenderLimit)
+
 	// Load the v1 routes.
 	v1.PublicRoutes(app, v1.Config{
-		Log: cfg.Log,
+		Log:           cfg.Log,
+		State:         cfg.State,
+		Evts:          cfg.Evts,
+		MaxBatchSize:  cfg.MaxBatchSize,
+		MaxBatchBytes: cfg.MaxBatchBytes,
+		QueryLimiter:  queryLimiter,
+		SubmitLimiter: submitLimiter,
+		SenderLimiter: senderLimiter,
+	})
+
+	// Load the JSON-RPC routes.
+	rpc.Routes(app, rpc.Config{
+		Log:           cfg.Log,
+		State:         cfg.State,
+		QueryLimiter:  queryLimiter,
+		SubmitLimiter: submitLimiter,
+		SenderLimiter: senderLimiter,
+	})
+
+	// Load the documentation of the routes above.
+	v1.DocRoutes(app, openapi.Info{
+		Title:   "Node Public API",
+		Version: cfg.Build,
 	})
 
 	return app
@@ -59,6 +114,7 @@ func PrivateMux(cfg MuxConfig) http.Handler {
 		mid.Logger(cfg.Log),
 		mid.Errors(cfg.Log),
 		mid.Metrics(),
+		mid.Tracing(cfg.Exporter),
 		mid.Cors("*"),
 		mid.Panics(),
 	)
@@ -71,9 +127,21 @@ func PrivateMux(cfg MuxConfig) http.Handler {
 	}
 	app.Handle(http.MethodOptions, "", "/*", h, mid.Cors("*"))
 
-	// Load the v1 routes.
+	// Load the v1 routes. They require a request signed by an allowed peer
+	// while the preflight and the documentation stay open.
 	v1.PrivateRoutes(app, v1.Config{
-		Log: cfg.Log,
+		Log:           cfg.Log,
+		State:         cfg.State,
+		Peers:         cfg.Peers,
+		PrivateKey:    cfg.PrivateKey,
+		AllowedPeers:  cfg.AllowedPeers,
+		MaxRequestAge: cfg.MaxRequestAge,
+	})
+
+	// Load the documentation of the routes above.
+	v1.DocRoutes(app, openapi.Info{
+		Title:   "Node Private API",
+		Version: cfg.Build,
 	})
 
 	return app
@@ -97,20 +165,51 @@ func DebugStandardLibraryMux() *http.ServeMux {
 	return mux
 }
 
+// DebugConfig contains all the mandatory systems required by debug handlers.
+type DebugConfig struct {
+	Build           string
+	Log             *zap.SugaredLogger
+	Peers           *peer.PeerSet
+	State           *state.State
+	Miner           *worker.Miner
+	ExpectPeers     bool
+	MaxBlocksBehind uint64
+}
+
 // DebugMux registers all the debug standard library routes and then custom
 // debug application routes for the service. This bypassing the use of the
 // DefaultServerMux. Using the DefaultServerMux would be a security risk since
 // a dependency could inject a handler into our service without us knowing it.
-func DebugMux(build string, log *zap.SugaredLogger) http.Handler {
+func DebugMux(cfg DebugConfig) http.Handler {
 	mux := DebugStandardLibraryMux()
 
 	// Register debug check endpoints.
 	cgh := checkgrp.Handlers{
-		Build: build,
-		Log:   log,
+		Build:           cfg.Build,
+		Log:             cfg.Log,
+		State:           cfg.State,
+		Peers:           cfg.Peers,
+		Miner:           cfg.Miner,
+		ExpectPeers:     cfg.ExpectPeers,
+		MaxBlocksBehind: cfg.MaxBlocksBehind,
 	}
 	mux.HandleFunc("/debug/readiness", cgh.Readiness)
 	mux.HandleFunc("/debug/liveness", cgh.Liveness)
 
+	// Register debug peer endpoints.
+	pgh := peergrp.Handlers{
+		Peers: cfg.Peers,
+		Log:   cfg.Log,
+	}
+	mux.HandleFunc("/debug/peers", pgh.Scores)
+
+	// Register the metrics endpoint for scraping.
+	mgh := metricsgrp.Handlers{
+		State: cfg.State,
+		Peers: cfg.Peers,
+		Log:   cfg.Log,
+	}
+	mux.HandleFunc("/metrics", mgh.Prometheus)
+
 	return mux
 }
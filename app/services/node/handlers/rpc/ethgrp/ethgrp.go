@@ -0,0 +1,465 @@
+// Package ethgrp maintains the group of handlers for the Ethereum compatible
+// JSON-RPC api.
+package ethgrp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/business/web/jsonrpc"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/ratelimit"
+	"github.com/bruno-sartori/go-blockchain/foundation/web"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"go.uber.org/zap"
+)
+
+// maxBodySize is the largest request body accepted, batches included.
+const maxBodySize = 1 << 20
+
+// maxBatchLength is the largest number of requests accepted in a batch.
+const maxBatchLength = 100
+
+// Handlers manages the set of JSON-RPC endpoints. Transactions sent are
+// held to the same submit and sender limits as the public api.
+type Handlers struct {
+	Log           *zap.SugaredLogger
+	State         *state.State
+	SubmitLimiter *ratelimit.Limiter
+	SenderLimiter *ratelimit.Limiter
+}
+
+// RPC processes a single JSON-RPC request or a batch of them. Failures are
+// always reported as JSON-RPC error objects so this handler only returns an
+// error when the response can't be written.
+func (h Handlers) RPC(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	cl := client{ip: ip, now: v.Now}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return h.respond(ctx, w, failure(nil, jsonrpc.NewError(jsonrpc.CodeParseError, "unable to read request")))
+	}
+
+	body = bytes.TrimSpace(body)
+
+	// A batch is an array of requests and is answered with an array holding
+	// a response for every request that is not a notification.
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return h.respond(ctx, w, failure(nil, jsonrpc.NewError(jsonrpc.CodeParseError, "parse error")))
+		}
+
+		switch {
+		case len(batch) == 0:
+			return h.respond(ctx, w, failure(nil, jsonrpc.NewError(jsonrpc.CodeInvalidRequest, "empty batch")))
+		case len(batch) > maxBatchLength:
+			return h.respond(ctx, w, failure(nil, jsonrpc.NewError(jsonrpc.CodeInvalidRequest, fmt.Sprintf("batch of %d requests exceeds the limit of %d", len(batch), maxBatchLength))))
+		}
+
+		resps := make([]jsonrpc.Response, 0, len(batch))
+		for _, raw := range batch {
+			if resp, ok := h.process(ctx, cl, raw); ok {
+				resps = append(resps, resp)
+			}
+		}
+
+		if len(resps) == 0 {
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		}
+
+		return h.respond(ctx, w, resps)
+	}
+
+	resp, ok := h.process(ctx, cl, body)
+	if !ok {
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+
+	return h.respond(ctx, w, resp)
+}
+
+// =============================================================================
+
+// client identifies the caller of the requests for the rate limits.
+type client struct {
+	ip  string
+	now time.Time
+}
+
+// process executes a single request. It returns false when the request is
+// a notification and no response should be sent.
+func (h Handlers) process(ctx context.Context, cl client, raw json.RawMessage) (jsonrpc.Response, bool) {
+	var req jsonrpc.Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return failure(nil, jsonrpc.NewError(jsonrpc.CodeParseError, "parse error")), true
+		}
+		return failure(nil, jsonrpc.NewError(jsonrpc.CodeInvalidRequest, "invalid request")), true
+	}
+
+	if req.Version != jsonrpc.Version || req.Method == "" {
+		return failure(req.ID, jsonrpc.NewError(jsonrpc.CodeInvalidRequest, "invalid request")), true
+	}
+
+	result, err := h.call(cl, req)
+
+	if req.IsNotification() {
+		return jsonrpc.Response{}, false
+	}
+
+	if err != nil {
+		if !jsonrpc.IsError(err) {
+			h.Log.Errorw("ERROR", "traceid", web.GetTraceID(ctx), "method", req.Method, "ERROR", err)
+			return failure(req.ID, jsonrpc.NewError(jsonrpc.CodeInternalError, "internal error")), true
+		}
+		return failure(req.ID, jsonrpc.GetError(err)), true
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		h.Log.Errorw("ERROR", "traceid", web.GetTraceID(ctx), "method", req.Method, "ERROR", err)
+		return failure(req.ID, jsonrpc.NewError(jsonrpc.CodeInternalError, "internal error")), true
+	}
+
+	resp := jsonrpc.Response{
+		Version: jsonrpc.Version,
+		Result:  data,
+		ID:      req.ID,
+	}
+
+	return resp, true
+}
+
+// call maps the request onto the method that implements it.
+func (h Handlers) call(cl client, req jsonrpc.Request) (any, error) {
+	switch req.Method {
+	case "eth_chainId":
+		return hexutil.EncodeUint64(uint64(h.State.Genesis().ChainID)), nil
+
+	case "eth_blockNumber":
+		return hexutil.EncodeUint64(h.State.LatestBlockNumber()), nil
+
+	case "eth_getBalance":
+		account, err := h.account(req.Params)
+		if err != nil {
+			return nil, err
+		}
+		return hexutil.EncodeUint64(account.Balance), nil
+
+	case "eth_getTransactionCount":
+		account, err := h.account(req.Params)
+		if err != nil {
+			return nil, err
+		}
+		return hexutil.EncodeUint64(account.Nonce), nil
+
+	case "eth_getBlockByNumber":
+		return h.blockByNumber(req.Params)
+
+	case "eth_getTransactionByHash":
+		return h.transactionByHash(req.Params)
+
+	case "eth_sendRawTransaction":
+		return h.sendRawTransaction(cl, req.Params)
+	}
+
+	return nil, jsonrpc.NewError(jsonrpc.CodeMethodNotFound, fmt.Sprintf("the method %s does not exist/is not available", req.Method))
+}
+
+// account handles the [address, block] params shared by eth_getBalance and
+// eth_getTransactionCount. Accounts that never transacted have a zero
+// balance and nonce.
+func (h Handlers) account(params json.RawMessage) (database.Account, error) {
+	var args []string
+	if err := json.Unmarshal(params, &args); err != nil || len(args) == 0 || len(args) > 2 {
+		return database.Account{}, jsonrpc.NewError(jsonrpc.CodeInvalidParams, "expected [address, block] params")
+	}
+
+	if !common.IsHexAddress(args[0]) {
+		return database.Account{}, jsonrpc.NewError(jsonrpc.CodeInvalidParams, "invalid address")
+	}
+	accountID := database.AccountID(common.HexToAddress(args[0]).Hex())
+
+	if len(args) == 2 {
+		if err := h.checkBlock(args[1]); err != nil {
+			return database.Account{}, err
+		}
+	}
+
+	account, err := h.State.QueryAccount(accountID)
+	if err != nil {
+		return database.Account{AccountID: accountID}, nil
+	}
+
+	return account, nil
+}
+
+// blockByNumber handles the [block, fullTransactions] params. A block that
+// isn't in the chain is reported as null.
+func (h Handlers) blockByNumber(params json.RawMessage) (any, error) {
+	var args []json.RawMessage
+	if err := json.Unmarshal(params, &args); err != nil || len(args) != 2 {
+		return nil, jsonrpc.NewError(jsonrpc.CodeInvalidParams, "expected [block, fullTransactions] params")
+	}
+
+	var tag string
+	if err := json.Unmarshal(args[0], &tag); err != nil {
+		return nil, jsonrpc.NewError(jsonrpc.CodeInvalidParams, "invalid block")
+	}
+
+	var full bool
+	if err := json.Unmarshal(args[1], &full); err != nil {
+		return nil, jsonrpc.NewError(jsonrpc.CodeInvalidParams, "invalid fullTransactions")
+	}
+
+	var number uint64
+	switch tag {
+	case "latest", "pending", "safe", "finalized":
+		number = h.State.LatestBlockNumber()
+	case "earliest":
+		number = 0
+	default:
+		var err error
+		if number, err = hexutil.DecodeUint64(tag); err != nil {
+			return nil, jsonrpc.NewError(jsonrpc.CodeInvalidParams, "invalid block: "+err.Error())
+		}
+	}
+
+	blk, exists := h.State.QueryBlock(number)
+	if !exists {
+		return nil, nil
+	}
+
+	return newBlock(blk, full, h.State.Genesis().Rules(number).GasPrice), nil
+}
+
+// transactionByHash handles the [hash] params. Mined transactions are found
+// in the transaction index and pending ones in the mempool.
+func (h Handlers) transactionByHash(params json.RawMessage) (any, error) {
+	var args []string
+	if err := json.Unmarshal(params, &args); err != nil || len(args) != 1 {
+		return nil, jsonrpc.NewError(jsonrpc.CodeInvalidParams, "expected [hash] params")
+	}
+
+	if tx, loc, exists := h.State.QueryMinedTransaction(args[0]); exists {
+		blk, exists := h.State.QueryBlock(loc.BlockNumber)
+		if !exists {
+			return nil, nil
+		}
+		return newMinedTransaction(tx, blk, loc.Index, h.State.Genesis().Rules(loc.BlockNumber).GasPrice), nil
+	}
+
+	tx, exists := h.State.QueryMempoolTransaction(args[0])
+	if !exists {
+		return nil, nil
+	}
+
+	return newTransaction(tx, h.State.Rules().GasPrice), nil
+}
+
+// sendRawTransaction handles the [data] params. The data is the hex encoded
+// JSON document of a signed transaction of this chain since transactions
+// are not RLP encoded here. Every transaction takes a token from the client
+// IP and from the account that signed it, like a submit to the public api.
+func (h Handlers) sendRawTransaction(cl client, params json.RawMessage) (any, error) {
+	if ok, _ := h.SubmitLimiter.Allow(cl.ip, cl.now); !ok {
+		return nil, jsonrpc.NewError(jsonrpc.CodeLimitExceeded, fmt.Sprintf("too many requests from %s", cl.ip))
+	}
+
+	var args []string
+	if err := json.Unmarshal(params, &args); err != nil || len(args) != 1 {
+		return nil, jsonrpc.NewError(jsonrpc.CodeInvalidParams, "expected [data] params")
+	}
+
+	data, err := hexutil.Decode(args[0])
+	if err != nil {
+		return nil, jsonrpc.NewError(jsonrpc.CodeInvalidParams, "invalid data: "+err.Error())
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var signedTx database.SignedTx
+	if err := decoder.Decode(&signedTx); err != nil {
+		return nil, jsonrpc.NewError(jsonrpc.CodeInvalidParams, "invalid signed transaction: "+err.Error())
+	}
+
+	if signedTx.V == nil || signedTx.R == nil || signedTx.S == nil {
+		return nil, jsonrpc.NewError(jsonrpc.CodeInvalidParams, "invalid signed transaction: missing signature")
+	}
+
+	// Only limit the account that signed the transaction, anyone can put
+	// another account in the from field. An invalid transaction is left for
+	// the state to reject.
+	if err := signedTx.Validate(h.State.Genesis().ChainID); err == nil {
+		if ok, _ := h.SenderLimiter.Allow(string(signedTx.FromID), cl.now); !ok {
+			return nil, jsonrpc.NewError(jsonrpc.CodeLimitExceeded, fmt.Sprintf("too many transactions from %s", signedTx.FromID))
+		}
+	}
+
+	if err := h.State.UpsertWalletTransaction(signedTx); err != nil {
+		return nil, jsonrpc.NewError(jsonrpc.CodeServerError, err.Error())
+	}
+
+	return signedTx.Hash(), nil
+}
+
+// checkBlock validates the block parameter. Only the state of the latest
+// block is kept so older block numbers, and the earliest block, can't be
+// queried.
+func (h Handlers) checkBlock(block string) error {
+	switch block {
+	case "latest", "pending", "safe", "finalized":
+		return nil
+	case "earliest":
+		return jsonrpc.NewError(jsonrpc.CodeInvalidParams, "state is only available for the latest block")
+	}
+
+	number, err := hexutil.DecodeUint64(block)
+	if err != nil {
+		return jsonrpc.NewError(jsonrpc.CodeInvalidParams, "invalid block: "+err.Error())
+	}
+
+	if number != h.State.LatestBlockNumber() {
+		return jsonrpc.NewError(jsonrpc.CodeServerError, "state is only available for the latest block")
+	}
+
+	return nil
+}
+
+// respond writes the JSON-RPC response. JSON-RPC responses always use the
+// 200 status code, even when they carry errors.
+func (h Handlers) respond(ctx context.Context, w http.ResponseWriter, data any) error {
+	return web.Respond(ctx, w, data, http.StatusOK)
+}
+
+// =============================================================================
+
+// transaction is the Ethereum representation of a transaction.
+type transaction struct {
+	Hash             string  `json:"hash"`
+	Nonce            string  `json:"nonce"`
+	BlockHash        *string `json:"blockHash"`
+	BlockNumber      *string `json:"blockNumber"`
+	TransactionIndex *string `json:"transactionIndex"`
+	From             string  `json:"from"`
+	To               string  `json:"to"`
+	Value            string  `json:"value"`
+	Gas              string  `json:"gas"`
+	GasPrice         string  `json:"gasPrice"`
+	Input            string  `json:"input"`
+	ChainID          string  `json:"chainId"`
+	V                string  `json:"v"`
+	R                string  `json:"r"`
+	S                string  `json:"s"`
+}
+
+// newTransaction maps a pending transaction of this chain onto the Ethereum
+// representation. Every transaction costs a single unit of gas and the tip
+// is paid on top of the gas price.
+func newTransaction(tx database.SignedTx, gasPrice uint64) transaction {
+	const oneUnitOfGas = 1
+
+	return transaction{
+		Hash:     tx.Hash(),
+		Nonce:    hexutil.EncodeUint64(tx.Nonce),
+		From:     string(tx.FromID),
+		To:       string(tx.ToID),
+		Value:    hexutil.EncodeUint64(tx.Value),
+		Gas:      hexutil.EncodeUint64(oneUnitOfGas),
+		GasPrice: hexutil.EncodeUint64(gasPrice + tx.Tip),
+		Input:    hexutil.Encode(tx.Data),
+		ChainID:  hexutil.EncodeUint64(uint64(tx.ChainID)),
+		V:        hexutil.EncodeBig(tx.V),
+		R:        hexutil.EncodeBig(tx.R),
+		S:        hexutil.EncodeBig(tx.S),
+	}
+}
+
+// newMinedTransaction maps a transaction of a block onto the Ethereum
+// representation.
+func newMinedTransaction(tx database.SignedTx, blk database.Block, index uint64, gasPrice uint64) transaction {
+	hash := blk.Hash()
+	number := hexutil.EncodeUint64(blk.Header.Number)
+	txIndex := hexutil.EncodeUint64(index)
+
+	t := newTransaction(tx, gasPrice)
+	t.BlockHash = &hash
+	t.BlockNumber = &number
+	t.TransactionIndex = &txIndex
+
+	return t
+}
+
+// block is the Ethereum representation of a block. The transactions are
+// either their hashes or the full transactions.
+type block struct {
+	Number           string `json:"number"`
+	Hash             string `json:"hash"`
+	ParentHash       string `json:"parentHash"`
+	Nonce            string `json:"nonce"`
+	Timestamp        string `json:"timestamp"`
+	Miner            string `json:"miner"`
+	Difficulty       string `json:"difficulty"`
+	TransactionsRoot string `json:"transactionsRoot"`
+	Transactions     []any  `json:"transactions"`
+}
+
+// newBlock maps a block of this chain onto the Ethereum representation.
+// Ethereum timestamps are in seconds.
+func newBlock(blk database.Block, full bool, gasPrice uint64) block {
+	b := block{
+		Number:           hexutil.EncodeUint64(blk.Header.Number),
+		Hash:             blk.Hash(),
+		ParentHash:       blk.Header.PrevBlockHash,
+		Nonce:            hexutil.EncodeUint64(blk.Header.Nonce),
+		Timestamp:        hexutil.EncodeUint64(blk.Header.TimeStamp / 1000),
+		Miner:            string(blk.Header.BeneficiaryID),
+		Difficulty:       hexutil.EncodeUint64(uint64(blk.Header.Difficulty)),
+		TransactionsRoot: blk.Header.TransRoot,
+		Transactions:     make([]any, len(blk.Trans)),
+	}
+
+	for i, tx := range blk.Trans {
+		if full {
+			b.Transactions[i] = newMinedTransaction(tx, blk, uint64(i), gasPrice)
+			continue
+		}
+		b.Transactions[i] = tx.Hash()
+	}
+
+	return b
+}
+
+// failure constructs the response for a failed request.
+func failure(id json.RawMessage, err *jsonrpc.Error) jsonrpc.Response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	return jsonrpc.Response{
+		Version: jsonrpc.Version,
+		Error:   err,
+		ID:      id,
+	}
+}
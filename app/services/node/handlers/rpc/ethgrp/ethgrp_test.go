@@ -0,0 +1,291 @@
+package ethgrp_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers"
+	"github.com/bruno-sartori/go-blockchain/app/services/node/nodetest"
+	"github.com/bruno-sartori/go-blockchain/business/web/jsonrpc"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+	"github.com/bruno-sartori/go-blockchain/foundation/ratelimit"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Query(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+
+	gen := nodetest.Genesis
+	gen.Difficulty = 1
+	gen.Balances = map[string]uint64{string(database.PublicKeyToAccountID(privateKey.PublicKey)): 1_000_000}
+
+	ctx := context.Background()
+	nw := nodetest.NewNetwork(t, 1, gen)
+
+	mined := signTx(t, privateKey, 1)
+	if err := nw.Submit(ctx, 0, mined); err != nil {
+		t.Fatalf("submitting tx: %s", err)
+	}
+	nw.Mine(ctx, 0)
+
+	pending := signTx(t, privateKey, 2)
+	if err := nw.Submit(ctx, 0, pending); err != nil {
+		t.Fatalf("submitting tx: %s", err)
+	}
+
+	tt := []struct {
+		name   string
+		method string
+		params []any
+		field  string
+		exp    any
+	}{
+		{"latest block", "eth_getBlockByNumber", []any{"latest", false}, "number", "0x1"},
+		{"latest block transactions", "eth_getBlockByNumber", []any{"latest", false}, "transactions", []any{mined.Hash()}},
+		{"earliest block", "eth_getBlockByNumber", []any{"earliest", false}, "hash", nw.Nodes[0].State.QueryHeaders(0, 1)[0].Hash()},
+		{"block past the chain", "eth_getBlockByNumber", []any{"0x5", false}, "", nil},
+		{"mined transaction", "eth_getTransactionByHash", []any{mined.Hash()}, "blockNumber", "0x1"},
+		{"pending transaction", "eth_getTransactionByHash", []any{pending.Hash()}, "blockNumber", nil},
+		{"unknown transaction", "eth_getTransactionByHash", []any{"0x1234"}, "", nil},
+	}
+
+	t.Log("Given the need to query the chain through JSON-RPC.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					result := call(t, nw.Nodes[0].Public.URL, tst.method, tst.params)
+
+					var got any
+					if err := json.Unmarshal(result, &got); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould decode the result: %s", failed, testID, err)
+					}
+
+					if tst.field != "" {
+						obj, ok := got.(map[string]any)
+						if !ok {
+							t.Fatalf("\t%s\tTest %d:\tShould get an object: got[%s]", failed, testID, result)
+						}
+						got = obj[tst.field]
+					}
+
+					gotJSON, _ := json.Marshal(got)
+					expJSON, _ := json.Marshal(tst.exp)
+					if !bytes.Equal(gotJSON, expJSON) {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected result: got[%s] exp[%s]", failed, testID, gotJSON, expJSON)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected result.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_BlockParam(t *testing.T) {
+	gen := nodetest.Genesis
+	gen.Difficulty = 1
+
+	ctx := context.Background()
+	nw := nodetest.NewNetwork(t, 1, gen)
+	nw.Mine(ctx, 0)
+
+	const address = "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76"
+
+	tt := []struct {
+		name  string
+		block string
+		code  int
+	}{
+		{"latest block", "latest", 0},
+		{"latest block by number", "0x1", 0},
+		{"earliest block", "earliest", jsonrpc.CodeInvalidParams},
+		{"block before the latest", "0x0", jsonrpc.CodeServerError},
+	}
+
+	t.Log("Given the need to query the state of an account at a block.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					code := 0
+					if rpcErr := post(t, nw.Nodes[0].Public.URL, request(t, "eth_getBalance", []any{address, tst.block})).Error; rpcErr != nil {
+						code = rpcErr.Code
+					}
+
+					if code != tst.code {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected error code: got[%d] exp[%d]", failed, testID, code, tst.code)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected error code.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_Limits(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+
+	gen := nodetest.Genesis
+	gen.Difficulty = 1
+	gen.Balances = map[string]uint64{string(database.PublicKeyToAccountID(privateKey.PublicKey)): 1_000_000}
+
+	nw := nodetest.NewNetwork(t, 1, gen)
+
+	// The limits only let a single transaction through for the test.
+	srv := httptest.NewServer(handlers.PublicMux(handlers.MuxConfig{
+		Shutdown:    make(chan os.Signal, 1),
+		Log:         zap.NewNop().Sugar(),
+		State:       nw.Nodes[0].State,
+		Evts:        events.New(),
+		SenderLimit: ratelimit.Limit{Rate: 0.001, Burst: 1},
+	}))
+	t.Cleanup(srv.Close)
+
+	send := func(nonce uint64) jsonrpc.Request {
+		data, err := json.Marshal(signTx(t, privateKey, nonce))
+		if err != nil {
+			t.Fatalf("marshaling tx: %s", err)
+		}
+		return request(t, "eth_sendRawTransaction", []any{hexutil.Encode(data)})
+	}
+
+	var tooLong []jsonrpc.Request
+	for i := 0; i < 101; i++ {
+		tooLong = append(tooLong, request(t, "eth_blockNumber", nil))
+	}
+
+	tt := []struct {
+		name string
+		body any
+		code int
+	}{
+		{"transaction from a sender with tokens", send(1), 0},
+		{"transaction from a sender out of tokens", send(2), jsonrpc.CodeLimitExceeded},
+		{"batch longer than the limit", tooLong, jsonrpc.CodeInvalidRequest},
+	}
+
+	t.Log("Given the need to limit the JSON-RPC requests.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					code := 0
+					if rpcErr := post(t, srv.URL, tst.body).Error; rpcErr != nil {
+						code = rpcErr.Code
+					}
+
+					if code != tst.code {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected error code: got[%d] exp[%d]", failed, testID, code, tst.code)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected error code.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+// =============================================================================
+
+// call performs the JSON-RPC request and returns its result.
+func call(t *testing.T, url string, method string, params []any) json.RawMessage {
+	t.Helper()
+
+	rpcResp := post(t, url, request(t, method, params))
+	if rpcResp.Error != nil {
+		t.Fatalf("calling %s: %s", method, rpcResp.Error.Message)
+	}
+
+	if rpcResp.Result == nil {
+		return json.RawMessage("null")
+	}
+
+	return rpcResp.Result
+}
+
+// request constructs a JSON-RPC request with the specified params.
+func request(t *testing.T, method string, params []any) jsonrpc.Request {
+	t.Helper()
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshaling params: %s", err)
+	}
+
+	return jsonrpc.Request{
+		Version: jsonrpc.Version,
+		Method:  method,
+		Params:  data,
+		ID:      json.RawMessage("1"),
+	}
+}
+
+// post sends the request, or batch of requests, and returns the response
+// or the single response a batch is refused with.
+func post(t *testing.T, url string, body any) jsonrpc.Response {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("marshaling request: %s", err)
+	}
+
+	resp, err := http.Post(url+"/rpc", "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("posting request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var rpcResp jsonrpc.Response
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		t.Fatalf("decoding response: %s", err)
+	}
+
+	return rpcResp
+}
+
+// signTx signs a transaction with the specified nonce.
+func signTx(t *testing.T, privateKey *ecdsa.PrivateKey, nonce uint64) database.SignedTx {
+	t.Helper()
+
+	tx, err := database.NewTx(1, nonce, database.PublicKeyToAccountID(privateKey.PublicKey), "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76", 10, 1, nil)
+	if err != nil {
+		t.Fatalf("constructing tx: %s", err)
+	}
+
+	signedTx, err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("signing tx: %s", err)
+	}
+
+	return signedTx
+}
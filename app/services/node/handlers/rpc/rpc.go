@@ -0,0 +1,41 @@
+// Package rpc contains the full set of handler functions and routes
+// supported by the JSON-RPC api.
+package rpc
+
+import (
+	"net/http"
+
+	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers/rpc/ethgrp"
+	"github.com/bruno-sartori/go-blockchain/business/web/jsonrpc"
+	"github.com/bruno-sartori/go-blockchain/business/web/v1/mid"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/ratelimit"
+	"github.com/bruno-sartori/go-blockchain/foundation/web"
+	"go.uber.org/zap"
+)
+
+// Config contains all the mandatory systems required by handlers.
+type Config struct {
+	Log           *zap.SugaredLogger
+	State         *state.State
+	QueryLimiter  *ratelimit.Limiter
+	SubmitLimiter *ratelimit.Limiter
+	SenderLimiter *ratelimit.Limiter
+}
+
+// Routes binds all the JSON-RPC routes.
+func Routes(app *web.App, cfg Config) {
+	eth := ethgrp.Handlers{
+		Log:           cfg.Log,
+		State:         cfg.State,
+		SubmitLimiter: cfg.SubmitLimiter,
+		SenderLimiter: cfg.SenderLimiter,
+	}
+
+	app.HandleDoc(http.MethodPost, "", "/rpc", web.Doc{
+		Summary:     "Ethereum compatible JSON-RPC",
+		Description: "Accepts a single request or a batch of them as an array.",
+		Request:     jsonrpc.Request{},
+		Response:    jsonrpc.Response{},
+	}, eth.RPC, mid.RateLimit(cfg.QueryLimiter))
+}
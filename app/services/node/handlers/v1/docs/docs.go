@@ -0,0 +1,25 @@
+// Package docs maintains the group of handlers for the API documentation.
+package docs
+
+import (
+	"context"
+	"net/http"
+
+	v1 "github.com/bruno-sartori/go-blockchain/business/web/v1"
+	"github.com/bruno-sartori/go-blockchain/foundation/openapi"
+	"github.com/bruno-sartori/go-blockchain/foundation/web"
+)
+
+// Handlers manages the set of documentation endpoints.
+type Handlers struct {
+	App  *web.App
+	Info openapi.Info
+}
+
+// OpenAPI returns the OpenAPI document generated from the routes registered
+// with the app, so the document always matches what is served.
+func (h Handlers) OpenAPI(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	doc := openapi.New(h.Info, h.App.Routes(), v1.ErrorResponse{})
+
+	return web.Respond(ctx, w, doc, http.StatusOK)
+}
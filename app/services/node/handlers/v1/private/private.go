@@ -3,17 +3,34 @@ package private
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/bruno-sartori/go-blockchain/business/web/auth"
+	v1 "github.com/bruno-sartori/go-blockchain/business/web/v1"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
 	"github.com/bruno-sartori/go-blockchain/foundation/web"
 	"go.uber.org/zap"
 )
 
 // Handlers manages the set of bar ledger endpoints.
 type Handlers struct {
-	Log *zap.SugaredLogger
+	Log        *zap.SugaredLogger
+	State      *state.State
+	Peers      *peer.PeerSet
+	PrivateKey *ecdsa.PrivateKey
 }
 
+// Set of limits on the number of headers and blocks returned by a single
+// request.
+const (
+	MaxHeaders = 256
+	MaxBlocks  = 16
+)
+
 // Sample just provides a starting point for the class.
 func (h Handlers) Sample(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	resp := struct {
@@ -24,3 +41,87 @@ func (h Handlers) Sample(ctx context.Context, w http.ResponseWriter, r *http.Req
 
 	return web.Respond(ctx, w, resp, http.StatusOK)
 }
+
+// Handshake validates the chain identity of the calling node. If the node
+// belongs to the same chain it is added as a peer run by the account that
+// signed the request and this node's handshake is returned signed, otherwise
+// the node is refused. The handshake returned reports the current height.
+func (h Handlers) Handshake(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var remote peer.Handshake
+	if err := web.Decode(r, &remote); err != nil {
+		return v1.NewProtocolError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	node, err := auth.GetNode(ctx)
+	if err != nil {
+		return web.NewShutdownError("node value missing from context")
+	}
+	pr := peer.New(node.Host)
+
+	identity := peer.NewHandshake(h.State.Genesis(), h.State.LatestBlockNumber())
+	if err := identity.Match(remote); err != nil {
+		h.Log.Infow("handshake", "traceid", web.GetTraceID(ctx), "status", "peer refused", "host", pr.Host, "ERROR", err)
+		return v1.NewRequestError(fmt.Errorf("handshake refused: %w", err), http.StatusConflict)
+	}
+
+	if h.Peers.Add(pr, node.AccountID) {
+		h.Log.Infow("handshake", "traceid", web.GetTraceID(ctx), "status", "peer added", "host", pr.Host, "accountid", node.AccountID, "height", remote.Height)
+	}
+	h.Peers.SetHeight(pr, remote.Height)
+
+	resp := identity
+	resp.Signature, err = auth.SignReply(identity, r.Header.Get(auth.HeaderNonce), h.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// Headers returns the headers of the chain starting at the specified block
+// number. Peers sync the header chain before they download the blocks.
+func (h Handlers) Headers(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	from, limit, err := parseRange(r, MaxHeaders)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, h.State.QueryHeaders(from, limit), http.StatusOK)
+}
+
+// Blocks returns the blocks of the chain starting at the specified block
+// number.
+func (h Handlers) Blocks(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	from, limit, err := parseRange(r, MaxBlocks)
+	if err != nil {
+		return err
+	}
+
+	blocks, err := h.State.QueryBlocks(from, limit)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	return web.Respond(ctx, w, blocks, http.StatusOK)
+}
+
+// =============================================================================
+
+// parseRange returns the block number in the path and the limit in the
+// query string, which defaults to the maximum.
+func parseRange(r *http.Request, max int) (uint64, int, error) {
+	from, err := strconv.ParseUint(web.Param(r, "from"), 10, 64)
+	if err != nil {
+		return 0, 0, v1.NewRequestError(fmt.Errorf("invalid block number %q", web.Param(r, "from")), http.StatusBadRequest)
+	}
+
+	limit := max
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > max {
+			return 0, 0, v1.NewRequestError(fmt.Errorf("limit must be between 1 and %d", max), http.StatusBadRequest)
+		}
+	}
+
+	return from, limit, nil
+}
@@ -0,0 +1,109 @@
+package private_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bruno-sartori/go-blockchain/app/services/node/nodetest"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_NodeAuth(t *testing.T) {
+	nw := nodetest.NewNetwork(t, 1, nodetest.Genesis)
+	url := nw.Nodes[0].Private.URL
+
+	tt := []struct {
+		name       string
+		method     string
+		path       string
+		statusCode int
+	}{
+		{"unsigned request for the documentation", http.MethodGet, "/v1/openapi.json", http.StatusOK},
+		{"unsigned preflight request", http.MethodOptions, "/v1/node/headers/0", http.StatusOK},
+		{"unsigned request for a node route", http.MethodGet, "/v1/node/headers/0", http.StatusUnauthorized},
+	}
+
+	t.Log("Given the need to only let peers use the node routes.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling an %s.", testID, tst.name)
+				{
+					req, err := http.NewRequest(tst.method, url+tst.path, nil)
+					if err != nil {
+						t.Fatalf("creating request: %s", err)
+					}
+
+					resp, err := http.DefaultClient.Do(req)
+					if err != nil {
+						t.Fatalf("sending request: %s", err)
+					}
+					resp.Body.Close()
+
+					if resp.StatusCode != tst.statusCode {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected status code: got[%d] exp[%d]", failed, testID, resp.StatusCode, tst.statusCode)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected status code.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_NodeAuthPenalties(t *testing.T) {
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		score  int
+	}{
+		{"request for a block range out of bounds", http.MethodGet, "/v1/node/headers/0?limit=100000", nil, peer.InitialScore},
+		{"request for an invalid block number", http.MethodGet, "/v1/node/blocks/latest", nil, peer.InitialScore},
+		{"handshake that can't be decoded", http.MethodPost, "/v1/node/handshake", "not a handshake", peer.InitialScore - peer.PenaltyProtocol},
+	}
+
+	t.Log("Given the need to only penalize peers that break the protocol.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					ctx := context.Background()
+
+					nw := nodetest.NewNetwork(t, 2, nodetest.Genesis)
+					nw.Connect(ctx)
+
+					from, to := nw.Nodes[0], nw.Nodes[1]
+					if err := from.Client.Do(ctx, tst.method, to.Private.URL+tst.path, tst.body, nil); err == nil {
+						t.Fatalf("\t%s\tTest %d:\tShould reject the request.", failed, testID)
+					}
+					t.Logf("\t%s\tTest %d:\tShould reject the request.", success, testID)
+
+					score := -1
+					for _, status := range to.Peers.Statuses() {
+						if status.AccountID == from.AccountID {
+							score = status.Score
+						}
+					}
+
+					if score != tst.score {
+						t.Fatalf("\t%s\tTest %d:\tShould leave the peer with the expected score: got[%d] exp[%d]", failed, testID, score, tst.score)
+					}
+					t.Logf("\t%s\tTest %d:\tShould leave the peer with the expected score.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
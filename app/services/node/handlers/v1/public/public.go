@@ -2,16 +2,76 @@
 package public
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	v1 "github.com/bruno-sartori/go-blockchain/business/web/v1"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+	"github.com/bruno-sartori/go-blockchain/foundation/ratelimit"
 	"github.com/bruno-sartori/go-blockchain/foundation/web"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
+// Set of limits used to page through account history.
+const (
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+// Set of timings used to keep websocket and event stream connections healthy.
+const (
+	writeWait    = 10 * time.Second
+	pingInterval = 30 * time.Second
+)
+
+// SubmitResponse is the response for a transaction added to the mempool.
+type SubmitResponse struct {
+	Status string `json:"status"`
+	Hash   string `json:"hash"`
+}
+
+// BatchResult is the result of a single transaction of a batch.
+type BatchResult struct {
+	Hash   string `json:"hash"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchResponse is the response for a batch of transactions.
+type BatchResponse struct {
+	Accepted int           `json:"accepted"`
+	Rejected int           `json:"rejected"`
+	Results  []BatchResult `json:"results"`
+}
+
+// AccountTxsResponse is a page of the history of an account.
+type AccountTxsResponse struct {
+	AccountID  database.AccountID   `json:"account_id"`
+	Txs        []database.AccountTx `json:"txs"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// =============================================================================
+
 // Handlers manages the set of bar ledger endpoints.
 type Handlers struct {
-	Log *zap.SugaredLogger
+	Log           *zap.SugaredLogger
+	State         *state.State
+	Evts          *events.Events
+	SenderLimiter *ratelimit.Limiter
+	MaxBatchSize  int
+	MaxBatchBytes int64
 }
 
 // Sample just provides a starting point for the class.
@@ -24,3 +84,464 @@ func (h Handlers) Sample(ctx context.Context, w http.ResponseWriter, r *http.Req
 
 	return web.Respond(ctx, w, resp, http.StatusOK)
 }
+
+// Transaction returns the status of the transaction with the specified hash.
+func (h Handlers) Transaction(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	hash := web.Param(r, "hash")
+
+	status, exists := h.State.QueryTransaction(hash)
+	if !exists {
+		return v1.NewRequestError(fmt.Errorf("transaction %q not found", hash), http.StatusNotFound)
+	}
+
+	return web.Respond(ctx, w, status, http.StatusOK)
+}
+
+// SubmitWalletTransaction adds a new transaction to the mempool.
+func (h Handlers) SubmitWalletTransaction(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	// Decode the JSON in the post call into a Signed transaction.
+	var signedTx database.SignedTx
+	if err := web.Decode(r, &signedTx); err != nil {
+		return v1.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	// Only limit the account that signed the transaction, anyone can put
+	// another account in the from field. An invalid transaction is left for
+	// the state to reject.
+	if err := signedTx.Validate(h.State.Genesis().ChainID); err == nil {
+		if ok, wait := h.SenderLimiter.Allow(string(signedTx.FromID), v.Now); !ok {
+			return v1.NewThrottleError(fmt.Errorf("too many transactions from %s", signedTx.FromID), wait)
+		}
+	}
+
+	h.Log.Infow("add tran", "traceid", v.TraceID, "sig:nonce", signedTx, "from", signedTx.FromID, "to", signedTx.ToID, "value", signedTx.Value, "tip", signedTx.Tip)
+
+	// Ask the state package to add this transaction to the mempool. Only the
+	// checks are the transaction signature, the recipient account format and
+	// that the nonce isn't used yet. It's up to the wallet to make sure the
+	// account has a proper balance and the next nonce. Fees will be taken if
+	// this transaction is mined into a block.
+	if err := h.State.UpsertWalletTransaction(signedTx); err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	resp := SubmitResponse{
+		Status: "transaction added to mempool",
+		Hash:   signedTx.Hash(),
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// SubmitWalletTransactions adds a batch of transactions to the mempool. Every
+// transaction is validated on its own and the response holds the result of
+// each one in the order provided. With the atomic query parameter set to
+// true the batch is rejected as a whole when any transaction fails.
+func (h Handlers) SubmitWalletTransactions(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	atomic := false
+	if a := r.URL.Query().Get("atomic"); a != "" {
+		atomic, err = strconv.ParseBool(a)
+		if err != nil {
+			return v1.NewRequestError(fmt.Errorf("invalid atomic %q", a), http.StatusBadRequest)
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxBatchBytes)
+
+	var signedTxs []database.SignedTx
+	if err := web.Decode(r, &signedTxs); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return v1.NewRequestError(fmt.Errorf("batch larger than %d bytes", h.MaxBatchBytes), http.StatusRequestEntityTooLarge)
+		}
+		return v1.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	switch {
+	case len(signedTxs) == 0:
+		return v1.NewRequestError(errors.New("empty batch"), http.StatusBadRequest)
+	case len(signedTxs) > h.MaxBatchSize:
+		return v1.NewRequestError(fmt.Errorf("batch of %d transactions exceeds the limit of %d", len(signedTxs), h.MaxBatchSize), http.StatusRequestEntityTooLarge)
+	}
+
+	// Every transaction of the batch takes a token from the account that
+	// signed it, and the batch is refused without taking any when one of
+	// them is out of tokens. A transaction that fails validation is rejected on its own
+	// like any other invalid transaction.
+	var senders []string
+	for _, signedTx := range signedTxs {
+		if err := signedTx.Validate(h.State.Genesis().ChainID); err == nil {
+			senders = append(senders, string(signedTx.FromID))
+		}
+	}
+
+	if ok, wait := h.SenderLimiter.AllowAll(senders, v.Now); !ok {
+		return v1.NewThrottleError(errors.New("too many transactions from the senders of the batch"), wait)
+	}
+
+	errs := h.State.UpsertWalletTransactions(signedTxs, atomic)
+
+	resp := BatchResponse{
+		Results: make([]BatchResult, len(signedTxs)),
+	}
+
+	for i, signedTx := range signedTxs {
+		resp.Results[i] = BatchResult{
+			Hash:   signedTx.Hash(),
+			Status: state.TxStatusPending,
+		}
+
+		if errs[i] != nil {
+			resp.Results[i].Status = state.TxStatusRejected
+			resp.Results[i].Error = errs[i].Error()
+			resp.Rejected++
+			continue
+		}
+		resp.Accepted++
+	}
+
+	h.Log.Infow("add trans", "traceid", v.TraceID, "atomic", atomic, "accepted", resp.Accepted, "rejected", resp.Rejected)
+
+	// A rejected atomic batch changed nothing so it is reported as a
+	// failed request.
+	statusCode := http.StatusOK
+	if atomic && resp.Rejected > 0 {
+		statusCode = http.StatusBadRequest
+	}
+
+	return web.Respond(ctx, w, resp, statusCode)
+}
+
+// EstimateFees returns the suggested tips for a new transaction.
+func (h Handlers) EstimateFees(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web.Respond(ctx, w, h.State.EstimateFees(), http.StatusOK)
+}
+
+// SimulateTransaction predicts the outcome of a transaction without changing
+// the state of the node. It accepts an unsigned transaction, or a signed one
+// whose signature is verified first.
+func (h Handlers) SimulateTransaction(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var signedTx database.SignedTx
+	if err := web.Decode(r, &signedTx); err != nil {
+		return v1.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	var hash string
+	switch {
+	case signedTx.V == nil && signedTx.R == nil && signedTx.S == nil:
+
+		// Unsigned transactions may not use the checksum format of the
+		// accounts the node stores.
+		if signedTx.FromID.IsAccountID() {
+			signedTx.FromID = database.AccountID(common.HexToAddress(string(signedTx.FromID)).Hex())
+		}
+		if signedTx.ToID.IsAccountID() {
+			signedTx.ToID = database.AccountID(common.HexToAddress(string(signedTx.ToID)).Hex())
+		}
+
+	case signedTx.V == nil || signedTx.R == nil || signedTx.S == nil:
+		return v1.NewRequestError(errors.New("incomplete signature"), http.StatusBadRequest)
+
+	default:
+		if err := signedTx.Validate(h.State.Genesis().ChainID); err != nil {
+			return v1.NewRequestError(err, http.StatusBadRequest)
+		}
+		hash = signedTx.Hash()
+	}
+
+	sim, err := h.State.SimulateTransaction(signedTx.Tx)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+	sim.Hash = hash
+
+	return web.Respond(ctx, w, sim, http.StatusOK)
+}
+
+// AccountTxs returns a page of the history of the specified account, newest
+// first. The direction query parameter filters the entries to sent, received
+// or reward, and the cursor returned with a page fetches the next one.
+func (h Handlers) AccountTxs(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	accountID, err := database.ToAccountID(web.Param(r, "id"))
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+	accountID = database.AccountID(common.HexToAddress(string(accountID)).Hex())
+
+	query := r.URL.Query()
+
+	direction := query.Get("direction")
+	switch direction {
+	case "", "all":
+		direction = ""
+	case database.DirectionSent, database.DirectionReceived, database.DirectionReward:
+	default:
+		return v1.NewRequestError(fmt.Errorf("invalid direction %q", direction), http.StatusBadRequest)
+	}
+
+	var cursor uint64
+	if c := query.Get("cursor"); c != "" {
+		cursor, err = strconv.ParseUint(c, 10, 64)
+		if err != nil || cursor == 0 {
+			return v1.NewRequestError(fmt.Errorf("invalid cursor %q", c), http.StatusBadRequest)
+		}
+	}
+
+	limit := defaultLimit
+	if l := query.Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return v1.NewRequestError(fmt.Errorf("limit must be between 1 and %d", maxLimit), http.StatusBadRequest)
+		}
+	}
+
+	txs, next := h.State.QueryAccountHistory(accountID, direction, cursor, limit)
+
+	resp := AccountTxsResponse{
+		AccountID: accountID,
+		Txs:       txs,
+	}
+	if next != 0 {
+		resp.NextCursor = strconv.FormatUint(next, 10)
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// Events upgrades the connection to a websocket and streams the events
+// generated by the blockchain as JSON documents. The account query parameter
+// can be provided multiple times to only receive events involving those
+// accounts. A client that reconnects with the last_event_id query parameter
+// first receives the events it missed, as long as they are still held in
+// the node's event history. Clients that can't keep up are dropped.
+func (h Handlers) Events(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	accounts, err := queryAccounts(r)
+	if err != nil {
+		return err
+	}
+
+	var lastID uint64
+	resume := r.URL.Query().Get("last_event_id")
+	if resume != "" {
+		lastID, err = strconv.ParseUint(resume, 10, 64)
+		if err != nil {
+			return v1.NewRequestError(fmt.Errorf("invalid last_event_id %q", resume), http.StatusBadRequest)
+		}
+	}
+
+	// This provides a channel for receiving events from the blockchain. The
+	// trace id can be set by the client, so every connection subscribes
+	// with an id of its own. The channel is acquired before the upgrade so
+	// the client receives every event sent once the handshake completes.
+	subID := uuid.NewString()
+	ch := h.Evts.Acquire(subID, accounts...)
+	defer h.Evts.Release(subID)
+
+	upgrader := websocket.Upgrader{
+
+		// CORS is handled by the middleware for every route.
+		CheckOrigin: func(r *http.Request) bool { return true },
+
+		// Let the error middleware respond when the upgrade fails.
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {},
+	}
+
+	// This upgrades the HTTP connection to a websocket connection.
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+	defer conn.Close()
+
+	web.SetStatusCode(ctx, http.StatusSwitchingProtocols)
+
+	// Like the event stream, the history is read after the channel is
+	// acquired and the events the channel holds that were already sent are
+	// skipped by id.
+	if resume != "" {
+		var evs []events.Event
+		evs, lastID = h.Evts.Since(lastID, accounts...)
+		for _, ev := range evs {
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteJSON(ev); err != nil {
+				return nil
+			}
+		}
+	}
+
+	// The client is not expected to send anything, but reading is required
+	// to process control messages and notice the client going away.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Starting a ticker to send a ping message over the websocket.
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	// Block waiting for events from the blockchain or ticker.
+	for {
+		select {
+		case ev, ok := <-ch:
+
+			// The channel is closed when the node shuts down or when this
+			// client fell too far behind.
+			if !ok {
+				msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "event stream closed")
+				conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+				return nil
+			}
+
+			if ev.ID <= lastID {
+				continue
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteJSON(ev); err != nil {
+				h.Log.Infow("events", "traceid", v.TraceID, "status", "client dropped", "ERROR", err)
+				return nil
+			}
+
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return nil
+			}
+
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// EventStream streams the events generated by the blockchain as server sent
+// events for clients that can't use a websocket. The account query parameter
+// filters the events the same way it does for the websocket. A client that
+// reconnects with the Last-Event-ID header first receives the events it
+// missed, as long as they are still held in the node's event history.
+func (h Handlers) EventStream(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	accounts, err := queryAccounts(r)
+	if err != nil {
+		return err
+	}
+
+	var lastID uint64
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		lastID, err = strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return v1.NewRequestError(fmt.Errorf("invalid Last-Event-ID %q", id), http.StatusBadRequest)
+		}
+	}
+
+	// Acquire the channel before reading the history so no event can be
+	// sent in between. Events the channel holds that were sent before the
+	// history was read are skipped by id. Like the websocket, every
+	// connection subscribes with an id of its own.
+	subID := uuid.NewString()
+	ch := h.Evts.Acquire(subID, accounts...)
+	defer h.Evts.Release(subID)
+
+	stream, err := web.NewStreamer(ctx, w, "text/event-stream", writeWait)
+	if err != nil {
+		return err
+	}
+
+	evs, lastID := h.Evts.Since(lastID, accounts...)
+	for _, ev := range evs {
+		if err := stream.Write(sseEvent(ev)); err != nil {
+			return nil
+		}
+	}
+
+	// Starting a ticker to send a comment line that keeps proxies from
+	// closing an idle connection.
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	// Block waiting for events from the blockchain or ticker. From here on
+	// the response is already started so errors are only logged.
+	for {
+		select {
+		case ev, ok := <-ch:
+
+			// The channel is closed when the node shuts down or when this
+			// client fell too far behind. The client reconnects and resumes
+			// from the last event it received.
+			if !ok {
+				return nil
+			}
+
+			if ev.ID <= lastID {
+				continue
+			}
+
+			if err := stream.Write(sseEvent(ev)); err != nil {
+				h.Log.Infow("events", "traceid", v.TraceID, "status", "client dropped", "ERROR", err)
+				return nil
+			}
+			lastID = ev.ID
+
+		case <-ticker.C:
+			if err := stream.Write([]byte(": ping\n\n")); err != nil {
+				return nil
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// =============================================================================
+
+// queryAccounts returns the accounts provided by the account query parameter.
+func queryAccounts(r *http.Request) ([]string, error) {
+	var accounts []string
+	for _, account := range r.URL.Query()["account"] {
+		accountID, err := database.ToAccountID(account)
+		if err != nil {
+			return nil, v1.NewRequestError(fmt.Errorf("invalid account %q: %w", account, err), http.StatusBadRequest)
+		}
+		accounts = append(accounts, common.HexToAddress(string(accountID)).Hex())
+	}
+
+	return accounts, nil
+}
+
+// sseEvent formats the event as a server sent event message.
+func sseEvent(ev events.Event) []byte {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		data = []byte(`{}`)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+
+	return b.Bytes()
+}
@@ -0,0 +1,315 @@
+package public_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers/v1/public"
+	"github.com/bruno-sartori/go-blockchain/app/services/node/nodetest"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gorilla/websocket"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_SubmitBatch(t *testing.T) {
+	tt := []struct {
+		name       string
+		atomic     string
+		statusCode int
+		accepted   int
+	}{
+		{"batch with an unsigned transaction", "false", http.StatusOK, 1},
+		{"atomic batch with an unsigned transaction", "true", http.StatusBadRequest, 0},
+	}
+
+	t.Log("Given the need to submit a batch of transactions.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					privateKey, err := crypto.GenerateKey()
+					if err != nil {
+						t.Fatalf("generating key: %s", err)
+					}
+					fromID := database.PublicKeyToAccountID(privateKey.PublicKey)
+
+					nw := nodetest.NewNetwork(t, 1, nodetest.Genesis)
+
+					signed, err := database.NewTx(1, 1, fromID, "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76", 10, 0, nil)
+					if err != nil {
+						t.Fatalf("constructing tx: %s", err)
+					}
+					signedTx, err := signed.Sign(privateKey)
+					if err != nil {
+						t.Fatalf("signing tx: %s", err)
+					}
+
+					unsigned := signedTx
+					unsigned.Nonce = 2
+					unsigned.V, unsigned.R, unsigned.S = nil, nil, nil
+
+					body, err := json.Marshal([]database.SignedTx{signedTx, unsigned})
+					if err != nil {
+						t.Fatalf("marshaling batch: %s", err)
+					}
+
+					url := nw.Nodes[0].Public.URL + "/v1/tx/submit/batch?atomic=" + tst.atomic
+					resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+					if err != nil {
+						t.Fatalf("submitting batch: %s", err)
+					}
+					defer resp.Body.Close()
+
+					if resp.StatusCode != tst.statusCode {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected status code: got[%d] exp[%d]", failed, testID, resp.StatusCode, tst.statusCode)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected status code.", success, testID)
+
+					var batch public.BatchResponse
+					if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould get a result per transaction: %s", failed, testID, err)
+					}
+
+					if batch.Accepted != tst.accepted || len(batch.Results) != 2 || batch.Results[1].Error != "missing signature" {
+						t.Fatalf("\t%s\tTest %d:\tShould get a result per transaction: %+v", failed, testID, batch)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get a result per transaction.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_EventStream(t *testing.T) {
+	t.Log("Given the need to stream events to every connection.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen two connections send the same trace id.", testID)
+		{
+			privateKey, err := crypto.GenerateKey()
+			if err != nil {
+				t.Fatalf("generating key: %s", err)
+			}
+			fromID := database.PublicKeyToAccountID(privateKey.PublicKey)
+
+			nw := nodetest.NewNetwork(t, 1, nodetest.Genesis)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			// The response starts once the connection is subscribed, so
+			// both connections receive the transaction sent below.
+			traceParent := tracing.FormatTraceParent(tracing.NewTraceID(), tracing.NewSpanID())
+			streams := make([]*bufio.Reader, 2)
+			for i := range streams {
+				url := nw.Nodes[0].Public.URL + "/v1/events/sse?account=" + string(fromID)
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+				if err != nil {
+					t.Fatalf("creating request: %s", err)
+				}
+				req.Header.Set(tracing.Header, traceParent)
+
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("opening stream: %s", err)
+				}
+				defer resp.Body.Close()
+
+				streams[i] = bufio.NewReader(resp.Body)
+			}
+
+			tx, err := database.NewTx(1, 1, fromID, "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76", 10, 0, nil)
+			if err != nil {
+				t.Fatalf("constructing tx: %s", err)
+			}
+			signedTx, err := tx.Sign(privateKey)
+			if err != nil {
+				t.Fatalf("signing tx: %s", err)
+			}
+			if err := nw.Submit(ctx, 0, signedTx); err != nil {
+				t.Fatalf("submitting tx: %s", err)
+			}
+
+			for i, stream := range streams {
+				if !readEvent(stream, signedTx.Hash()) {
+					t.Fatalf("\t%s\tTest %d:\tShould receive the event on connection %d.", failed, testID, i)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive the event on every connection.", success, testID)
+		}
+	}
+}
+
+func Test_Events(t *testing.T) {
+	t.Log("Given the need to stream events over a websocket.")
+	{
+		a := newKey(t)
+		b := newKey(t)
+		accountA := database.PublicKeyToAccountID(a.PublicKey)
+
+		nw := nodetest.NewNetwork(t, 1, nodetest.Genesis)
+		node := nw.Nodes[0]
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		wsURL := "ws" + strings.TrimPrefix(node.Public.URL, "http") + "/v1/events?account=" + string(accountA)
+
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a connection filters on an account.", testID)
+		var lastID uint64
+		{
+			conn := dial(t, ctx, wsURL)
+
+			// The transaction of account b is sent first, so the first
+			// message is only the one of account a when the filter works.
+			submit(t, ctx, nw, b, 1)
+			txA := submit(t, ctx, nw, a, 1)
+
+			ev := readMessage(t, conn)
+			if hash := eventHash(ev); hash != txA.Hash() {
+				t.Fatalf("\t%s\tTest %d:\tShould only receive the events of the account: got[%s] exp[%s]", failed, testID, hash, txA.Hash())
+			}
+			t.Logf("\t%s\tTest %d:\tShould only receive the events of the account.", success, testID)
+			lastID = ev.ID
+
+			conn.Close()
+
+			removed := false
+			for !removed && ctx.Err() == nil {
+				removed = node.Evts.Subscribers() == 0
+				time.Sleep(10 * time.Millisecond)
+			}
+			if !removed {
+				t.Fatalf("\t%s\tTest %d:\tShould remove the subscription on disconnect: got[%d]", failed, testID, node.Evts.Subscribers())
+			}
+			t.Logf("\t%s\tTest %d:\tShould remove the subscription on disconnect.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen a connection resumes from the last event received.", testID)
+		{
+			submit(t, ctx, nw, b, 2)
+			missed := submit(t, ctx, nw, a, 2)
+
+			conn := dial(t, ctx, fmt.Sprintf("%s&last_event_id=%d", wsURL, lastID))
+			defer conn.Close()
+
+			if hash := eventHash(readMessage(t, conn)); hash != missed.Hash() {
+				t.Fatalf("\t%s\tTest %d:\tShould first receive the event missed: got[%s] exp[%s]", failed, testID, hash, missed.Hash())
+			}
+			t.Logf("\t%s\tTest %d:\tShould first receive the event missed.", success, testID)
+
+			next := submit(t, ctx, nw, a, 3)
+			if hash := eventHash(readMessage(t, conn)); hash != next.Hash() {
+				t.Fatalf("\t%s\tTest %d:\tShould then receive the new events: got[%s] exp[%s]", failed, testID, hash, next.Hash())
+			}
+			t.Logf("\t%s\tTest %d:\tShould then receive the new events.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// newKey generates the key of a new account.
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+
+	return privateKey
+}
+
+// submit signs and submits a transaction of the account with the specified
+// nonce to the first node.
+func submit(t *testing.T, ctx context.Context, nw *nodetest.Network, privateKey *ecdsa.PrivateKey, nonce uint64) database.SignedTx {
+	t.Helper()
+
+	tx, err := database.NewTx(1, nonce, database.PublicKeyToAccountID(privateKey.PublicKey), "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76", 10, 0, nil)
+	if err != nil {
+		t.Fatalf("constructing tx: %s", err)
+	}
+
+	signedTx, err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("signing tx: %s", err)
+	}
+
+	if err := nw.Submit(ctx, 0, signedTx); err != nil {
+		t.Fatalf("submitting tx: %s", err)
+	}
+
+	return signedTx
+}
+
+// dial opens a websocket to the specified url. The handshake completes once
+// the connection is subscribed.
+func dial(t *testing.T, ctx context.Context, url string) *websocket.Conn {
+	t.Helper()
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		t.Fatalf("dialing %s: %s", url, err)
+	}
+	resp.Body.Close()
+
+	return conn
+}
+
+// readMessage reads the next event from the websocket.
+func readMessage(t *testing.T, conn *websocket.Conn) events.Event {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var ev events.Event
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatalf("reading event: %s", err)
+	}
+
+	return ev
+}
+
+// eventHash returns the hash of the transaction of a pending tx event.
+func eventHash(ev events.Event) string {
+	data, _ := ev.Data.(map[string]any)
+	hash, _ := data["hash"].(string)
+	return hash
+}
+
+// readEvent reads the stream until an event holding the specified text is
+// received. It returns false when the stream ends first.
+func readEvent(stream *bufio.Reader, text string) bool {
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			return false
+		}
+
+		if strings.HasPrefix(line, "data:") && strings.Contains(line, text) {
+			return true
+		}
+	}
+}
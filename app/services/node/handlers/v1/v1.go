@@ -3,10 +3,20 @@
 package v1
 
 import (
+	"crypto/ecdsa"
 	"net/http"
+	"time"
 
+	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers/v1/docs"
 	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers/v1/private"
 	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers/v1/public"
+	"github.com/bruno-sartori/go-blockchain/business/web/v1/mid"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+	"github.com/bruno-sartori/go-blockchain/foundation/openapi"
+	"github.com/bruno-sartori/go-blockchain/foundation/ratelimit"
 	"github.com/bruno-sartori/go-blockchain/foundation/web"
 	"go.uber.org/zap"
 )
@@ -15,23 +25,152 @@ const version = "v1"
 
 // Config contains all the mandatory systems required by handlers.
 type Config struct {
-	Log *zap.SugaredLogger
+	Log           *zap.SugaredLogger
+	State         *state.State
+	Evts          *events.Events
+	Peers         *peer.PeerSet
+	PrivateKey    *ecdsa.PrivateKey
+	AllowedPeers  []database.AccountID
+	MaxRequestAge time.Duration
+	MaxBatchSize  int
+	MaxBatchBytes int64
+	QueryLimiter  *ratelimit.Limiter
+	SubmitLimiter *ratelimit.Limiter
+	SenderLimiter *ratelimit.Limiter
 }
 
 // PublicRoutes binds all the version 1 public routes.
 func PublicRoutes(app *web.App, cfg Config) {
 	pbl := public.Handlers{
-		Log: cfg.Log,
+		Log:           cfg.Log,
+		State:         cfg.State,
+		Evts:          cfg.Evts,
+		SenderLimiter: cfg.SenderLimiter,
+		MaxBatchSize:  cfg.MaxBatchSize,
+		MaxBatchBytes: cfg.MaxBatchBytes,
 	}
 
-	app.Handle(http.MethodGet, version, "/sample", pbl.Sample)
+	// Queries and submissions have their own quota per client IP. The
+	// handlers also limit submissions per sending account once the
+	// signatures are verified.
+	query := mid.RateLimit(cfg.QueryLimiter)
+	submit := mid.RateLimit(cfg.SubmitLimiter)
+
+	app.HandleDoc(http.MethodGet, version, "/sample", web.Doc{
+		Summary: "Sample endpoint",
+	}, pbl.Sample, query)
+
+	app.HandleDoc(http.MethodGet, version, "/events", web.Doc{
+		Summary:     "Stream events over a websocket",
+		Description: "Upgrades the connection to a websocket that receives every event as a JSON message.",
+		Query: []web.QueryParam{
+			{Name: "account", Description: "Only receive events involving this account, can be repeated."},
+			{Name: "last_event_id", Description: "Resume after the last event received."},
+		},
+		Response:   events.Event{},
+		StatusCode: http.StatusSwitchingProtocols,
+	}, pbl.Events, query)
+
+	app.HandleDoc(http.MethodGet, version, "/events/sse", web.Doc{
+		Summary:     "Stream events as server sent events",
+		Description: "Send the Last-Event-ID header to resume after the last event received.",
+		Query:       []web.QueryParam{{Name: "account", Description: "Only receive events involving this account, can be repeated."}},
+		Response:    events.Event{},
+		ContentType: "text/event-stream",
+	}, pbl.EventStream, query)
+
+	app.HandleDoc(http.MethodGet, version, "/fees/estimate", web.Doc{
+		Summary:  "Suggest tips for a new transaction",
+		Response: state.FeeEstimate{},
+	}, pbl.EstimateFees, query)
+
+	app.HandleDoc(http.MethodPost, version, "/tx/submit", web.Doc{
+		Summary:  "Submit a signed transaction",
+		Request:  database.SignedTx{},
+		Response: public.SubmitResponse{},
+	}, pbl.SubmitWalletTransaction, submit)
+
+	app.HandleDoc(http.MethodPost, version, "/tx/submit/batch", web.Doc{
+		Summary:     "Submit a batch of signed transactions",
+		Description: "Every transaction is validated on its own unless atomic is true.",
+		Query:       []web.QueryParam{{Name: "atomic", Description: "Reject the whole batch if any transaction fails."}},
+		Request:     []database.SignedTx{},
+		Response:    public.BatchResponse{},
+	}, pbl.SubmitWalletTransactions, submit)
+
+	app.HandleDoc(http.MethodPost, version, "/tx/simulate", web.Doc{
+		Summary:     "Simulate a transaction",
+		Description: "Accepts an unsigned transaction or a signed one.",
+		Request:     database.SignedTx{},
+		Response:    state.Simulation{},
+	}, pbl.SimulateTransaction, query)
+
+	app.HandleDoc(http.MethodGet, version, "/tx/:hash", web.Doc{
+		Summary:  "Query the status of a transaction",
+		Response: state.TxStatus{},
+	}, pbl.Transaction, query)
+
+	app.HandleDoc(http.MethodGet, version, "/accounts/:id/txs", web.Doc{
+		Summary: "Query the history of an account",
+		Query: []web.QueryParam{
+			{Name: "direction", Description: "One of sent, received, reward or all."},
+			{Name: "cursor", Description: "The cursor returned with the previous page."},
+			{Name: "limit", Description: "The number of entries in the page."},
+		},
+		Response: public.AccountTxsResponse{},
+	}, pbl.AccountTxs, query)
 }
 
 // PrivateRoutes binds all the version 1 private routes.
 func PrivateRoutes(app *web.App, cfg Config) {
 	prv := private.Handlers{
-		Log: cfg.Log,
+		Log:        cfg.Log,
+		State:      cfg.State,
+		Peers:      cfg.Peers,
+		PrivateKey: cfg.PrivateKey,
+	}
+
+	// Every node route requires a request signed by an allowed peer.
+	nodeAuth := mid.NodeAuth(cfg.AllowedPeers, cfg.MaxRequestAge, cfg.Peers)
+
+	app.HandleDoc(http.MethodGet, version, "/node/sample", web.Doc{
+		Summary: "Sample endpoint",
+	}, prv.Sample, nodeAuth)
+
+	app.HandleDoc(http.MethodPost, version, "/node/handshake", web.Doc{
+		Summary:  "Exchange the chain identity with a peer",
+		Request:  peer.Handshake{},
+		Response: peer.Handshake{},
+	}, prv.Handshake, nodeAuth)
+
+	app.HandleDoc(http.MethodGet, version, "/node/headers/:from", web.Doc{
+		Summary: "Headers of the chain starting at a block number",
+		Query: []web.QueryParam{
+			{Name: "limit", Description: "The number of headers, at most 256."},
+		},
+		Response: []database.BlockHeader{},
+	}, prv.Headers, nodeAuth)
+
+	app.HandleDoc(http.MethodGet, version, "/node/blocks/:from", web.Doc{
+		Summary: "Blocks of the chain starting at a block number",
+		Query: []web.QueryParam{
+			{Name: "limit", Description: "The number of blocks, at most 16."},
+		},
+		Response: []database.Block{},
+	}, prv.Blocks, nodeAuth)
+}
+
+// DocRoutes binds the route serving the OpenAPI document of the app. It
+// documents every route registered with the app, including those
+// registered after it.
+func DocRoutes(app *web.App, info openapi.Info) {
+	dcs := docs.Handlers{
+		App:  app,
+		Info: info,
 	}
 
-	app.Handle(http.MethodGet, version, "/node/sample", prv.Sample)
+	app.HandleDoc(http.MethodGet, version, "/openapi.json", web.Doc{
+		Summary:  "The OpenAPI 3 document of this API",
+		Response: map[string]any{},
+	}, dcs.OpenAPI)
 }
@@ -7,12 +7,25 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/ardanlabs/conf/v3"
 	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers"
+	"github.com/bruno-sartori/go-blockchain/business/web/client"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/genesis"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/worker"
+	"github.com/bruno-sartori/go-blockchain/foundation/config"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
 	"github.com/bruno-sartori/go-blockchain/foundation/logger"
+	"github.com/bruno-sartori/go-blockchain/foundation/ratelimit"
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
+	"github.com/ethereum/go-ethereum/crypto"
 	"go.uber.org/zap"
 )
 
@@ -47,7 +60,8 @@ func run(log *zap.SugaredLogger) error {
 	// values.
 	cfg := struct {
 		conf.Version
-		Web struct {
+		Config string
+		Web    struct {
 			ReadTimeout     time.Duration `conf:"default:5s"`
 			WriteTimeout    time.Duration `conf:"default:10s"`
 			IdleTimeout     time.Duration `conf:"default:120s"`
@@ -55,6 +69,40 @@ func run(log *zap.SugaredLogger) error {
 			DebugHost       string        `conf:"default:0.0.0.0:7080"`
 			PublicHost      string        `conf:"default:0.0.0.0:8080"`
 			PrivateHost     string        `conf:"default:0.0.0.0:9080"`
+			MaxBatchSize    int           `conf:"default:500"`
+			MaxBatchBytes   int64         `conf:"default:4194304"`
+		}
+		RateLimit struct {
+			QueryRate   float64 `conf:"default:20"`
+			QueryBurst  int     `conf:"default:40"`
+			SubmitRate  float64 `conf:"default:2"`
+			SubmitBurst int     `conf:"default:10"`
+			SenderRate  float64 `conf:"default:1"`
+			SenderBurst int     `conf:"default:5"`
+		}
+		State struct {
+			Beneficiary string   `conf:"default:miner1"`
+			DBPath      string   `conf:"default:zblock/miner1/"`
+			GenesisPath string   `conf:"default:zblock/genesis.json"`
+			KeysFolder  string   `conf:"default:zblock/accounts/"`
+			OriginPeers []string `conf:"default:0.0.0.0:9080"`
+			MempoolSize int      `conf:"default:10000"`
+		}
+		Node struct {
+			AllowedPeers      []string      `conf:"default:0xFef311483Cc040e1A89fb9bb469eeB8A70935EF8;0xb8Ee4c7ac4ca3269fEc242780D7D960bd6272a61"`
+			MaxRequestAge     time.Duration `conf:"default:30s"`
+			BanThreshold      int           `conf:"default:0"`
+			BanDuration       time.Duration `conf:"default:10m"`
+			RequestTimeout    time.Duration `conf:"default:5s"`
+			MaxBlocksBehind   uint64        `conf:"default:2"`
+			HandshakeInterval time.Duration `conf:"default:30s"`
+			SyncInterval      time.Duration `conf:"default:10s"`
+			Mining            bool          `conf:"default:false"`
+			MineInterval      time.Duration `conf:"default:5s"`
+		}
+		Tracing struct {
+			Exporter string `conf:"default:none"`
+			File     string `conf:"default:zblock/miner1/spans.jsonl"`
 		}
 	}{
 		Version: conf.Version{
@@ -64,9 +112,17 @@ func run(log *zap.SugaredLogger) error {
 	}
 
 	// Parse will set the defaults and then look for any overriding values
-	// in environment variables and command line flags.
+	// in the config file, environment variables and command line flags.
 	const prefix = "NODE"
-	help, err := conf.Parse(prefix, &cfg)
+	var cfgFile *config.File
+	if path := config.Path(prefix, os.Args[1:]); path != "" {
+		var err error
+		if cfgFile, err = config.Load(path); err != nil {
+			return err
+		}
+	}
+
+	help, err := config.Parse(prefix, &cfg, cfgFile)
 	if err != nil {
 		if errors.Is(err, conf.ErrHelpWanted) {
 			fmt.Println(help)
@@ -89,12 +145,125 @@ func run(log *zap.SugaredLogger) error {
 	defer log.Infow("shutdown complete")
 
 	// Display the current configuration to the logs.
-	out, err := conf.String(&cfg)
+	out, err := config.String(prefix, &cfg, cfgFile, os.Args[1:])
 	if err != nil {
 		return fmt.Errorf("generating config for output: %w", err)
 	}
 	log.Infow("startup", "config", out)
 
+	// =========================================================================
+	// Node Identity
+
+	// The beneficiary key is the identity of this node. It is used to sign
+	// the requests this node sends to its peers.
+	path := filepath.Join(cfg.State.KeysFolder, cfg.State.Beneficiary+".ecdsa")
+	privateKey, err := crypto.LoadECDSA(path)
+	if err != nil {
+		return fmt.Errorf("unable to load private key for node: %w", err)
+	}
+
+	log.Infow("startup", "status", "node identity loaded", "accountid", database.PublicKeyToAccountID(privateKey.PublicKey))
+
+	// Only requests signed by these accounts are accepted by the private API.
+	allowedPeers := make([]database.AccountID, len(cfg.Node.AllowedPeers))
+	for i, peer := range cfg.Node.AllowedPeers {
+		accountID, err := database.ToAccountID(peer)
+		if err != nil {
+			return fmt.Errorf("invalid allowed peer %q: %w", peer, err)
+		}
+		allowedPeers[i] = accountID
+	}
+
+	// =========================================================================
+	// Tracing Support
+
+	// Spans are exported as JSON to a file of their own for local use, so
+	// they don't mix with the logs. There is no collector to send them to
+	// yet.
+	var exporter tracing.Exporter
+	switch cfg.Tracing.Exporter {
+	case "none":
+		exporter = tracing.NopExporter{}
+	case "file":
+		if err := os.MkdirAll(filepath.Dir(cfg.Tracing.File), 0755); err != nil {
+			return fmt.Errorf("creating tracing folder: %w", err)
+		}
+
+		spans, err := os.OpenFile(cfg.Tracing.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("opening tracing file: %w", err)
+		}
+		defer spans.Close()
+
+		exporter = tracing.NewJSONExporter(spans)
+	default:
+		return fmt.Errorf("unknown tracing exporter %q", cfg.Tracing.Exporter)
+	}
+
+	log.Infow("startup", "status", "tracing support", "exporter", cfg.Tracing.Exporter, "file", cfg.Tracing.File)
+
+	// =========================================================================
+	// Genesis
+
+	gen, err := genesis.Load(cfg.State.GenesisPath)
+	if err != nil {
+		return fmt.Errorf("unable to load genesis: %w", err)
+	}
+
+	log.Infow("startup", "status", "genesis loaded", "chainid", gen.ChainID, "genesishash", gen.Hash())
+
+	// =========================================================================
+	// Blockchain Support
+
+	// The blockchain packages accept events that are streamed to the
+	// websocket and event stream clients.
+	evts := events.New()
+
+	// The state value represents the blockchain node and manages the
+	// accounts and the mempool.
+	st, err := state.New(state.Config{
+		BeneficiaryID: database.PublicKeyToAccountID(privateKey.PublicKey),
+		DBPath:        cfg.State.DBPath,
+		Genesis:       gen,
+		Evts:          evts,
+		MempoolSize:   cfg.State.MempoolSize,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to start blockchain: %w", err)
+	}
+	defer st.Shutdown()
+
+	// =========================================================================
+	// Peers
+
+	// The peer set tracks the reputation of every peer. Peers that misbehave
+	// are banned for a while and ignored by the node. Peers are only added
+	// once a handshake proves they belong to the same chain.
+	peerSet := peer.NewPeerSet(cfg.Node.BanThreshold, cfg.Node.BanDuration, evts)
+
+	// The node is only expected to have peers when it was given other nodes
+	// to connect to.
+	var expectPeers bool
+	for _, host := range cfg.State.OriginPeers {
+		if !peer.New(host).Match(cfg.Web.PrivateHost) {
+			expectPeers = true
+		}
+	}
+
+	// The client signs the requests this node sends to its peers.
+	nodeClient := client.New(cfg.Web.PrivateHost, privateKey, cfg.Node.RequestTimeout)
+
+	// The miner is only constructed when the node mines, so the readiness
+	// check knows whether to expect it running.
+	var miner *worker.Miner
+	if cfg.Node.Mining {
+		miner = worker.NewMiner(worker.MinerConfig{
+			State:    st,
+			Exporter: exporter,
+			Log:      log.Infow,
+		})
+	}
+
 	// =========================================================================
 	// Start Debug Service
 
@@ -104,7 +273,15 @@ func run(log *zap.SugaredLogger) error {
 	// related endpoints. This includes the standard library endpoints.
 
 	// Construct the mux for the debug calls.
-	debugMux := handlers.DebugMux(build, log)
+	debugMux := handlers.DebugMux(handlers.DebugConfig{
+		Build:           build,
+		Log:             log,
+		Peers:           peerSet,
+		State:           st,
+		Miner:           miner,
+		ExpectPeers:     expectPeers,
+		MaxBlocksBehind: cfg.Node.MaxBlocksBehind,
+	})
 
 	// Start the service listening for debug requests.
 	// Not concerned with shutting this down with load shedding.
@@ -133,8 +310,17 @@ func run(log *zap.SugaredLogger) error {
 
 	// Construct the mux for the public API calls.
 	publicMux := handlers.PublicMux(handlers.MuxConfig{
-		Shutdown: shutdown,
-		Log:      log,
+		Build:         build,
+		Shutdown:      shutdown,
+		Log:           log,
+		Exporter:      exporter,
+		State:         st,
+		Evts:          evts,
+		MaxBatchSize:  cfg.Web.MaxBatchSize,
+		MaxBatchBytes: cfg.Web.MaxBatchBytes,
+		QueryLimit:    ratelimit.Limit{Rate: cfg.RateLimit.QueryRate, Burst: cfg.RateLimit.QueryBurst},
+		SubmitLimit:   ratelimit.Limit{Rate: cfg.RateLimit.SubmitRate, Burst: cfg.RateLimit.SubmitBurst},
+		SenderLimit:   ratelimit.Limit{Rate: cfg.RateLimit.SenderRate, Burst: cfg.RateLimit.SenderBurst},
 	})
 
 	// Construct a server to service the requests against the mux.
@@ -160,8 +346,15 @@ func run(log *zap.SugaredLogger) error {
 
 	// Construct the mux for the private API calls.
 	privateMux := handlers.PrivateMux(handlers.MuxConfig{
-		Shutdown: shutdown,
-		Log:      log,
+		Build:         build,
+		Shutdown:      shutdown,
+		Log:           log,
+		Exporter:      exporter,
+		AllowedPeers:  allowedPeers,
+		MaxRequestAge: cfg.Node.MaxRequestAge,
+		Peers:         peerSet,
+		PrivateKey:    privateKey,
+		State:         st,
 	})
 
 	// Construct a server to service the requests against the mux.
@@ -180,6 +373,64 @@ func run(log *zap.SugaredLogger) error {
 		serverErrors <- private.ListenAndServe()
 	}()
 
+	// =========================================================================
+	// Peer Handshakes, Chain Sync And Mining
+
+	// The handshaker adds the origin peers to the peer set, trying again
+	// every interval for the ones that are down. Peers started after this
+	// node perform the handshake with us instead.
+	handshaker := worker.NewHandshaker(worker.HandshakerConfig{
+		Host:     cfg.Web.PrivateHost,
+		Origins:  cfg.State.OriginPeers,
+		Allowed:  allowedPeers,
+		State:    st,
+		Peers:    peerSet,
+		Shaker:   nodeClient,
+		Exporter: exporter,
+		Log:      log.Infow,
+	})
+
+	// The syncer keeps the chain in line with the longest chain of the
+	// peers. It is stopped before the state is shut down.
+	syncer := worker.NewSyncer(worker.Config{
+		Host:     cfg.Web.PrivateHost,
+		State:    st,
+		Peers:    peerSet,
+		Fetcher:  nodeClient,
+		Exporter: exporter,
+		Log:      log.Infow,
+	})
+
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	defer func() {
+		log.Infow("shutdown", "status", "stopping workers")
+		cancelWorkers()
+		workers.Wait()
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		handshaker.Run(workerCtx, cfg.Node.HandshakeInterval)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		syncer.Run(workerCtx, cfg.Node.SyncInterval)
+	}()
+
+	if miner != nil {
+		log.Infow("startup", "status", "miner started", "interval", cfg.Node.MineInterval)
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			miner.Run(workerCtx, cfg.Node.MineInterval)
+		}()
+	}
+
 	// =========================================================================
 	// Shutdown
 
@@ -192,6 +443,11 @@ func run(log *zap.SugaredLogger) error {
 		log.Infow("shutdown", "status", "shutdown started", "signal", sig)
 		defer log.Infow("shutdown", "status", "shutdown complete", "signal", sig)
 
+		// Close the event streams so long lived requests don't hold up
+		// the shutdown of the servers.
+		log.Infow("shutdown", "status", "closing event streams")
+		evts.Shutdown()
+
 		// Give outstanding requests a deadline for completion.
 		ctx, cancelPub := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
 		defer cancelPub()
@@ -0,0 +1,352 @@
+// Package nodetest provides support for running a network of nodes inside a
+// single process for integration testing.
+package nodetest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/app/services/node/handlers"
+	"github.com/bruno-sartori/go-blockchain/business/web/auth"
+	"github.com/bruno-sartori/go-blockchain/business/web/client"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/genesis"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/worker"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.uber.org/zap"
+)
+
+// Genesis is a genesis tests can use to start a simulated network. It does
+// not depend on the zblock folder so tests can run from any directory.
+var Genesis = genesis.Genesis{
+	Date:          time.Date(2021, time.December, 17, 0, 0, 0, 0, time.UTC),
+	ChainID:       1,
+	TransPerBlock: 10,
+	Difficulty:    2,
+	MiningReward:  700,
+	GasPrice:      15,
+	Balances: map[string]uint64{
+		"0xF01813E4B85e178A83e29B8E7bF26BD830a25f32": 1000000,
+		"0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4": 1000000,
+	},
+}
+
+// Node represents a single node running in the simulated network.
+type Node struct {
+	Name      string
+	AccountID database.AccountID
+	DataDir   string
+	Peers     *peer.PeerSet
+	State     *state.State
+	Evts      *events.Events
+	Private   *httptest.Server
+	Public    *httptest.Server
+	Client    *client.Client
+	Syncer    *worker.Syncer
+}
+
+// Host returns the private API host of the node.
+func (n *Node) Host() string {
+	return n.Private.Listener.Addr().String()
+}
+
+// Identity returns the handshake of the node at its current height.
+func (n *Node) Identity() peer.Handshake {
+	return peer.NewHandshake(n.State.Genesis(), n.State.LatestBlockNumber())
+}
+
+// Network represents a set of nodes running in the same process.
+type Network struct {
+	t     *testing.T
+	Nodes []*Node
+
+	mu        sync.RWMutex
+	partition map[string]int
+	now       time.Time
+}
+
+// NewNetwork starts the specified number of nodes. Each node has its own
+// identity key and temporary data directory, and every node is allowed to
+// talk to every other node. The nodes share a clock starting at the date
+// of the genesis that moves a second every time it is read, so the blocks
+// mined are the same on every run. The nodes are stopped when the test
+// completes.
+func NewNetwork(t *testing.T, n int, gen genesis.Genesis) *Network {
+	t.Helper()
+
+	network := Network{
+		t:         t,
+		partition: make(map[string]int),
+		now:       gen.Date,
+	}
+
+	// Generate the identity of every node first since every node needs the
+	// full list of accounts to authenticate its peers.
+	privateKeys := make([]*ecdsa.PrivateKey, n)
+	allowed := make([]database.AccountID, n)
+	for i := range privateKeys {
+		privateKey, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("generating key for node %d: %s", i, err)
+		}
+
+		privateKeys[i] = privateKey
+		allowed[i] = database.PublicKeyToAccountID(privateKey.PublicKey)
+	}
+
+	log := zap.NewNop().Sugar()
+
+	for i, privateKey := range privateKeys {
+		shutdown := make(chan os.Signal, 1)
+
+		evts := events.New()
+		t.Cleanup(evts.Shutdown)
+
+		dataDir := t.TempDir()
+
+		st, err := state.New(state.Config{
+			BeneficiaryID: allowed[i],
+			DBPath:        dataDir,
+			Genesis:       gen,
+			Evts:          evts,
+			Clock:         network.clock,
+		})
+		if err != nil {
+			t.Fatalf("starting state for node %d: %s", i, err)
+		}
+		t.Cleanup(func() { st.Shutdown() })
+
+		node := Node{
+			Name:      fmt.Sprintf("node%d", i),
+			AccountID: allowed[i],
+			DataDir:   dataDir,
+			Peers:     peer.NewPeerSet(0, time.Minute, evts),
+			State:     st,
+			Evts:      evts,
+		}
+
+		cfg := handlers.MuxConfig{
+			Build:         "nodetest",
+			Shutdown:      shutdown,
+			Log:           log,
+			AllowedPeers:  allowed,
+			MaxRequestAge: time.Minute,
+			Peers:         node.Peers,
+			PrivateKey:    privateKey,
+			State:         st,
+			Evts:          evts,
+			MaxBatchSize:  500,
+			MaxBatchBytes: 4 << 20,
+		}
+
+		node.Public = httptest.NewServer(handlers.PublicMux(cfg))
+		node.Private = httptest.NewUnstartedServer(nil)
+		node.Private.Config.Handler = network.gate(node.Private, handlers.PrivateMux(cfg))
+		node.Private.Start()
+		node.Client = client.New(node.Host(), privateKey, 5*time.Second)
+		node.Syncer = worker.NewSyncer(worker.Config{
+			Host:    node.Host(),
+			State:   st,
+			Peers:   node.Peers,
+			Fetcher: node.Client,
+		})
+
+		t.Cleanup(node.Public.Close)
+		t.Cleanup(node.Private.Close)
+
+		network.Nodes = append(network.Nodes, &node)
+	}
+
+	return &network
+}
+
+// Connect performs the handshake between every pair of nodes that can reach
+// each other so they all become peers.
+func (nw *Network) Connect(ctx context.Context) {
+	nw.t.Helper()
+
+	for _, from := range nw.Nodes {
+		for _, to := range nw.Nodes {
+			if from == to {
+				continue
+			}
+
+			pr := peer.New(to.Host())
+			remote, accountID, err := from.Client.Handshake(ctx, pr, from.Identity())
+			if err != nil {
+				nw.t.Logf("handshake %s -> %s: %s", from.Name, to.Name, err)
+				continue
+			}
+
+			if accountID != to.AccountID {
+				nw.t.Logf("handshake %s -> %s: reply signed by %s", from.Name, to.Name, accountID)
+				continue
+			}
+
+			if err := from.Identity().Match(remote); err != nil {
+				nw.t.Logf("handshake %s -> %s: %s", from.Name, to.Name, err)
+				continue
+			}
+
+			from.Peers.Add(pr, accountID)
+		}
+	}
+}
+
+// Partition splits the network into the specified groups of node indexes.
+// Nodes in different groups can't reach each other until Heal is called.
+// Nodes not listed in any group are placed together in their own group.
+func (nw *Network) Partition(groups ...[]int) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	nw.partition = make(map[string]int)
+	for group, indexes := range groups {
+		for _, i := range indexes {
+			nw.partition[nw.Nodes[i].Host()] = group + 1
+		}
+	}
+}
+
+// Heal removes any partition so every node can reach every other node.
+func (nw *Network) Heal() {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	nw.partition = make(map[string]int)
+}
+
+// Submit sends the transaction to the public API of the specified node.
+func (nw *Network) Submit(ctx context.Context, node int, tx database.SignedTx) error {
+	url := nw.Nodes[node].Public.URL + "/v1/tx/submit"
+	return nw.Nodes[node].Client.Do(ctx, http.MethodPost, url, tx, nil)
+}
+
+// Mine mines a block with the pending transactions of the specified node.
+func (nw *Network) Mine(ctx context.Context, node int) database.Block {
+	nw.t.Helper()
+
+	block, err := nw.Nodes[node].State.MineNewBlock(ctx)
+	if err != nil {
+		nw.t.Fatalf("%s: mining block: %s", nw.Nodes[node].Name, err)
+	}
+
+	return block
+}
+
+// Sync has every node sync with its peers. The nodes sync once for every
+// node in the network so a block reaches nodes that only see it through
+// other nodes.
+func (nw *Network) Sync(ctx context.Context) {
+	for range nw.Nodes {
+		for _, node := range nw.Nodes {
+			node.Syncer.Sync(ctx)
+		}
+	}
+}
+
+// AssertConverged fails the test unless every node knows every other node
+// as a peer, all nodes report the same chain identity and all nodes have
+// the same latest block and the same balances for the accounts of the
+// genesis and the nodes.
+func (nw *Network) AssertConverged() {
+	nw.t.Helper()
+
+	first := nw.Nodes[0]
+
+	accountIDs := make([]database.AccountID, 0, len(first.State.Genesis().Balances)+len(nw.Nodes))
+	for accountID := range first.State.Genesis().Balances {
+		accountIDs = append(accountIDs, database.AccountID(accountID))
+	}
+	for _, node := range nw.Nodes {
+		accountIDs = append(accountIDs, node.AccountID)
+	}
+
+	for _, node := range nw.Nodes {
+		if err := node.Identity().Match(first.Identity()); err != nil {
+			nw.t.Errorf("%s: %s", node.Name, err)
+		}
+
+		var got []string
+		for _, pr := range node.Peers.Copy(node.Host()) {
+			got = append(got, pr.Host)
+		}
+		sort.Strings(got)
+
+		var exp []string
+		for _, other := range nw.Nodes {
+			if other != node {
+				exp = append(exp, other.Host())
+			}
+		}
+		sort.Strings(exp)
+
+		if fmt.Sprint(got) != fmt.Sprint(exp) {
+			nw.t.Errorf("%s: peers not converged, got%v exp%v", node.Name, got, exp)
+		}
+
+		if got, exp := node.State.LatestBlock(), first.State.LatestBlock(); got.Hash() != exp.Hash() {
+			nw.t.Errorf("%s: chain not converged, got block %d %s exp block %d %s", node.Name, got.Number, got.Hash(), exp.Number, exp.Hash())
+			continue
+		}
+
+		for _, accountID := range accountIDs {
+			got, _ := node.State.QueryAccount(accountID)
+			exp, _ := first.State.QueryAccount(accountID)
+			if got != exp {
+				nw.t.Errorf("%s: account %s not converged, got%+v exp%+v", node.Name, accountID, got, exp)
+			}
+		}
+	}
+}
+
+// =============================================================================
+
+// gate wraps the private API of a node so requests coming from a node in a
+// different partition are dropped as if the network was down.
+func (nw *Network) gate(srv *httptest.Server, handler http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		if !nw.reachable(r.Header.Get(auth.HeaderHost), srv.Listener.Addr().String()) {
+			if hj, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hj.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(f)
+}
+
+// clock returns the time of the network and moves it forward a second.
+func (nw *Network) clock() time.Time {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	nw.now = nw.now.Add(time.Second)
+	return nw.now
+}
+
+// reachable reports whether the two hosts are in the same partition.
+func (nw *Network) reachable(from string, to string) bool {
+	nw.mu.RLock()
+	defer nw.mu.RUnlock()
+
+	return nw.partition[from] == nw.partition[to]
+}
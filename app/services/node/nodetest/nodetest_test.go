@@ -0,0 +1,98 @@
+package nodetest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruno-sartori/go-blockchain/app/services/node/nodetest"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Converge(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+	fromID := database.PublicKeyToAccountID(privateKey.PublicKey)
+
+	gen := nodetest.Genesis
+	gen.Difficulty = 1
+	gen.Balances = map[string]uint64{string(fromID): 1_000_000}
+
+	tx, err := database.NewTx(1, 1, fromID, "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76", 100, 5, nil)
+	if err != nil {
+		t.Fatalf("constructing tx: %s", err)
+	}
+	signedTx, err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("signing tx: %s", err)
+	}
+
+	ctx := context.Background()
+
+	nw := nodetest.NewNetwork(t, 3, gen)
+	nw.Connect(ctx)
+
+	t.Log("Given the need for a network of nodes to agree on one chain.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the network is partitioned and each side mines.", testID)
+		{
+			nw.Partition([]int{0}, []int{1, 2})
+
+			if err := nw.Submit(ctx, 0, signedTx); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the transaction: %s", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept the transaction.", success, testID)
+
+			nw.Mine(ctx, 0)
+			nw.Mine(ctx, 1)
+			nw.Mine(ctx, 1)
+			nw.Sync(ctx)
+
+			if got, exp := nw.Nodes[2].State.LatestBlock().Hash(), nw.Nodes[1].State.LatestBlock().Hash(); got != exp {
+				t.Fatalf("\t%s\tTest %d:\tShould sync inside the partition: got[%s] exp[%s]", failed, testID, got, exp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould sync inside the partition.", success, testID)
+
+			if latest := nw.Nodes[0].State.LatestBlockNumber(); latest != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould not sync across the partition: got[%d] exp[1]", failed, testID, latest)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not sync across the partition.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the partition heals.", testID)
+		{
+			nw.Heal()
+			nw.Sync(ctx)
+			nw.AssertConverged()
+
+			if _, exists := nw.Nodes[0].State.QueryMempoolTransaction(signedTx.Hash()); !exists {
+				t.Fatalf("\t%s\tTest %d:\tShould return the reverted transaction to the mempool.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould converge on the longest chain.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the reverted transaction is mined again.", testID)
+		{
+			block := nw.Mine(ctx, 0)
+			nw.Sync(ctx)
+			nw.AssertConverged()
+
+			account, _ := nw.Nodes[2].State.QueryAccount(signedTx.ToID)
+			if len(block.Trans) != 1 || account.Balance != 100 {
+				t.Fatalf("\t%s\tTest %d:\tShould apply the transaction on every node: trans[%d] balance[%d]", failed, testID, len(block.Trans), account.Balance)
+			}
+			t.Logf("\t%s\tTest %d:\tShould apply the transaction on every node.", success, testID)
+		}
+	}
+}
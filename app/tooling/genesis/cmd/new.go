@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/genesis"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	chainID       uint16
+	difficulty    uint16
+	miningReward  uint64
+	gasPrice      uint64
+	transPerBlock uint16
+	blockInterval uint64
+	retargetWin   uint64
+	date          string
+	keysPath      string
+	keysBalance   uint64
+	csvPath       string
+	outPath       string
+	force         bool
+)
+
+var newCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Generate a new genesis file",
+	Long: `Generate a new genesis file from the chain settings and the initial balances.
+Balances are read from the key files of a directory, which all get the same
+balance, and from a CSV file of address,balance lines. The same flags and
+balances always produce the same file.`,
+	Run: newRun,
+}
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+	newCmd.Flags().Uint16VarP(&chainID, "chain-id", "c", 1, "Id of the chain.")
+	newCmd.Flags().Uint16VarP(&difficulty, "difficulty", "d", 6, "Difficulty of the proof of work.")
+	newCmd.Flags().Uint64VarP(&miningReward, "reward", "r", 700, "Reward for mining a block.")
+	newCmd.Flags().Uint64VarP(&gasPrice, "gas-price", "g", 15, "Fee paid for each transaction.")
+	newCmd.Flags().Uint16VarP(&transPerBlock, "trans-per-block", "t", 10, "Maximum number of transactions per block.")
+	newCmd.Flags().Uint64Var(&blockInterval, "block-interval", 0, "Target seconds between blocks the difficulty is retargeted toward.")
+	newCmd.Flags().Uint64Var(&retargetWin, "retarget-window", 0, "Blocks between difficulty adjustments, 0 keeps the difficulty fixed.")
+	newCmd.Flags().StringVar(&date, "date", "", "Date of the genesis in RFC3339, like 2021-12-17T00:00:00Z.")
+	newCmd.Flags().StringVarP(&keysPath, "keys", "k", "", "Directory with the key files of the accounts to fund.")
+	newCmd.Flags().Uint64VarP(&keysBalance, "balance", "b", 1000000, "Balance of every account read from the keys directory.")
+	newCmd.Flags().StringVar(&csvPath, "csv", "", "CSV file with address,balance lines of the accounts to fund.")
+	newCmd.Flags().StringVarP(&outPath, "out", "o", "genesis.json", "Path of the genesis file to write.")
+	newCmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the genesis file if it exists.")
+
+	// The date is part of the genesis hash, so a default like today would
+	// give the same flags a different chain every day.
+	newCmd.MarkFlagRequired("date")
+}
+
+func newRun(cmd *cobra.Command, args []string) {
+	genesisDate, err := getDate()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	balances := make(map[string]uint64)
+
+	if keysPath != "" {
+		if err := addKeyBalances(balances, keysPath, keysBalance); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if csvPath != "" {
+		if err := addCSVBalances(balances, csvPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(balances) == 0 {
+		log.Fatal("no balances, use --keys or --csv to fund accounts")
+	}
+
+	gen := genesis.Genesis{
+		Date:           genesisDate,
+		ChainID:        chainID,
+		TransPerBlock:  transPerBlock,
+		Difficulty:     difficulty,
+		MiningReward:   miningReward,
+		GasPrice:       gasPrice,
+		BlockInterval:  blockInterval,
+		RetargetWindow: retargetWin,
+		Balances:       balances,
+	}
+
+	if err := gen.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// The balances are a map which is encoded with its keys sorted so
+	// the file only depends on its content.
+	data, err := json.MarshalIndent(gen, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	data = append(data, '\n')
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(outPath, flags, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		log.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("genesis written to %s with %d accounts\n", outPath, len(balances))
+	fmt.Println("genesis hash:", gen.Hash())
+}
+
+// getDate returns the date of the genesis from the flag.
+func getDate() (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %w", err)
+	}
+
+	return t.UTC(), nil
+}
+
+// addKeyBalances funds the account of every key file in the directory with
+// the specified balance.
+func addKeyBalances(balances map[string]uint64, path string, balance uint64) error {
+	files, err := filepath.Glob(filepath.Join(path, "*"+keyExtension))
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return fmt.Errorf("no %s files in %s", keyExtension, path)
+	}
+
+	for _, file := range files {
+		privateKey, err := crypto.LoadECDSA(file)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", file, err)
+		}
+
+		accountID := database.PublicKeyToAccountID(privateKey.PublicKey)
+		if err := addBalance(balances, string(accountID), balance); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// addCSVBalances funds the accounts of the CSV file which holds address,
+// balance lines. A first line that doesn't start with an address is
+// skipped as the header.
+func addCSVBalances(balances map[string]uint64, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		account := strings.TrimSpace(record[0])
+		if _, err := database.ToAccountID(account); err != nil && line == 1 {
+			continue
+		}
+
+		balance, err := strconv.ParseUint(strings.TrimSpace(record[1]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s:%d: invalid balance %q", path, line, record[1])
+		}
+
+		if err := addBalance(balances, account, balance); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+	}
+}
+
+// addBalance validates the account and adds it with the balance. Accounts
+// are written with their checksum so the same account always looks the
+// same and can't be funded twice.
+func addBalance(balances map[string]uint64, account string, balance uint64) error {
+	if _, err := database.ToAccountID(account); err != nil {
+		return fmt.Errorf("account %q: %w", account, err)
+	}
+
+	account = common.HexToAddress(account).Hex()
+	if _, exists := balances[account]; exists {
+		return fmt.Errorf("account %s is funded twice", account)
+	}
+
+	balances[account] = balance
+	return nil
+}
@@ -0,0 +1,24 @@
+// Package cmd contains the genesis app
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	keyExtension = ".ecdsa"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "genesis",
+	Short: "Manage the genesis of a network",
+}
+
+func Execute() {
+	err := rootCmd.Execute()
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,7 @@
+package main
+
+import "github.com/bruno-sartori/go-blockchain/app/tooling/genesis/cmd"
+
+func main() {
+	cmd.Execute()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// levels maps the log levels to their severity.
+var levels = map[string]int{
+	"debug":  0,
+	"info":   1,
+	"warn":   2,
+	"error":  3,
+	"dpanic": 4,
+	"panic":  5,
+	"fatal":  6,
+}
+
+// filter decides which logs are shown based on the flags.
+type filter struct {
+	level   int
+	since   time.Time
+	until   time.Time
+	service string
+	traceID string
+	greps   matchers
+	exclude matchers
+}
+
+// newFilter constructs the filter from the flags.
+func newFilter() (filter, error) {
+	f := filter{
+		level:   -1,
+		service: service,
+		traceID: trace,
+		greps:   greps,
+		exclude: exclude,
+	}
+
+	if level != "" {
+		severity, ok := levels[strings.ToLower(level)]
+		if !ok {
+			return filter{}, fmt.Errorf("unknown level %q", level)
+		}
+		f.level = severity
+	}
+
+	var err error
+	if f.since, err = parseTime(since); err != nil {
+		return filter{}, fmt.Errorf("invalid since: %w", err)
+	}
+	if f.until, err = parseTime(until); err != nil {
+		return filter{}, fmt.Errorf("invalid until: %w", err)
+	}
+
+	return f, nil
+}
+
+// active reports whether anything is filtered.
+func (f filter) active() bool {
+	return f.level >= 0 || !f.since.IsZero() || !f.until.IsZero() ||
+		f.service != "" || f.traceID != "" || len(f.greps) > 0 || len(f.exclude) > 0
+}
+
+// match reports whether the log passes every filter.
+func (f filter) match(m map[string]any) bool {
+	if f.service != "" && m["service"] != f.service {
+		return false
+	}
+
+	if f.traceID != "" && m["traceid"] != f.traceID {
+		return false
+	}
+
+	if f.level >= 0 {
+		severity, ok := levels[fmt.Sprint(m["level"])]
+		if !ok || severity < f.level {
+			return false
+		}
+	}
+
+	if !f.since.IsZero() || !f.until.IsZero() {
+		ts, ok := logTime(m["ts"])
+		if !ok {
+			return false
+		}
+		if !f.since.IsZero() && ts.Before(f.since) {
+			return false
+		}
+		if !f.until.IsZero() && ts.After(f.until) {
+			return false
+		}
+	}
+
+	for _, mt := range f.greps {
+		if !mt.match(m) {
+			return false
+		}
+	}
+
+	for _, mt := range f.exclude {
+		if mt.match(m) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// timeLayouts are the layouts a time in a log or a flag can be written
+// in. Zap writes ISO8601 times with milliseconds and no colon in the zone.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.000Z0700",
+}
+
+// parseTime parses a time flag which is either a time in one of the time
+// layouts or a duration that is subtracted from now.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	return parseLayouts(s)
+}
+
+// logTime returns the time of a log which is either an ISO8601 string or
+// the seconds since the epoch.
+func logTime(v any) (time.Time, bool) {
+	switch ts := v.(type) {
+	case string:
+		t, err := parseLayouts(ts)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+
+	case float64:
+		sec := int64(ts)
+		return time.Unix(sec, int64((ts-float64(sec))*1e9)), true
+	}
+
+	return time.Time{}, false
+}
+
+// parseLayouts parses the time with the first time layout that fits.
+func parseLayouts(s string) (time.Time, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// =============================================================================
+
+// matcher matches a field of the log against a value.
+type matcher struct {
+	key   string
+	value string
+}
+
+// match reports whether the field of the log holds the value.
+func (mt matcher) match(m map[string]any) bool {
+	v, ok := m[mt.key]
+	if !ok {
+		return false
+	}
+	return fmt.Sprint(v) == mt.value
+}
+
+// matchers implements flag.Value so a key=value flag can be repeated.
+type matchers []matcher
+
+// String implements the flag.Value interface.
+func (ms *matchers) String() string {
+	pairs := make([]string, len(*ms))
+	for i, mt := range *ms {
+		pairs[i] = mt.key + "=" + mt.value
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set implements the flag.Value interface.
+func (ms *matchers) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	*ms = append(*ms, matcher{key: key, value: value})
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_LogTime(t *testing.T) {
+	exp := time.Date(2022, time.March, 4, 10, 20, 30, 123000000, time.UTC)
+
+	tt := []struct {
+		name    string
+		ts      any
+		success bool
+	}{
+		{"zap ISO8601 time", "2022-03-04T10:20:30.123Z", true},
+		{"zap ISO8601 time with an offset", "2022-03-04T07:20:30.123-0300", true},
+		{"RFC3339 time", "2022-03-04T07:20:30.123-03:00", true},
+		{"epoch time", float64(exp.UnixMilli()) / 1000, true},
+		{"time in an unknown layout", "04/03/2022 10:20:30", false},
+	}
+
+	t.Log("Given the need to read the time of a log.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					got, ok := logTime(tst.ts)
+					if ok != tst.success {
+						t.Fatalf("\t%s\tTest %d:\tShould parse as expected: got[%v] exp[%v]", failed, testID, ok, tst.success)
+					}
+					t.Logf("\t%s\tTest %d:\tShould parse as expected.", success, testID)
+
+					// Epoch times lose some precision in the float.
+					if diff := got.Sub(exp); ok && (diff > time.Millisecond || diff < -time.Millisecond) {
+						t.Fatalf("\t%s\tTest %d:\tShould get the time of the log: got[%v] exp[%v]", failed, testID, got, exp)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the time of the log.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_Match(t *testing.T) {
+	log := map[string]any{
+		"level":   "warn",
+		"ts":      "2022-03-04T10:20:30.123Z",
+		"service": "NODE",
+		"traceid": "abc",
+		"status":  "peer added",
+		"height":  float64(12),
+	}
+
+	tt := []struct {
+		name  string
+		f     filter
+		match bool
+	}{
+		{"filter on nothing", filter{level: -1}, true},
+		{"filter on the service", filter{level: -1, service: "NODE"}, true},
+		{"filter on another service", filter{level: -1, service: "WALLET"}, false},
+		{"filter on the trace id", filter{level: -1, traceID: "abc"}, true},
+		{"filter on another trace id", filter{level: -1, traceID: "xyz"}, false},
+		{"filter on a lower level", filter{level: levels["info"]}, true},
+		{"filter on the same level", filter{level: levels["warn"]}, true},
+		{"filter on a higher level", filter{level: levels["error"]}, false},
+		{"filter on a range holding the time", filter{level: -1, since: newTime(t, "2022-03-04T10:00:00Z"), until: newTime(t, "2022-03-04T11:00:00Z")}, true},
+		{"filter on a range before the time", filter{level: -1, until: newTime(t, "2022-03-04T10:00:00Z")}, false},
+		{"filter on a range after the time", filter{level: -1, since: newTime(t, "2022-03-04T11:00:00Z")}, false},
+		{"grep on a matching field", filter{level: -1, greps: matchers{{"status", "peer added"}, {"height", "12"}}}, true},
+		{"grep on a field with another value", filter{level: -1, greps: matchers{{"status", "peer added"}, {"height", "13"}}}, false},
+		{"grep on a missing field", filter{level: -1, greps: matchers{{"missing", ""}}}, false},
+		{"exclude on a matching field", filter{level: -1, exclude: matchers{{"status", "peer added"}}}, false},
+		{"exclude on a field with another value", filter{level: -1, exclude: matchers{{"status", "peer removed"}}}, true},
+	}
+
+	t.Log("Given the need to filter logs.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					if got := tst.f.match(log); got != tst.match {
+						t.Fatalf("\t%s\tTest %d:\tShould match as expected: got[%v] exp[%v]", failed, testID, got, tst.match)
+					}
+					t.Logf("\t%s\tTest %d:\tShould match as expected.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+// =============================================================================
+
+// newTime parses a time in one of the time layouts.
+func newTime(t *testing.T, s string) time.Time {
+	t.Helper()
+
+	ts, err := parseLayouts(s)
+	if err != nil {
+		t.Fatalf("parsing time: %s", err)
+	}
+
+	return ts
+}
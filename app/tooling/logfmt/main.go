@@ -2,82 +2,234 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
+	"time"
 )
 
-var service string
+var (
+	service string
+	level   string
+	trace   string
+	since   string
+	until   string
+	color   string
+	follow  bool
+	window  time.Duration
+	greps   matchers
+	exclude matchers
+)
 
 func init() {
 	flag.StringVar(&service, "service", "", "filter which service to see")
+	flag.StringVar(&level, "level", "", "minimum level to see: debug, info, warn, error")
+	flag.StringVar(&trace, "traceid", "", "filter which trace to see")
+	flag.StringVar(&since, "since", "", "hide logs before this time, RFC3339 or a duration ago like 10m")
+	flag.StringVar(&until, "until", "", "hide logs after this time, RFC3339 or a duration ago like 10m")
+	flag.StringVar(&color, "color", "auto", "color by level: auto, always, never")
+	flag.BoolVar(&follow, "follow", false, "keep reading the files as they grow")
+	flag.DurationVar(&window, "window", time.Second, "how long a log waits for the other files before it is shown")
+	flag.Var(&greps, "grep", "only see logs where key=value, can be repeated")
+	flag.Var(&exclude, "exclude", "hide logs where key=value, can be repeated")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [[name=]file ...]\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Reads standard input when no file is given. Logs of several files are")
+		fmt.Fprintln(flag.CommandLine.Output(), "merged by time and prefixed with the name of their file.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
 }
 
 func main() {
 	flag.Parse()
-	var b strings.Builder
 
-	// Scan standard input for log data per line.
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		s := scanner.Text()
+	f, err := newFilter()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
 
-		// Convert the JSON to a map for processing.
-		m := make(map[string]any)
-		err := json.Unmarshal([]byte(s), &m)
-		if err != nil {
-			if service == "" {
-				fmt.Println(s)
+	colored, err := useColor(color)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if window <= 0 {
+		fmt.Fprintln(os.Stderr, "window must be positive")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sources := parseSources(flag.Args())
+
+	// Only label the logs when they come from more than one place.
+	var width int
+	if len(sources) > 1 {
+		for _, src := range sources {
+			if len(src.name) > width {
+				width = len(src.name)
 			}
-			continue
 		}
+	}
 
-		// If a service filter was provided, check.
-		if service != "" && m["service"] != service {
-			continue
-		}
+	// Read every source on its own goroutine. The end of a source is sent
+	// on the same channel so it's seen after the last line of the source.
+	ch := make(chan entry, 1024)
+	for _, src := range sources {
+		go func(src source) {
+			if err := src.read(ch, follow); err != nil {
+				log.Println(err)
+			}
+			ch <- entry{source: src.index, eof: true}
+		}(src)
+	}
+
+	ticker := time.NewTicker(window / 4)
+	defer ticker.Stop()
+
+	m := newMerger(len(sources), window)
+	for !m.done() {
+		select {
+		case e := <-ch:
+			if e.eof {
+				m.close(e.source)
+				break
+			}
+			m.add(e)
 
-		// I like always having a traceid present in the logs.
-		traceID := "00000000-0000-0000-0000-000000000000"
-		if v, ok := m["traceid"]; ok {
-			traceID = fmt.Sprintf("%v", v)
+		case <-ticker.C:
 		}
 
-		// Build out the know portions of the log in the order
-		// I want them in.
-		b.Reset()
-		b.WriteString(fmt.Sprintf("%s: %s: %s: %s: %s: %s: ",
-			m["service"],
-			m["ts"],
-			m["level"],
-			traceID,
-			m["caller"],
-			m["msg"],
-		))
-
-		// Add the rest of the keys ignoring the ones we already
-		// added for the log.
-		for k, v := range m {
-			switch k {
-			case "service", "ts", "level", "traceid", "caller", "msg":
+		for _, e := range m.release(time.Now()) {
+
+			// Lines that are not logs are only shown when nothing
+			// is filtered.
+			if e.log == nil {
+				if !f.active() {
+					fmt.Println(prefix(sources[e.source].name, width) + e.line)
+				}
+				continue
+			}
+
+			if !f.match(e.log) {
 				continue
 			}
 
-			// It's nice to see the key[value] in this format
-			// especially since map ordering is random.
-			b.WriteString(fmt.Sprintf("%s[%v]: ", k, v))
+			out := format(e.log)
+			if colored {
+				out = colorize(fmt.Sprint(e.log["level"]), out)
+			}
+
+			fmt.Println(prefix(sources[e.source].name, width) + out)
 		}
+	}
+}
+
+// format returns the log in a readable form.
+func format(m map[string]any) string {
+	var b strings.Builder
+
+	// I like always having a traceid present in the logs.
+	traceID := "00000000000000000000000000000000"
+	if v, ok := m["traceid"]; ok {
+		traceID = fmt.Sprintf("%v", v)
+	}
+
+	// Build out the know portions of the log in the order
+	// I want them in.
+	b.WriteString(fmt.Sprintf("%s: %s: %s: %s: %s: %s: ",
+		m["service"],
+		m["ts"],
+		m["level"],
+		traceID,
+		m["caller"],
+		m["msg"],
+	))
+
+	// Add the rest of the keys ignoring the ones we already
+	// added for the log. They are sorted so the same log always
+	// reads the same way.
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		switch k {
+		case "service", "ts", "level", "traceid", "caller", "msg":
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		b.WriteString(fmt.Sprintf("%s[%v]: ", k, m[k]))
+	}
 
-		// Write the new log format, removing the last :
-		out := b.String()
-		fmt.Println(out[:len(out)-2])
+	// Return the new log format, removing the last :
+	out := b.String()
+	return out[:len(out)-2]
+}
+
+// prefix returns the label of the source padded to the width. There is no
+// label when the width is zero.
+func prefix(name string, width int) string {
+	if width == 0 {
+		return ""
 	}
+	return fmt.Sprintf("%-*s | ", width, name)
+}
+
+// =============================================================================
 
-	if err := scanner.Err(); err != nil {
-		log.Println(err)
+// Set of ANSI colors used for the levels.
+const (
+	colorReset  = "\x1b[0m"
+	colorGray   = "\x1b[90m"
+	colorYellow = "\x1b[33m"
+	colorRed    = "\x1b[31m"
+)
+
+// useColor decides if the output is colored. In auto mode the output is
+// only colored when it goes to a terminal.
+func useColor(mode string) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		fi, err := os.Stdout.Stat()
+		if err != nil {
+			return false, nil
+		}
+		return fi.Mode()&os.ModeCharDevice != 0, nil
+	}
+
+	return false, fmt.Errorf("unknown color mode %q", mode)
+}
+
+// colorize wraps the line in the color of the level. Info logs keep the
+// color of the terminal.
+func colorize(level string, line string) string {
+	var c string
+	switch level {
+	case "debug":
+		c = colorGray
+	case "info":
+		return line
+	case "warn":
+		c = colorYellow
+	case "error", "dpanic", "panic", "fatal":
+		c = colorRed
+	default:
+		return line
 	}
+
+	return c + line + colorReset
 }
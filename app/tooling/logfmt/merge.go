@@ -0,0 +1,86 @@
+package main
+
+import (
+	"time"
+)
+
+// merger interleaves the entries of several sources into a single stream
+// ordered by time. An entry is only released once every source still open
+// has an entry waiting, so nothing older can show up after it. Sources that
+// are quiet would hold everything back, so an entry that waited longer than
+// the window is released anyway.
+type merger struct {
+	window time.Duration
+	queues [][]entry
+	open   []bool
+}
+
+// newMerger constructs a merger for the specified number of sources.
+func newMerger(sources int, window time.Duration) *merger {
+	m := merger{
+		window: window,
+		queues: make([][]entry, sources),
+		open:   make([]bool, sources),
+	}
+
+	for i := range m.open {
+		m.open[i] = true
+	}
+
+	return &m
+}
+
+// add queues an entry read from its source.
+func (m *merger) add(e entry) {
+	m.queues[e.source] = append(m.queues[e.source], e)
+}
+
+// close marks the source as done so it doesn't hold back the others.
+func (m *merger) close(source int) {
+	m.open[source] = false
+}
+
+// done reports whether every source is closed and every entry released.
+func (m *merger) done() bool {
+	for i := range m.queues {
+		if m.open[i] || len(m.queues[i]) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// release returns the entries that can be written in the order they
+// should be written.
+func (m *merger) release(now time.Time) []entry {
+	var entries []entry
+
+	for {
+		next := -1
+		waiting := false
+		for i, q := range m.queues {
+			if len(q) == 0 {
+				if m.open[i] {
+					waiting = true
+				}
+				continue
+			}
+
+			if next == -1 || q[0].ts.Before(m.queues[next][0].ts) {
+				next = i
+			}
+		}
+
+		if next == -1 {
+			return entries
+		}
+
+		e := m.queues[next][0]
+		if waiting && now.Sub(e.arrived) < m.window {
+			return entries
+		}
+
+		m.queues[next] = m.queues[next][1:]
+		entries = append(entries, e)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Release(t *testing.T) {
+	now := time.Date(2022, time.March, 4, 10, 20, 30, 0, time.UTC)
+	window := time.Second
+
+	// newEntry constructs an entry of the source logged at the offset from
+	// now, which arrived at the offset from now.
+	newEntry := func(source int, ts time.Duration, arrived time.Duration) entry {
+		return entry{source: source, ts: now.Add(ts), arrived: now.Add(arrived), line: ts.String()}
+	}
+
+	tt := []struct {
+		name    string
+		entries []entry
+		closed  []int
+		exp     []string
+	}{
+		{
+			"set of sources with waiting entries",
+			[]entry{newEntry(0, 1*time.Second, 0), newEntry(0, 3*time.Second, 0), newEntry(1, 2*time.Second, 0)},
+			nil,
+			[]string{"1s", "2s"},
+		},
+		{
+			"quiet source within the window",
+			[]entry{newEntry(0, 1*time.Second, 0)},
+			nil,
+			nil,
+		},
+		{
+			"quiet source past the window",
+			[]entry{newEntry(0, 1*time.Second, -2*window)},
+			nil,
+			[]string{"1s"},
+		},
+		{
+			"closed source",
+			[]entry{newEntry(0, 1*time.Second, 0), newEntry(0, 2*time.Second, 0)},
+			[]int{1},
+			[]string{"1s", "2s"},
+		},
+		{
+			"set of closed sources",
+			[]entry{newEntry(0, 3*time.Second, 0), newEntry(1, 1*time.Second, 0), newEntry(1, 2*time.Second, 0)},
+			[]int{0, 1},
+			[]string{"1s", "2s", "3s"},
+		},
+	}
+
+	t.Log("Given the need to merge the logs of several sources by time.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					m := newMerger(2, window)
+					for _, e := range tst.entries {
+						m.add(e)
+					}
+					for _, source := range tst.closed {
+						m.close(source)
+					}
+
+					var got []string
+					for _, e := range m.release(now) {
+						got = append(got, e.line)
+					}
+
+					if len(got) != len(tst.exp) {
+						t.Fatalf("\t%s\tTest %d:\tShould release the expected entries: got%v exp%v", failed, testID, got, tst.exp)
+					}
+					for i := range got {
+						if got[i] != tst.exp[i] {
+							t.Fatalf("\t%s\tTest %d:\tShould release the expected entries: got%v exp%v", failed, testID, got, tst.exp)
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould release the expected entries.", success, testID)
+
+					if done := len(tst.closed) == 2; m.done() != done {
+						t.Fatalf("\t%s\tTest %d:\tShould be done once every source is closed and released: got[%v] exp[%v]", failed, testID, m.done(), done)
+					}
+					t.Logf("\t%s\tTest %d:\tShould be done once every source is closed and released.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pollInterval is how often a followed source is checked for new data once
+// everything written so far was read.
+const pollInterval = 200 * time.Millisecond
+
+// entry represents a single line read from a source. The last entry of a
+// source is marked with eof and holds no line.
+type entry struct {
+	source  int
+	ts      time.Time
+	line    string
+	log     map[string]any
+	arrived time.Time
+	eof     bool
+}
+
+// source represents an input the logs are read from.
+type source struct {
+	index int
+	name  string
+	path  string
+}
+
+// parseSources parses the arguments into sources. An argument is a path or
+// name=path when the logs should be labeled with something else than the
+// name of the file. Standard input is read when there are no arguments.
+func parseSources(args []string) []source {
+	if len(args) == 0 {
+		return []source{{name: "stdin"}}
+	}
+
+	sources := make([]source, len(args))
+	for i, arg := range args {
+		name, path, ok := strings.Cut(arg, "=")
+		if !ok {
+			path = arg
+			name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		sources[i] = source{index: i, name: name, path: path}
+	}
+
+	return sources
+}
+
+// read sends every line of the source to the channel. When follow is set,
+// reaching the end of a file waits for more data instead of returning,
+// which also applies to named pipes whose writers went away. A line is only
+// sent once its newline was read, so a partial line at the end of a growing
+// file waits for the rest of it.
+func (src source) read(ch chan<- entry, follow bool) error {
+	in := os.Stdin
+	if src.path != "" {
+		f, err := os.Open(src.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	r := bufio.NewReader(in)
+
+	var partial string
+	var offset int64
+	var last time.Time
+	for {
+		s, err := r.ReadString('\n')
+		offset += int64(len(s))
+		partial += s
+
+		switch {
+		case err == nil:
+			last = src.send(ch, strings.TrimSuffix(strings.TrimSuffix(partial, "\n"), "\r"), last)
+			partial = ""
+			continue
+
+		case !errors.Is(err, io.EOF):
+			return fmt.Errorf("reading %s: %w", src.name, err)
+
+		case !follow || src.path == "":
+			if partial != "" {
+				src.send(ch, partial, last)
+			}
+			return nil
+		}
+
+		// A file that is smaller than what was read was truncated, like
+		// when the node was restarted, so start over from the beginning.
+		if fi, err := in.Stat(); err == nil && fi.Mode().IsRegular() && fi.Size() < offset {
+			if _, err := in.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("rewinding %s: %w", src.name, err)
+			}
+			r.Reset(in)
+			partial = ""
+			offset = 0
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+// send parses the line and sends it to the channel. Lines without a time
+// take the time of the line before them so they stay in place. The time
+// of the line is returned.
+func (src source) send(ch chan<- entry, line string, last time.Time) time.Time {
+	e := entry{
+		source:  src.index,
+		ts:      last,
+		line:    line,
+		arrived: time.Now(),
+	}
+
+	m := make(map[string]any)
+	if err := json.Unmarshal([]byte(line), &m); err == nil {
+		e.log = m
+		if ts, ok := logTime(m["ts"]); ok {
+			e.ts = ts
+		}
+	}
+
+	ch <- e
+	return e.ts
+}
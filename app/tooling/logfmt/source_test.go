@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Read(t *testing.T) {
+	tt := []struct {
+		name string
+		data string
+		exp  []string
+		ts   []int64
+	}{
+		{"set of complete lines", "{\"ts\":1}\n{\"ts\":2}\r\n", []string{`{"ts":1}`, `{"ts":2}`}, []int64{1, 2}},
+		{"set of lines that are not logs", "{\"ts\":1}\npanic\n", []string{`{"ts":1}`, `panic`}, []int64{1, 1}},
+		{"file ending in a partial line", "{\"ts\":1}\n{\"ts\":", []string{`{"ts":1}`, `{"ts":`}, []int64{1, 1}},
+	}
+
+	t.Log("Given the need to read the logs of a file.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					path := filepath.Join(t.TempDir(), "node.log")
+					if err := os.WriteFile(path, []byte(tst.data), 0600); err != nil {
+						t.Fatalf("writing file: %s", err)
+					}
+
+					ch := make(chan entry, len(tst.exp)+1)
+					src := source{index: 1, name: "node", path: path}
+					if err := src.read(ch, false); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould read the file: %s", failed, testID, err)
+					}
+					close(ch)
+
+					var entries []entry
+					for e := range ch {
+						entries = append(entries, e)
+					}
+
+					if len(entries) != len(tst.exp) {
+						t.Fatalf("\t%s\tTest %d:\tShould read every line: got[%d] exp[%d]", failed, testID, len(entries), len(tst.exp))
+					}
+					for i, e := range entries {
+						if e.line != tst.exp[i] || e.source != src.index {
+							t.Fatalf("\t%s\tTest %d:\tShould read every line: got[%q] exp[%q]", failed, testID, e.line, tst.exp[i])
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould read every line.", success, testID)
+
+					// A line that isn't a log keeps the time of the line
+					// before it so it stays in place.
+					for i, e := range entries {
+						if e.ts.Unix() != tst.ts[i] {
+							t.Fatalf("\t%s\tTest %d:\tShould get the time of line %d: got[%d] exp[%d]", failed, testID, i, e.ts.Unix(), tst.ts[i])
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the time of every line.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
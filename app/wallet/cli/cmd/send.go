@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	nodeURL string
+	chainID uint16
+	nonce   uint64
+	to      string
+	value   uint64
+	tip     string
+	data    []byte
+)
+
+var sendCmd = &cobra.Command{
+	Use:   "send",
+	Short: "Send transaction",
+	Run:   sendRun,
+}
+
+func init() {
+	rootCmd.AddCommand(sendCmd)
+	sendCmd.Flags().StringVarP(&nodeURL, "url", "u", "http://localhost:8080", "Url of the node.")
+	sendCmd.Flags().Uint16VarP(&chainID, "chain-id", "c", 1, "Id of the chain.")
+	sendCmd.Flags().Uint64VarP(&nonce, "nonce", "n", 0, "Id for the transaction.")
+	sendCmd.Flags().StringVarP(&to, "to", "t", "", "Account receiving the value.")
+	sendCmd.Flags().Uint64VarP(&value, "value", "v", 0, "Value to send.")
+	sendCmd.Flags().StringVarP(&tip, "tip", "i", "0", "Tip to send, or auto to use the tip suggested by the node.")
+	sendCmd.Flags().BytesHexVarP(&data, "data", "d", nil, "Data to send.")
+}
+
+func sendRun(cmd *cobra.Command, args []string) {
+	privateKey, err := crypto.LoadECDSA(getPrivateKeyPath())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	txTip, err := getTip()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fromAccount := database.PublicKeyToAccountID(privateKey.PublicKey)
+	toAccount, err := database.ToAccountID(to)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tx, err := database.NewTx(chainID, nonce, fromAccount, toAccount, value, txTip, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	signedTx, err := tx.Sign(privateKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	body, err := json.Marshal(signedTx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%s/v1/tx/submit", nodeURL), "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("%s: %v", resp.Status, result["error"])
+	}
+
+	fmt.Println(result["hash"])
+}
+
+// getTip returns the tip provided on the command line. The auto tip is the
+// medium tip suggested by the node.
+func getTip() (uint64, error) {
+	if tip != "auto" {
+		return strconv.ParseUint(tip, 10, 64)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/v1/fees/estimate", nodeURL))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("estimating fees: %s", resp.Status)
+	}
+
+	var estimate struct {
+		GasPrice uint64 `json:"gas_price"`
+		Medium   uint64 `json:"medium"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&estimate); err != nil {
+		return 0, err
+	}
+
+	log.Printf("auto tip: %d, gas price: %d", estimate.Medium, estimate.GasPrice)
+
+	return estimate.Medium, nil
+}
@@ -0,0 +1,303 @@
+// Package auth provides support for signing and verifying node to node
+// requests with the node's identity key.
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/signature"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Set of headers used to carry the signature of a node to node request.
+const (
+	HeaderHost      = "X-Node-Host"
+	HeaderTimestamp = "X-Node-Timestamp"
+	HeaderNonce     = "X-Node-Nonce"
+	HeaderSignature = "X-Node-Signature"
+)
+
+// Set of errors returned for requests that are valid but can't be accepted.
+// Together they block a signed request from being replayed.
+var (
+	ErrStale    = errors.New("request timestamp is stale")
+	ErrReplayed = errors.New("request was already received")
+)
+
+// Node represents the identity of the node that signed a request.
+type Node struct {
+	AccountID database.AccountID
+	Host      string
+}
+
+// payload represents the data that is signed for every request.
+type payload struct {
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	BodyHash  string `json:"body_hash"`
+	Host      string `json:"host"`
+	Timestamp int64  `json:"timestamp"`
+	Nonce     string `json:"nonce"`
+}
+
+// Sign signs the request with the specified private key and sets the
+// signature headers on the request. The host is the private API host of
+// the sending node so the receiver can attribute the request to a peer.
+// Every request gets a random nonce so two identical requests sent in the
+// same second are still told apart from a replay.
+func Sign(r *http.Request, privateKey *ecdsa.PrivateKey, host string) error {
+	bodyHash, err := hashBody(r)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generating nonce: %w", err)
+	}
+
+	p := payload{
+		Method:    r.Method,
+		Path:      r.URL.RequestURI(),
+		BodyHash:  bodyHash,
+		Host:      host,
+		Timestamp: time.Now().UTC().Unix(),
+		Nonce:     hexutil.Encode(nonce),
+	}
+
+	v, rs, s, err := signature.Sign(p, privateKey)
+	if err != nil {
+		return fmt.Errorf("signing request: %w", err)
+	}
+
+	r.Header.Set(HeaderHost, p.Host)
+	r.Header.Set(HeaderTimestamp, strconv.FormatInt(p.Timestamp, 10))
+	r.Header.Set(HeaderNonce, p.Nonce)
+	r.Header.Set(HeaderSignature, signature.SignatureString(v, rs, s))
+
+	return nil
+}
+
+// Verifier validates the signature of node to node requests. It remembers
+// the requests it accepted until they are stale so every signed request is
+// only accepted once.
+type Verifier struct {
+	maxAge time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewVerifier constructs a verifier that rejects requests with a timestamp
+// further than maxAge from now.
+func NewVerifier(maxAge time.Duration) *Verifier {
+	return &Verifier{
+		maxAge: maxAge,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Verify validates the signature headers on the request and returns the
+// identity of the node that signed it. A request that was already accepted
+// is rejected with ErrReplayed.
+func (vf *Verifier) Verify(r *http.Request, now time.Time) (Node, error) {
+	sigStr := r.Header.Get(HeaderSignature)
+	if sigStr == "" {
+		return Node{}, errors.New("missing request signature")
+	}
+
+	timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return Node{}, errors.New("missing or invalid request timestamp")
+	}
+
+	signedAt := time.Unix(timestamp, 0)
+	age := now.Sub(signedAt)
+	if age > vf.maxAge || age < -vf.maxAge {
+		return Node{}, ErrStale
+	}
+
+	bodyHash, err := hashBody(r)
+	if err != nil {
+		return Node{}, err
+	}
+
+	p := payload{
+		Method:    r.Method,
+		Path:      r.URL.RequestURI(),
+		BodyHash:  bodyHash,
+		Host:      r.Header.Get(HeaderHost),
+		Timestamp: timestamp,
+		Nonce:     r.Header.Get(HeaderNonce),
+	}
+
+	v, rs, s, err := signature.ToVRSFromHexSignature(sigStr)
+	if err != nil {
+		return Node{}, fmt.Errorf("invalid request signature: %w", err)
+	}
+
+	if err := signature.VerifySignature(v, rs, s); err != nil {
+		return Node{}, err
+	}
+
+	address, err := signature.FromAddress(p, v, rs, s)
+	if err != nil {
+		return Node{}, err
+	}
+
+	accountID, err := database.ToAccountID(address)
+	if err != nil {
+		return Node{}, err
+	}
+
+	// The same payload can be signed in more than one valid way, so the
+	// request is remembered by what was signed rather than the signature.
+	if err := vf.accept(signature.Hash(p), signedAt, now); err != nil {
+		return Node{}, err
+	}
+
+	node := Node{
+		AccountID: accountID,
+		Host:      p.Host,
+	}
+
+	return node, nil
+}
+
+// accept records the request with the specified id. It fails if the id was
+// already recorded. Requests are forgotten once they are stale since Verify
+// rejects them from then on.
+func (vf *Verifier) accept(id string, signedAt time.Time, now time.Time) error {
+	vf.mu.Lock()
+	defer vf.mu.Unlock()
+
+	for seenID, expires := range vf.seen {
+		if now.After(expires) {
+			delete(vf.seen, seenID)
+		}
+	}
+
+	if _, exists := vf.seen[id]; exists {
+		return ErrReplayed
+	}
+
+	vf.seen[id] = signedAt.Add(vf.maxAge)
+	return nil
+}
+
+// =============================================================================
+
+// reply represents the data that is signed for a response.
+type reply struct {
+	Nonce    string `json:"nonce"`
+	DataHash string `json:"data_hash"`
+}
+
+// SignReply signs the data a node responds with to the request carrying
+// the specified nonce. Binding the reply to the nonce lets the requesting
+// node verify who answered and that the answer isn't an old one.
+func SignReply(data any, nonce string, privateKey *ecdsa.PrivateKey) (string, error) {
+	if nonce == "" {
+		return "", errors.New("missing request nonce")
+	}
+
+	p := reply{
+		Nonce:    nonce,
+		DataHash: signature.Hash(data),
+	}
+
+	v, rs, s, err := signature.Sign(p, privateKey)
+	if err != nil {
+		return "", fmt.Errorf("signing reply: %w", err)
+	}
+
+	return signature.SignatureString(v, rs, s), nil
+}
+
+// VerifyReply validates the signature of the data received in reply to the
+// request carrying the specified nonce and returns the account that signed
+// it.
+func VerifyReply(data any, nonce string, sigStr string) (database.AccountID, error) {
+	if sigStr == "" {
+		return "", errors.New("missing reply signature")
+	}
+
+	p := reply{
+		Nonce:    nonce,
+		DataHash: signature.Hash(data),
+	}
+
+	v, rs, s, err := signature.ToVRSFromHexSignature(sigStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid reply signature: %w", err)
+	}
+
+	if err := signature.VerifySignature(v, rs, s); err != nil {
+		return "", err
+	}
+
+	address, err := signature.FromAddress(p, v, rs, s)
+	if err != nil {
+		return "", err
+	}
+
+	return database.ToAccountID(address)
+}
+
+// =============================================================================
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// key is used to store/retrieve the verified node from a context.Context.
+const key ctxKey = 1
+
+// SetNode stores the verified node that signed the request in the context.
+func SetNode(ctx context.Context, node Node) context.Context {
+	return context.WithValue(ctx, key, node)
+}
+
+// GetNode returns the verified node that signed the request.
+func GetNode(ctx context.Context) (Node, error) {
+	node, ok := ctx.Value(key).(Node)
+	if !ok {
+		return Node{}, errors.New("node value missing from context")
+	}
+	return node, nil
+}
+
+// =============================================================================
+
+// hashBody returns the hex encoded sha256 of the request body. The body is
+// replaced so it can still be consumed by the caller.
+func hashBody(r *http.Request) (string, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			return "", fmt.Errorf("reading body: %w", err)
+		}
+		r.Body.Close()
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	hash := sha256.Sum256(body)
+	return hexutil.Encode(hash[:]), nil
+}
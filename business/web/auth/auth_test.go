@@ -0,0 +1,222 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/business/web/auth"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/signature"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+const host = "0.0.0.0:9080"
+
+func Test_Verify(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+	accountID := database.PublicKeyToAccountID(privateKey.PublicKey)
+
+	// A signature checked against a payload that was changed recovers some
+	// other account, which the caller rejects as not allowed.
+	tt := []struct {
+		name   string
+		change func(r *http.Request)
+		now    time.Duration
+		err    error
+		signer bool
+	}{
+		{"signed request", func(r *http.Request) {}, 0, nil, true},
+		{"clock slightly ahead", func(r *http.Request) {}, 20 * time.Second, nil, true},
+		{"stale request", func(r *http.Request) {}, time.Minute, auth.ErrStale, false},
+		{"request from the future", func(r *http.Request) {}, -time.Minute, auth.ErrStale, false},
+		{"changed path", func(r *http.Request) { r.URL.Path = "/v1/node/other" }, 0, nil, false},
+		{"changed host", func(r *http.Request) { r.Header.Set(auth.HeaderHost, "0.0.0.0:9999") }, 0, nil, false},
+		{"changed nonce", func(r *http.Request) { r.Header.Set(auth.HeaderNonce, "0x00") }, 0, nil, false},
+		{"changed body", func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader(`{"chain_id":2}`)) }, 0, nil, false},
+		{"missing signature", func(r *http.Request) { r.Header.Del(auth.HeaderSignature) }, 0, errAny, false},
+	}
+
+	t.Log("Given the need to verify signed node requests.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					r := newRequest(t, privateKey)
+					tst.change(r)
+
+					verifier := auth.NewVerifier(30 * time.Second)
+					node, err := verifier.Verify(r, time.Now().Add(tst.now))
+
+					switch {
+					case tst.err == nil && err != nil:
+						t.Fatalf("\t%s\tTest %d:\tShould verify the signature: %s", failed, testID, err)
+					case tst.err == errAny && err == nil:
+						t.Fatalf("\t%s\tTest %d:\tShould reject the request.", failed, testID)
+					case tst.err != nil && tst.err != errAny && !errors.Is(err, tst.err):
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected error: got[%v] exp[%v]", failed, testID, err, tst.err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected error.", success, testID)
+
+					if signer := err == nil && node.AccountID == accountID; signer != tst.signer {
+						t.Fatalf("\t%s\tTest %d:\tShould identify the signer only when untouched: got[%s] exp[%s]", failed, testID, node.AccountID, accountID)
+					}
+					t.Logf("\t%s\tTest %d:\tShould identify the signer only when untouched.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_Replay(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+
+	tt := []struct {
+		name   string
+		replay func(r *http.Request)
+	}{
+		{"same signature", func(r *http.Request) {}},
+		{"malleated signature", malleate},
+	}
+
+	t.Log("Given the need to reject replayed node requests.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a request replayed with the %s.", testID, tst.name)
+				{
+					verifier := auth.NewVerifier(30 * time.Second)
+					now := time.Now()
+
+					r := newRequest(t, privateKey)
+					if _, err := verifier.Verify(r, now); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould verify the first request: %s", failed, testID, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould verify the first request.", success, testID)
+
+					replay := r.Clone(r.Context())
+					replay.Body, _ = r.GetBody()
+					tst.replay(replay)
+
+					if _, err := verifier.Verify(replay, now.Add(time.Second)); !errors.Is(err, auth.ErrReplayed) {
+						t.Fatalf("\t%s\tTest %d:\tShould reject the replay: got[%v] exp[%v]", failed, testID, err, auth.ErrReplayed)
+					}
+					t.Logf("\t%s\tTest %d:\tShould reject the replay.", success, testID)
+
+					other := newRequest(t, privateKey)
+					if _, err := verifier.Verify(other, now.Add(time.Second)); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould verify an identical new request: %s", failed, testID, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould verify an identical new request.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_Reply(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+	accountID := database.PublicKeyToAccountID(privateKey.PublicKey)
+
+	data := struct{ Height uint64 }{Height: 10}
+	sig, err := auth.SignReply(data, "0x01", privateKey)
+	if err != nil {
+		t.Fatalf("signing reply: %s", err)
+	}
+
+	tt := []struct {
+		name   string
+		data   any
+		nonce  string
+		signer bool
+	}{
+		{"signed reply", data, "0x01", true},
+		{"reply to another request", data, "0x02", false},
+		{"changed reply", struct{ Height uint64 }{Height: 11}, "0x01", false},
+	}
+
+	t.Log("Given the need to verify who signed a reply.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					got, err := auth.VerifyReply(tst.data, tst.nonce, sig)
+					if err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould recover an account: %s", failed, testID, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould recover an account.", success, testID)
+
+					if signer := got == accountID; signer != tst.signer {
+						t.Fatalf("\t%s\tTest %d:\tShould identify the signer only when untouched: got[%s] exp[%s]", failed, testID, got, accountID)
+					}
+					t.Logf("\t%s\tTest %d:\tShould identify the signer only when untouched.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+// =============================================================================
+
+// errAny marks a test case that must fail without checking the error.
+var errAny = errors.New("any error")
+
+// newRequest constructs a signed handshake request.
+func newRequest(t *testing.T, privateKey *ecdsa.PrivateKey) *http.Request {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "http://"+host+"/v1/node/handshake", strings.NewReader(`{"chain_id":1}`))
+
+	if err := auth.Sign(r, privateKey, host); err != nil {
+		t.Fatalf("signing request: %s", err)
+	}
+
+	return r
+}
+
+// malleate replaces the signature with the other valid signature of the
+// same payload, which has s negated and the recovery id flipped.
+func malleate(r *http.Request) {
+	v, rs, s, err := signature.ToVRSFromHexSignature(r.Header.Get(auth.HeaderSignature))
+	if err != nil {
+		return
+	}
+
+	s = new(big.Int).Sub(crypto.S256().Params().N, s)
+	if v.Uint64()%2 == 0 {
+		v = new(big.Int).Sub(v, big.NewInt(1))
+	} else {
+		v = new(big.Int).Add(v, big.NewInt(1))
+	}
+
+	r.Header.Set(auth.HeaderSignature, signature.SignatureString(v, rs, s))
+}
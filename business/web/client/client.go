@@ -0,0 +1,151 @@
+// Package client provides support for sending signed requests to the
+// private API of other nodes.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/business/web/auth"
+	v1Web "github.com/bruno-sartori/go-blockchain/business/web/v1"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
+	"github.com/bruno-sartori/go-blockchain/foundation/web"
+)
+
+// Client signs every request with the node's identity key before sending
+// it to a peer.
+type Client struct {
+	host       string
+	privateKey *ecdsa.PrivateKey
+	http       *http.Client
+}
+
+// New constructs a client for the node listening on the specified private
+// host and identified by the private key.
+func New(host string, privateKey *ecdsa.PrivateKey, timeout time.Duration) *Client {
+	return &Client{
+		host:       host,
+		privateKey: privateKey,
+		http: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// Handshake sends this node's handshake to the specified peer and returns
+// the handshake the peer responds with along with the account that signed
+// the response.
+func (c *Client) Handshake(ctx context.Context, pr peer.Peer, hs peer.Handshake) (peer.Handshake, database.AccountID, error) {
+	url := fmt.Sprintf("http://%s/v1/node/handshake", pr.Host)
+
+	var remote peer.Handshake
+	nonce, err := c.do(ctx, http.MethodPost, url, hs, &remote)
+	if err != nil {
+		return peer.Handshake{}, "", err
+	}
+
+	sig := remote.Signature
+	remote.Signature = ""
+
+	accountID, err := auth.VerifyReply(remote, nonce, sig)
+	if err != nil {
+		return peer.Handshake{}, "", fmt.Errorf("handshake reply: %w", err)
+	}
+
+	return remote, accountID, nil
+}
+
+// Headers returns up to limit headers of the chain of the peer starting at
+// the specified block number.
+func (c *Client) Headers(ctx context.Context, pr peer.Peer, from uint64, limit int) ([]database.BlockHeader, error) {
+	url := fmt.Sprintf("http://%s/v1/node/headers/%d?limit=%d", pr.Host, from, limit)
+
+	var headers []database.BlockHeader
+	if err := c.Do(ctx, http.MethodGet, url, nil, &headers); err != nil {
+		return nil, err
+	}
+
+	return headers, nil
+}
+
+// Blocks returns up to limit blocks of the chain of the peer starting at
+// the specified block number.
+func (c *Client) Blocks(ctx context.Context, pr peer.Peer, from uint64, limit int) ([]database.Block, error) {
+	url := fmt.Sprintf("http://%s/v1/node/blocks/%d?limit=%d", pr.Host, from, limit)
+
+	var blocks []database.Block
+	if err := c.Do(ctx, http.MethodGet, url, nil, &blocks); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// Do sends a signed request to the specified url. The body is marshaled as
+// JSON when provided and the response is decoded into result when provided.
+func (c *Client) Do(ctx context.Context, method string, url string, body any, result any) error {
+	_, err := c.do(ctx, method, url, body, result)
+	return err
+}
+
+// do sends the signed request and returns the nonce it was signed with so
+// a signed reply can be verified.
+func (c *Client) do(ctx context.Context, method string, url string, body any, result any) (string, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return "", fmt.Errorf("marshaling body: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Continue the trace of the request being handled, if any.
+	if tp := web.GetTraceParent(ctx); tp != "" {
+		req.Header.Set(tracing.Header, tp)
+	}
+
+	if err := auth.Sign(req, c.privateKey, c.host); err != nil {
+		return "", err
+	}
+
+	nonce := req.Header.Get(auth.HeaderNonce)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		var er v1Web.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&er); err != nil {
+			return "", fmt.Errorf("%s %s: status[%d]", method, url, resp.StatusCode)
+		}
+		return "", fmt.Errorf("%s %s: status[%d]: %s", method, url, resp.StatusCode, er.Error)
+	}
+
+	if result == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nonce, nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return "", fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nonce, nil
+}
@@ -0,0 +1,80 @@
+// Package jsonrpc represents types used by the web application for the
+// JSON-RPC 2.0 api.
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Version is the only version of the protocol that is supported.
+const Version = "2.0"
+
+// Set of error codes defined by the JSON-RPC 2.0 specification and the
+// Ethereum JSON-RPC api.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeServerError    = -32000
+	CodeLimitExceeded  = -32005
+)
+
+// Request is the form used for a single JSON-RPC call. A request without an
+// id is a notification and receives no response.
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// IsNotification reports whether the caller expects no response.
+func (r Request) IsNotification() bool {
+	return r.ID == nil
+}
+
+// Response is the form used for the response of a single JSON-RPC call.
+// Exactly one of Result or Error is set.
+type Response struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// Error is the form used for failures of a JSON-RPC call.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewError constructs an error for the specified code.
+func NewError(code int, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// IsError checks if an error of type Error exists.
+func IsError(err error) bool {
+	var e *Error
+	return errors.As(err, &e)
+}
+
+// GetError returns a copy of the Error pointer.
+func GetError(err error) *Error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return nil
+	}
+	return e
+}
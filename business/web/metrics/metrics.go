@@ -22,6 +22,7 @@ type metrics struct {
 	requests   *expvar.Int
 	errors     *expvar.Int
 	panics     *expvar.Int
+	throttled  *expvar.Int
 }
 
 // init constructs the metrics value that will be used to capture metrics.
@@ -34,6 +35,7 @@ func init() {
 		requests:   expvar.NewInt("requests"),
 		errors:     expvar.NewInt("errors"),
 		panics:     expvar.NewInt("panics"),
+		throttled:  expvar.NewInt("throttled"),
 	}
 }
 
@@ -87,3 +89,10 @@ func AddPanics(ctx context.Context) {
 		v.panics.Add(1)
 	}
 }
+
+// AddThrottled increments the throttled metric by 1.
+func AddThrottled(ctx context.Context) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		v.throttled.Add(1)
+	}
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/prometheus"
+)
+
+// latencyBuckets are the upper bounds in seconds of the request latency
+// histogram.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// routeKey identifies a route of the application.
+type routeKey struct {
+	method string
+	route  string
+}
+
+// routeStats holds the metrics of a single route.
+type routeStats struct {
+	codes   map[int]uint64
+	latency *prometheus.Histogram
+}
+
+// routes holds the metrics of every route that received a request.
+var routes = struct {
+	mu    sync.Mutex
+	stats map[routeKey]*routeStats
+}{
+	stats: make(map[routeKey]*routeStats),
+}
+
+// AddRoute records a completed request for the route. The route is the path
+// pattern the handler was registered with so the number of series stays
+// bounded.
+func AddRoute(method string, route string, statusCode int, latency time.Duration) {
+	routes.mu.Lock()
+	defer routes.mu.Unlock()
+
+	key := routeKey{method: method, route: route}
+
+	rs, exists := routes.stats[key]
+	if !exists {
+		rs = &routeStats{
+			codes:   make(map[int]uint64),
+			latency: prometheus.NewHistogram(latencyBuckets),
+		}
+		routes.stats[key] = rs
+	}
+
+	rs.codes[statusCode]++
+	rs.latency.Observe(latency.Seconds())
+}
+
+// WritePrometheus writes the HTTP and runtime metrics, which include every
+// metric published to expvar by this package.
+func WritePrometheus(pw *prometheus.Writer) {
+	pw.Header("http_requests_total", "Number of requests handled.", prometheus.TypeCounter)
+	pw.Sample("http_requests_total", nil, float64(m.requests.Value()))
+
+	pw.Header("http_errors_total", "Number of requests that failed with an error.", prometheus.TypeCounter)
+	pw.Sample("http_errors_total", nil, float64(m.errors.Value()))
+
+	pw.Header("http_panics_total", "Number of requests that panicked.", prometheus.TypeCounter)
+	pw.Sample("http_panics_total", nil, float64(m.panics.Value()))
+
+	pw.Header("http_throttled_total", "Number of requests refused by a rate limit.", prometheus.TypeCounter)
+	pw.Sample("http_throttled_total", nil, float64(m.throttled.Value()))
+
+	writeRoutes(pw)
+	writeRuntime(pw)
+}
+
+// writeRoutes writes the per route metrics sorted by route and method.
+func writeRoutes(pw *prometheus.Writer) {
+	routes.mu.Lock()
+	defer routes.mu.Unlock()
+
+	keys := make([]routeKey, 0, len(routes.stats))
+	for key := range routes.stats {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	pw.Header("http_route_requests_total", "Number of requests handled per route and status code.", prometheus.TypeCounter)
+	for _, key := range keys {
+		codes := make([]int, 0, len(routes.stats[key].codes))
+		for code := range routes.stats[key].codes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+
+		for _, code := range codes {
+			labels := prometheus.Labels{"method": key.method, "route": key.route, "code": strconv.Itoa(code)}
+			pw.Sample("http_route_requests_total", labels, float64(routes.stats[key].codes[code]))
+		}
+	}
+
+	pw.Header("http_route_duration_seconds", "Latency of the requests handled per route.", prometheus.TypeHistogram)
+	for _, key := range keys {
+		labels := prometheus.Labels{"method": key.method, "route": key.route}
+		pw.Histogram("http_route_duration_seconds", labels, routes.stats[key].latency)
+	}
+}
+
+// writeRuntime writes the runtime metrics, covering the goroutines metric
+// and the memory stats published by expvar. The goroutines are read now
+// instead of using the sample expvar refreshes every hundred requests.
+func writeRuntime(pw *prometheus.Writer) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	gauges := []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())},
+		{"go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(ms.Alloc)},
+		{"go_memstats_sys_bytes", "Number of bytes obtained from the system.", float64(ms.Sys)},
+		{"go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", float64(ms.HeapAlloc)},
+		{"go_memstats_heap_objects", "Number of allocated objects.", float64(ms.HeapObjects)},
+	}
+	for _, g := range gauges {
+		pw.Header(g.name, g.help, prometheus.TypeGauge)
+		pw.Sample(g.name, nil, g.value)
+	}
+
+	counters := []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(ms.TotalAlloc)},
+		{"go_memstats_mallocs_total", "Total number of mallocs.", float64(ms.Mallocs)},
+		{"go_memstats_frees_total", "Total number of frees.", float64(ms.Frees)},
+		{"go_gc_cycles_total", "Number of completed GC cycles.", float64(ms.NumGC)},
+		{"go_gc_pause_seconds_total", "Total time spent in GC pauses.", time.Duration(ms.PauseTotalNs).Seconds()},
+	}
+	for _, c := range counters {
+		pw.Header(c.name, c.help, prometheus.TypeCounter)
+		pw.Sample(c.name, nil, c.value)
+	}
+}
@@ -3,7 +3,9 @@ package mid
 import (
 	"context"
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/bruno-sartori/go-blockchain/business/sys/validate"
 	v1Web "github.com/bruno-sartori/go-blockchain/business/web/v1"
@@ -54,6 +56,13 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 					}
 					status = reqErr.Status
 
+					// Tell a throttled client when to retry, in whole
+					// seconds as the header requires.
+					if reqErr.RetryAfter > 0 {
+						seconds := int(math.Ceil(reqErr.RetryAfter.Seconds()))
+						w.Header().Set("Retry-After", strconv.Itoa(seconds))
+					}
+
 				default:
 					er = v1Web.ErrorResponse{
 						Error: http.StatusText(http.StatusInternalServerError),
@@ -3,8 +3,11 @@ package mid
 import (
 	"context"
 	"net/http"
+	"time"
 
+	"github.com/bruno-sartori/go-blockchain/business/sys/validate"
 	"github.com/bruno-sartori/go-blockchain/business/web/metrics"
+	v1Web "github.com/bruno-sartori/go-blockchain/business/web/v1"
 	"github.com/bruno-sartori/go-blockchain/foundation/web"
 )
 
@@ -17,11 +20,18 @@ func Metrics() web.Middleware {
 		// Create the handler that will be attached in the middleware chain.
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			v, err := web.GetValues(ctx)
+			if err != nil {
+				return web.NewShutdownError("web value missing from context")
+			}
+
 			// Add the metrics into the context for metric gathering.
 			ctx = metrics.Set(ctx)
 
 			// Call the next handler.
-			err := handler(ctx, w, r)
+			err = handler(ctx, w, r)
 
 			// Handle updating the metrics that can be handled here.
 
@@ -34,6 +44,14 @@ func Metrics() web.Middleware {
 				metrics.AddErrors(ctx)
 			}
 
+			// Record the request against the route it was registered with,
+			// and count it when it was refused by a rate limit.
+			status := statusCode(v, err)
+			if status == http.StatusTooManyRequests {
+				metrics.AddThrottled(ctx)
+			}
+			metrics.AddRoute(r.Method, v.Route, status, time.Since(v.Now))
+
 			// Return the error so it can be handled further up the chain.
 			return err
 		}
@@ -43,3 +61,17 @@ func Metrics() web.Middleware {
 
 	return m
 }
+
+// statusCode returns the status code the request completes with. Errors are
+// responded to by the Errors middleware that runs after this one returns.
+func statusCode(v *web.Values, err error) int {
+	switch {
+	case err == nil:
+		return v.StatusCode
+	case validate.IsFieldErrors(err):
+		return http.StatusBadRequest
+	case v1Web.IsRequestError(err):
+		return v1Web.GetRequestError(err).Status
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,94 @@
+package mid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/business/sys/validate"
+	"github.com/bruno-sartori/go-blockchain/business/web/auth"
+	v1Web "github.com/bruno-sartori/go-blockchain/business/web/v1"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/web"
+)
+
+// NodeAuth validates that the request was signed by a node whose account
+// is in the allowed list of peers and that the signature is neither stale
+// nor replayed.
+// The verified node is stored in the context for the handlers. Requests
+// from banned accounts are rejected and requests that break the node
+// protocol cost the signing account points. Other rejected requests, like
+// a block range out of bounds, don't.
+func NodeAuth(allowed []database.AccountID, maxAge time.Duration, peers *peer.PeerSet) web.Middleware {
+	accounts := make(map[database.AccountID]struct{}, len(allowed))
+	for _, accountID := range allowed {
+		accounts[accountID] = struct{}{}
+	}
+
+	verifier := auth.NewVerifier(maxAge)
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			v, err := web.GetValues(ctx)
+			if err != nil {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			node, err := verifier.Verify(r, v.Now)
+			if err != nil {
+				return v1Web.NewRequestError(fmt.Errorf("authenticate: %w", err), http.StatusUnauthorized)
+			}
+
+			if node.Host == "" {
+				return v1Web.NewRequestError(errors.New("authenticate: missing node host"), http.StatusUnauthorized)
+			}
+
+			if _, exists := accounts[node.AccountID]; !exists {
+				return v1Web.NewRequestError(errors.New("authenticate: node is not an allowed peer"), http.StatusForbidden)
+			}
+
+			if peers.IsBanned(node.AccountID) {
+				return v1Web.NewRequestError(errors.New("authenticate: peer is banned"), http.StatusForbidden)
+			}
+
+			// Call the next handler.
+			err = handler(auth.SetNode(ctx, node), w, r)
+
+			// Only requests that break the protocol lower the reputation.
+			if isProtocolError(err) {
+				peers.Penalize(node.AccountID, peer.PenaltyProtocol, err.Error())
+			}
+
+			// Return the error so it can be handled further up the chain.
+			return err
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// isProtocolError reports whether the error is one caused by a peer
+// breaking the node protocol: a payload that fails validation or an error
+// the handlers marked as a protocol error.
+func isProtocolError(err error) bool {
+	switch {
+	case err == nil:
+		return false
+
+	case validate.IsFieldErrors(err):
+		return true
+	}
+
+	return v1Web.IsProtocolError(err)
+}
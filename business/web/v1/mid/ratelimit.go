@@ -0,0 +1,50 @@
+package mid
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	v1Web "github.com/bruno-sartori/go-blockchain/business/web/v1"
+	"github.com/bruno-sartori/go-blockchain/foundation/ratelimit"
+	"github.com/bruno-sartori/go-blockchain/foundation/web"
+)
+
+// RateLimit limits the number of requests a client IP can make with the
+// specified limiter. Throttled requests are refused with a 429 and the
+// Retry-After header.
+func RateLimit(limiter *ratelimit.Limiter) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// The limit is disabled so there is nothing to wrap.
+		if !limiter.Enabled() {
+			return handler
+		}
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v, err := web.GetValues(ctx)
+			if err != nil {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+
+			if ok, wait := limiter.Allow(ip, v.Now); !ok {
+				return v1Web.NewThrottleError(fmt.Errorf("too many requests from %s", ip), wait)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
@@ -0,0 +1,63 @@
+package mid
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
+	"github.com/bruno-sartori/go-blockchain/foundation/web"
+)
+
+// Tracing exports a span for every request to the specified exporter. The
+// span continues the trace of the caller when the request carries one.
+func Tracing(exporter tracing.Exporter) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// There is nowhere to send the spans so there is nothing to wrap.
+		if exporter == nil {
+			return handler
+		}
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			v, err := web.GetValues(ctx)
+			if err != nil {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			// The spans started while handling the request are children of
+			// the span of the request.
+			ctx = tracing.ContextWithSpan(ctx, exporter, v.TraceID, v.SpanID)
+
+			// Call the next handler.
+			err = handler(ctx, w, r)
+
+			exporter.Export(tracing.Span{
+				TraceID:  v.TraceID,
+				SpanID:   v.SpanID,
+				ParentID: v.ParentSpanID,
+				Name:     r.Method + " " + v.Route,
+				Start:    v.Now,
+				End:      time.Now().UTC(),
+				Attributes: map[string]any{
+					"http.method":      r.Method,
+					"http.route":       v.Route,
+					"http.status_code": statusCode(v, err),
+				},
+			})
+
+			// Return the error so it can be handled further up the chain.
+			return err
+		}
+
+		return h
+	}
+
+	return m
+}
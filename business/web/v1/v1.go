@@ -1,7 +1,11 @@
 // Package v1 represents types used by the web application for v1.
 package v1
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+	"time"
+)
 
 // ErrorResponse is the form used for API responses from failures in the API.
 type ErrorResponse struct {
@@ -12,14 +16,29 @@ type ErrorResponse struct {
 // RequestError is used to pass an error during the request through the
 // application with web specific context.
 type RequestError struct {
-	Err    error
-	Status int
+	Err        error
+	Status     int
+	RetryAfter time.Duration
+	Protocol   bool
 }
 
 // NewRequestError wraps a provided error with an HTTP status code. This
 // function should be used when handlers encounter expected errors.
 func NewRequestError(err error, status int) error {
-	return &RequestError{err, status}
+	return &RequestError{Err: err, Status: status}
+}
+
+// NewThrottleError wraps a provided error for a request refused by a rate
+// limit. The client is told to retry once the specified wait has passed.
+func NewThrottleError(err error, wait time.Duration) error {
+	return &RequestError{Err: err, Status: http.StatusTooManyRequests, RetryAfter: wait}
+}
+
+// NewProtocolError wraps a provided error for a request from a peer that
+// breaks the node protocol, like a payload that can't be decoded. These
+// errors cost the peer reputation, where other request errors don't.
+func NewProtocolError(err error, status int) error {
+	return &RequestError{Err: err, Status: status, Protocol: true}
 }
 
 // Error implements the error interface. It uses the default message of the
@@ -34,6 +53,13 @@ func IsRequestError(err error) bool {
 	return errors.As(err, &re)
 }
 
+// IsProtocolError checks if an error is a RequestError for a request that
+// breaks the node protocol.
+func IsProtocolError(err error) bool {
+	var re *RequestError
+	return errors.As(err, &re) && re.Protocol
+}
+
 // GetRequestError returns a copy of the RequestError pointer.
 func GetRequestError(err error) *RequestError {
 	var re *RequestError
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"sort"
+	"sync"
+)
+
+// Set of directions an account history entry can have.
+const (
+	DirectionSent     = "sent"
+	DirectionReceived = "received"
+	DirectionReward   = "reward"
+)
+
+// AccountTx represents a single entry in the history of an account.
+type AccountTx struct {
+	Seq          uint64    `json:"-"`
+	Hash         string    `json:"hash,omitempty"`
+	BlockNumber  uint64    `json:"block_number"`
+	Index        uint64    `json:"index"`
+	Direction    string    `json:"direction"`
+	Counterparty AccountID `json:"counterparty,omitempty"`
+	Value        uint64    `json:"value"`
+	Tip          uint64    `json:"tip"`
+}
+
+// AccountIndex maps accounts to the transactions they sent or received and
+// the mining rewards they earned. Blocks are applied in order and reverted
+// from the top when the chain reorganizes, so the history of every account
+// is always sorted by block.
+type AccountIndex struct {
+	mu      sync.RWMutex
+	seq     uint64
+	history map[AccountID][]AccountTx
+}
+
+// NewAccountIndex constructs an empty account index. The index is rebuilt
+// by applying every block in storage in order.
+func NewAccountIndex() *AccountIndex {
+	return &AccountIndex{
+		history: make(map[AccountID][]AccountTx),
+	}
+}
+
+// ApplyBlock adds the transactions of the block and the reward paid to its
+// beneficiary to the history of the accounts involved.
+func (idx *AccountIndex) ApplyBlock(number uint64, beneficiary AccountID, reward uint64, txs []SignedTx) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for i, tx := range txs {
+		hash := tx.Hash()
+
+		idx.add(tx.FromID, AccountTx{
+			Hash:         hash,
+			BlockNumber:  number,
+			Index:        uint64(i),
+			Direction:    DirectionSent,
+			Counterparty: tx.ToID,
+			Value:        tx.Value,
+			Tip:          tx.Tip,
+		})
+
+		idx.add(tx.ToID, AccountTx{
+			Hash:         hash,
+			BlockNumber:  number,
+			Index:        uint64(i),
+			Direction:    DirectionReceived,
+			Counterparty: tx.FromID,
+			Value:        tx.Value,
+			Tip:          tx.Tip,
+		})
+	}
+
+	idx.add(beneficiary, AccountTx{
+		BlockNumber: number,
+		Index:       uint64(len(txs)),
+		Direction:   DirectionReward,
+		Value:       reward,
+	})
+}
+
+// RevertFrom removes every entry added by the block with the specified
+// number and the blocks after it. It is used when a reorg replaces them.
+func (idx *AccountIndex) RevertFrom(number uint64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for accountID, entries := range idx.history {
+		n := sort.Search(len(entries), func(i int) bool {
+			return entries[i].BlockNumber >= number
+		})
+
+		if n == 0 {
+			delete(idx.history, accountID)
+			continue
+		}
+		idx.history[accountID] = entries[:n]
+	}
+}
+
+// Query returns up to limit entries from the history of the account, newest
+// first, that are older than the cursor and match the direction. A zero
+// cursor starts from the newest entry and an empty direction matches every
+// entry. The returned cursor is zero when there are no more entries.
+func (idx *AccountIndex) Query(accountID AccountID, direction string, cursor uint64, limit int) ([]AccountTx, uint64) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	entries := idx.history[accountID]
+
+	// Find the first entry older than the cursor.
+	i := len(entries)
+	if cursor != 0 {
+		i = sort.Search(len(entries), func(i int) bool {
+			return entries[i].Seq >= cursor
+		})
+	}
+
+	page := []AccountTx{}
+	for i--; i >= 0; i-- {
+		if direction != "" && entries[i].Direction != direction {
+			continue
+		}
+
+		if len(page) == limit {
+			return page, page[len(page)-1].Seq
+		}
+		page = append(page, entries[i])
+	}
+
+	return page, 0
+}
+
+// add appends the entry to the history of the account.
+func (idx *AccountIndex) add(accountID AccountID, entry AccountTx) {
+	idx.seq++
+	entry.Seq = idx.seq
+	idx.history[accountID] = append(idx.history[accountID], entry)
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/difficulty"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/signature"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ErrInvalidBlock is returned when a block or header breaks the rules of
+// the chain. Peers sending such blocks are penalized.
+var ErrInvalidBlock = errors.New("invalid block")
+
+// BlockHeader represents common information required for each block.
+type BlockHeader struct {
+	Number        uint64    `json:"number"`          // Ethereum: Block number in the chain.
+	PrevBlockHash string    `json:"prev_block_hash"` // Bitcoin: Hash of the previous block in the chain.
+	TimeStamp     uint64    `json:"timestamp"`       // Bitcoin: Time the block was mined in Unix milliseconds.
+	BeneficiaryID AccountID `json:"beneficiary"`     // Ethereum: The account who is receiving fees and tips.
+	Difficulty    uint16    `json:"difficulty"`      // Ethereum: Number of 0's needed to solve the hash solution.
+	MiningReward  uint64    `json:"mining_reward"`   // Ethereum: The reward for mining this block.
+	TransRoot     string    `json:"trans_root"`      // Both: Represents the merkle tree root hash for the transactions in this block.
+	Nonce         uint64    `json:"nonce"`           // Both: Value identified to solve the hash solution.
+}
+
+// Hash returns the unique hash for the block header.
+func (bh BlockHeader) Hash() string {
+	return signature.Hash(bh)
+}
+
+// IsSolved reports whether the hash of the header has the number of leading
+// zeros its difficulty requires.
+func (bh BlockHeader) IsSolved() bool {
+	hash := strings.TrimPrefix(bh.Hash(), "0x")
+	if int(bh.Difficulty) > len(hash) {
+		return false
+	}
+
+	return strings.Count(hash[:bh.Difficulty], "0") == int(bh.Difficulty)
+}
+
+// Retarget returns the fields of the header used to retarget the difficulty.
+func (bh BlockHeader) Retarget() difficulty.Header {
+	return difficulty.Header{
+		Number:     bh.Number,
+		TimeStamp:  bh.TimeStamp,
+		Difficulty: bh.Difficulty,
+	}
+}
+
+// ValidateLink checks the header extends the parent header and that its
+// proof of work is solved. The difficulty and reward depend on the rules
+// of the chain and are checked by the caller.
+func (bh BlockHeader) ValidateLink(parent BlockHeader) error {
+	if bh.Number != parent.Number+1 {
+		return fmt.Errorf("%w: block %d: number must follow parent %d", ErrInvalidBlock, bh.Number, parent.Number)
+	}
+
+	if bh.PrevBlockHash != parent.Hash() {
+		return fmt.Errorf("%w: block %d: previous block hash doesn't match parent", ErrInvalidBlock, bh.Number)
+	}
+
+	if bh.TimeStamp <= parent.TimeStamp {
+		return fmt.Errorf("%w: block %d: timestamp must be after parent", ErrInvalidBlock, bh.Number)
+	}
+
+	if !bh.IsSolved() {
+		return fmt.Errorf("%w: block %d: proof of work not solved", ErrInvalidBlock, bh.Number)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// Block represents a group of transactions batched together.
+type Block struct {
+	Header BlockHeader `json:"header"`
+	Trans  []SignedTx  `json:"trans"`
+}
+
+// Hash returns the unique hash for the block, which is the hash of its
+// header. The transactions are covered through the transaction root.
+func (b Block) Hash() string {
+	return b.Header.Hash()
+}
+
+// ValidateBody checks the transactions of the block match the transaction
+// root of its header.
+func (b Block) ValidateBody() error {
+	if root := TransRoot(b.Trans); root != b.Header.TransRoot {
+		return fmt.Errorf("%w: block %d: transaction root mismatch, got[%s] exp[%s]", ErrInvalidBlock, b.Header.Number, root, b.Header.TransRoot)
+	}
+	return nil
+}
+
+// POW performs the proof of work for the header. The nonce is searched in
+// order starting from the nonce the header already has, so mining the same
+// header always yields the same block. The search stops when the context
+// is cancelled.
+func POW(ctx context.Context, header BlockHeader) (BlockHeader, error) {
+	for {
+		if header.IsSolved() {
+			return header, nil
+		}
+
+		// Checking the context on every attempt is cheap compared to
+		// hashing the header.
+		if err := ctx.Err(); err != nil {
+			return BlockHeader{}, err
+		}
+
+		header.Nonce++
+	}
+}
+
+// TransRoot returns the merkle root of the transactions. A block without
+// transactions has the zero hash as its root.
+//
+// Leaves and inner nodes are hashed with a different prefix and the last
+// node of a level with an odd number of nodes moves up unchanged, so no two
+// lists of transactions share a root.
+func TransRoot(txs []SignedTx) string {
+	if len(txs) == 0 {
+		return signature.ZeroHash
+	}
+
+	level := make([][]byte, len(txs))
+	for i, tx := range txs {
+		level[i] = merkleHash(0x00, hexutil.MustDecode(tx.Hash()))
+	}
+
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleHash(0x01, level[i], level[i+1]))
+		}
+		level = next
+	}
+
+	return hexutil.Encode(level[0])
+}
+
+// merkleHash returns the sha256 of the prefix followed by the data.
+func merkleHash(prefix byte, data ...[]byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{prefix})
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,174 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/signature"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_ValidateLink(t *testing.T) {
+	parent := database.BlockHeader{Number: 1, TimeStamp: 1000, Difficulty: 1, TransRoot: signature.ZeroHash}
+
+	child := mine(t, database.BlockHeader{
+		Number:        2,
+		PrevBlockHash: parent.Hash(),
+		TimeStamp:     2000,
+		Difficulty:    1,
+		TransRoot:     signature.ZeroHash,
+	})
+
+	tt := []struct {
+		name   string
+		change func(h *database.BlockHeader)
+		valid  bool
+	}{
+		{"mined child", func(h *database.BlockHeader) {}, true},
+		{"wrong number", func(h *database.BlockHeader) { h.Number = 3 }, false},
+		{"wrong parent", func(h *database.BlockHeader) { h.PrevBlockHash = signature.ZeroHash }, false},
+		{"timestamp before the parent", func(h *database.BlockHeader) { h.TimeStamp = 1000 }, false},
+		{"unsolved proof of work", func(h *database.BlockHeader) { h.Difficulty = 32 }, false},
+	}
+
+	t.Log("Given the need to validate a header extends its parent.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					header := child
+					tst.change(&header)
+
+					err := header.ValidateLink(parent)
+					if tst.valid && err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould accept the header: %s", failed, testID, err)
+					}
+					if !tst.valid && !errors.Is(err, database.ErrInvalidBlock) {
+						t.Fatalf("\t%s\tTest %d:\tShould reject the header as invalid: got[%v]", failed, testID, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould validate the header.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_TransRoot(t *testing.T) {
+	txs := signedTxs(t, 3)
+
+	tt := []struct {
+		name  string
+		block database.Block
+		valid bool
+	}{
+		{"block without transactions", database.Block{Header: database.BlockHeader{TransRoot: signature.ZeroHash}}, true},
+		{"matching root", database.Block{Header: database.BlockHeader{TransRoot: database.TransRoot(txs)}, Trans: txs}, true},
+		{"missing transaction", database.Block{Header: database.BlockHeader{TransRoot: database.TransRoot(txs)}, Trans: txs[:2]}, false},
+		{"reordered transactions", database.Block{Header: database.BlockHeader{TransRoot: database.TransRoot(txs)}, Trans: []database.SignedTx{txs[1], txs[0], txs[2]}}, false},
+		{"duplicated last transaction", database.Block{Header: database.BlockHeader{TransRoot: database.TransRoot(txs)}, Trans: append(txs[:3:3], txs[2])}, false},
+	}
+
+	t.Log("Given the need to check a block body against its header.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					err := tst.block.ValidateBody()
+					if tst.valid && err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould accept the body: %s", failed, testID, err)
+					}
+					if !tst.valid && !errors.Is(err, database.ErrInvalidBlock) {
+						t.Fatalf("\t%s\tTest %d:\tShould reject the body as invalid: got[%v]", failed, testID, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould check the body.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_POW(t *testing.T) {
+	t.Log("Given the need to mine headers deterministically.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the same header is mined twice.", testID)
+		{
+			header := database.BlockHeader{Number: 1, Difficulty: 2, TransRoot: signature.ZeroHash}
+
+			a := mine(t, header)
+			b := mine(t, header)
+
+			if !a.IsSolved() || a.Hash() != b.Hash() {
+				t.Fatalf("\t%s\tTest %d:\tShould find the same solution: got[%s] exp[%s]", failed, testID, b.Hash(), a.Hash())
+			}
+			t.Logf("\t%s\tTest %d:\tShould find the same solution.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the context is cancelled.", testID)
+		{
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			header := database.BlockHeader{Number: 1, Difficulty: 32}
+			if _, err := database.POW(ctx, header); !errors.Is(err, context.Canceled) {
+				t.Fatalf("\t%s\tTest %d:\tShould stop mining: got[%v]", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould stop mining.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// mine solves the proof of work of the header.
+func mine(t *testing.T, header database.BlockHeader) database.BlockHeader {
+	t.Helper()
+
+	header, err := database.POW(context.Background(), header)
+	if err != nil {
+		t.Fatalf("mining header: %s", err)
+	}
+
+	return header
+}
+
+// signedTxs returns the specified number of transactions signed by a new
+// account.
+func signedTxs(t *testing.T, n int) []database.SignedTx {
+	t.Helper()
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+	fromID := database.PublicKeyToAccountID(privateKey.PublicKey)
+
+	txs := make([]database.SignedTx, n)
+	for i := range txs {
+		tx, err := database.NewTx(1, uint64(i+1), fromID, "0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4", 10, 0, nil)
+		if err != nil {
+			t.Fatalf("constructing tx: %s", err)
+		}
+
+		if txs[i], err = tx.Sign(privateKey); err != nil {
+			t.Fatalf("signing tx: %s", err)
+		}
+	}
+
+	return txs
+}
@@ -1,3 +1,173 @@
 // Package database handles all the lower level support for maintaining the
 // blockchain in storage and maintaining an in-memory database of account information.
 package database
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Account represents information stored in the database for an individual account.
+type Account struct {
+	AccountID AccountID `json:"account_id"`
+	Nonce     uint64    `json:"nonce"`
+	Balance   uint64    `json:"balance"`
+}
+
+// newAccount constructs a new account value for use.
+func newAccount(accountID AccountID, balance uint64) Account {
+	return Account{
+		AccountID: accountID,
+		Balance:   balance,
+	}
+}
+
+// =============================================================================
+
+// Database manages data related to accounts who have transacted on the blockchain.
+type Database struct {
+	mu       sync.RWMutex
+	accounts map[AccountID]Account
+}
+
+// New constructs a new database and applies the specified starting balances,
+// which are the balances provided by the genesis file.
+func New(balances map[string]uint64) (*Database, error) {
+	db := Database{
+		accounts: make(map[AccountID]Account),
+	}
+
+	for accountStr, balance := range balances {
+		accountID, err := ToAccountID(accountStr)
+		if err != nil {
+			return nil, err
+		}
+		db.accounts[accountID] = newAccount(accountID, balance)
+	}
+
+	return &db, nil
+}
+
+// Query retrieves an account from the database.
+func (db *Database) Query(accountID AccountID) (Account, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	account, exists := db.accounts[accountID]
+	if !exists {
+		return Account{}, errors.New("account does not exist")
+	}
+
+	return account, nil
+}
+
+// Copy makes a copy of the current accounts in the database. Changes made to
+// the copy don't affect the database.
+func (db *Database) Copy() *Database {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	accounts := make(map[AccountID]Account, len(db.accounts))
+	for accountID, account := range db.accounts {
+		accounts[accountID] = account
+	}
+
+	return &Database{
+		accounts: accounts,
+	}
+}
+
+// ApplyMiningReward gives the beneficiary of the block the mining reward.
+func (db *Database) ApplyMiningReward(header BlockHeader) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	changes := make(map[AccountID]Account)
+	if err := db.pay(changes, header.BeneficiaryID, header.MiningReward); err != nil {
+		return err
+	}
+	db.commit(changes)
+
+	return nil
+}
+
+// ApplyTransaction performs the business logic for applying a transaction
+// to the database. The gas fee and the tip are paid to the beneficiary of
+// the block. The gas fee is charged even when the transaction fails since
+// the work to process it was still done. A transaction that would overflow
+// a balance fails without moving its value.
+func (db *Database) ApplyTransaction(tx Tx, gasFee uint64, beneficiaryID AccountID) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	fromAccount := db.accounts[tx.FromID]
+	fromAccount.AccountID = tx.FromID
+
+	// Charge the gas fee, up to what the account can pay.
+	if gasFee > fromAccount.Balance {
+		gasFee = fromAccount.Balance
+	}
+	fromAccount.Balance -= gasFee
+
+	changes := map[AccountID]Account{tx.FromID: fromAccount}
+	if err := db.pay(changes, beneficiaryID, gasFee); err != nil {
+		return err
+	}
+	db.commit(changes)
+	fromAccount = db.accounts[tx.FromID]
+
+	if tx.Nonce != fromAccount.Nonce+1 {
+		return fmt.Errorf("transaction invalid, wrong nonce, got %d, exp %d", tx.Nonce, fromAccount.Nonce+1)
+	}
+
+	if tx.Tip > math.MaxUint64-tx.Value {
+		return fmt.Errorf("transaction invalid, value %d plus tip %d overflows", tx.Value, tx.Tip)
+	}
+
+	if fromAccount.Balance == 0 || fromAccount.Balance < tx.Value+tx.Tip {
+		return fmt.Errorf("transaction invalid, insufficient funds, bal %d, needed %d", fromAccount.Balance, tx.Value+tx.Tip)
+	}
+
+	fromAccount.Balance -= tx.Value + tx.Tip
+	fromAccount.Nonce = tx.Nonce
+
+	changes = map[AccountID]Account{tx.FromID: fromAccount}
+	if err := db.pay(changes, tx.ToID, tx.Value); err != nil {
+		return err
+	}
+	if err := db.pay(changes, beneficiaryID, tx.Tip); err != nil {
+		return err
+	}
+	db.commit(changes)
+
+	return nil
+}
+
+// pay adds the amount to the balance of the account in the set of changes,
+// starting from the database when the account hasn't changed yet. An
+// amount that would overflow the balance is an error. The caller must hold
+// the write lock.
+func (db *Database) pay(changes map[AccountID]Account, accountID AccountID, amount uint64) error {
+	account, exists := changes[accountID]
+	if !exists {
+		account = db.accounts[accountID]
+		account.AccountID = accountID
+	}
+
+	if account.Balance > math.MaxUint64-amount {
+		return fmt.Errorf("transaction invalid, balance of %s overflows, bal %d, paid %d", accountID, account.Balance, amount)
+	}
+	account.Balance += amount
+
+	changes[accountID] = account
+	return nil
+}
+
+// commit stores the changed accounts. The caller must hold the write lock.
+func (db *Database) commit(changes map[AccountID]Account) {
+	for accountID, account := range changes {
+		db.accounts[accountID] = account
+	}
+}
@@ -0,0 +1,102 @@
+package database_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+)
+
+const (
+	fromID        = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	toID          = database.AccountID("0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76")
+	beneficiaryID = database.AccountID("0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+)
+
+func Test_ApplyTransaction(t *testing.T) {
+	const gasFee = 15
+
+	// balances holds the starting balances of the sender, the receiver and
+	// the beneficiary, and exp the balances after the transaction.
+	type balances [3]uint64
+
+	tt := []struct {
+		name     string
+		nonce    uint64
+		value    uint64
+		tip      uint64
+		balances balances
+		success  bool
+		exp      balances
+	}{
+		{"valid transaction", 1, 100, 5, balances{1000, 0, 0}, true, balances{880, 100, 20}},
+		{"wrong nonce", 2, 100, 5, balances{1000, 0, 0}, false, balances{985, 0, 15}},
+		{"sender short of funds", 1, 1000, 5, balances{1000, 0, 0}, false, balances{985, 0, 15}},
+		{"value plus tip overflow", 1, math.MaxUint64, 1, balances{1000, 0, 0}, false, balances{985, 0, 15}},
+		{"receiver balance overflow", 1, 100, 5, balances{1000, math.MaxUint64 - 50, 0}, false, balances{985, math.MaxUint64 - 50, 15}},
+		{"beneficiary balance overflow on the tip", 1, 100, 5, balances{1000, 0, math.MaxUint64 - 17}, false, balances{985, 0, math.MaxUint64 - 2}},
+		{"beneficiary balance overflow on the gas", 1, 100, 5, balances{1000, 0, math.MaxUint64 - 10}, false, balances{1000, 0, math.MaxUint64 - 10}},
+	}
+
+	t.Log("Given the need to apply transactions to the accounts.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					db, err := database.New(map[string]uint64{
+						string(fromID):        tst.balances[0],
+						string(toID):          tst.balances[1],
+						string(beneficiaryID): tst.balances[2],
+					})
+					if err != nil {
+						t.Fatalf("constructing database: %s", err)
+					}
+
+					tx := database.Tx{ChainID: 1, Nonce: tst.nonce, FromID: fromID, ToID: toID, Value: tst.value, Tip: tst.tip}
+					err = db.ApplyTransaction(tx, gasFee, beneficiaryID)
+
+					if success := err == nil; success != tst.success {
+						t.Fatalf("\t%s\tTest %d:\tShould apply as expected: got[%v] exp[%v] err[%v]", failed, testID, success, tst.success, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould apply as expected.", success, testID)
+
+					for i, accountID := range []database.AccountID{fromID, toID, beneficiaryID} {
+						account, _ := db.Query(accountID)
+						if account.Balance != tst.exp[i] {
+							t.Fatalf("\t%s\tTest %d:\tShould have the expected balance for %s: got[%d] exp[%d]", failed, testID, accountID, account.Balance, tst.exp[i])
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould have the expected balances.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_ApplyMiningReward(t *testing.T) {
+	t.Log("Given the need to pay the mining reward.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the reward overflows the balance of the beneficiary.", testID)
+		{
+			db, err := database.New(map[string]uint64{string(beneficiaryID): math.MaxUint64 - 10})
+			if err != nil {
+				t.Fatalf("constructing database: %s", err)
+			}
+
+			header := database.BlockHeader{BeneficiaryID: beneficiaryID, MiningReward: 700}
+			if err := db.ApplyMiningReward(header); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse the reward.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse the reward.", success, testID)
+
+			if account, _ := db.Query(beneficiaryID); account.Balance != math.MaxUint64-10 {
+				t.Fatalf("\t%s\tTest %d:\tShould leave the balance unchanged: got[%d]", failed, testID, account.Balance)
+			}
+			t.Logf("\t%s\tTest %d:\tShould leave the balance unchanged.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Storage stores the blocks of the chain in a file with one JSON encoded
+// block per line, in block number order. Block 1 is the first line since
+// the genesis block is never stored.
+type Storage struct {
+	mu      sync.Mutex
+	file    *os.File
+	offsets []int64
+	size    int64
+}
+
+// NewStorage opens the block file at the specified path, creating it if it
+// doesn't exist. A block that was only partly written when the node stopped
+// is dropped.
+func NewStorage(path string) (*Storage, error) {
+	file, err := openRecords(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var number uint64
+	decode := func(line []byte) error {
+		var block Block
+		if err := json.Unmarshal(line, &block); err != nil {
+			return err
+		}
+
+		number++
+		if block.Header.Number != number {
+			return fmt.Errorf("block number %d out of order, exp %d", block.Header.Number, number)
+		}
+		return nil
+	}
+
+	offsets, size, err := readRecords(file, decode)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading blocks: %w", err)
+	}
+
+	strg := Storage{
+		file:    file,
+		offsets: offsets,
+		size:    size,
+	}
+
+	return &strg, nil
+}
+
+// Close closes the block file.
+func (strg *Storage) Close() error {
+	strg.mu.Lock()
+	defer strg.mu.Unlock()
+
+	return strg.file.Close()
+}
+
+// Count returns the number of blocks in storage.
+func (strg *Storage) Count() uint64 {
+	strg.mu.Lock()
+	defer strg.mu.Unlock()
+
+	return uint64(len(strg.offsets))
+}
+
+// Write appends the block to storage. The block must be the one after the
+// last block in storage.
+func (strg *Storage) Write(block Block) error {
+	strg.mu.Lock()
+	defer strg.mu.Unlock()
+
+	if exp := uint64(len(strg.offsets)) + 1; block.Header.Number != exp {
+		return fmt.Errorf("writing block %d, exp %d", block.Header.Number, exp)
+	}
+
+	data, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+
+	if _, err := strg.file.WriteAt(append(data, '\n'), strg.size); err != nil {
+		return err
+	}
+
+	strg.offsets = append(strg.offsets, strg.size)
+	strg.size += int64(len(data)) + 1
+
+	return nil
+}
+
+// Read returns the block with the specified number.
+func (strg *Storage) Read(number uint64) (Block, error) {
+	strg.mu.Lock()
+	defer strg.mu.Unlock()
+
+	if number == 0 || number > uint64(len(strg.offsets)) {
+		return Block{}, fmt.Errorf("block %d not found", number)
+	}
+
+	end := strg.size
+	if number < uint64(len(strg.offsets)) {
+		end = strg.offsets[number]
+	}
+	start := strg.offsets[number-1]
+
+	data := make([]byte, end-start)
+	if _, err := strg.file.ReadAt(data, start); err != nil {
+		return Block{}, err
+	}
+
+	var block Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		return Block{}, fmt.Errorf("decoding block %d: %w", number, err)
+	}
+
+	return block, nil
+}
+
+// Truncate removes every block after the specified number. It is used when
+// a reorg replaces them.
+func (strg *Storage) Truncate(number uint64) error {
+	strg.mu.Lock()
+	defer strg.mu.Unlock()
+
+	if number >= uint64(len(strg.offsets)) {
+		return nil
+	}
+
+	size := strg.offsets[number]
+	if err := strg.file.Truncate(size); err != nil {
+		return err
+	}
+
+	strg.offsets = strg.offsets[:number]
+	strg.size = size
+
+	return nil
+}
+
+// =============================================================================
+
+// openRecords opens a file of JSON records for reading and writing,
+// creating it and its folder if they don't exist.
+func openRecords(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+}
+
+// readRecords reads a file with one JSON record per line and passes every
+// record to decode. It returns the offset of every record and the size of
+// the file. A record is only complete once its newline is written, so a
+// final record without one, or one that can't be decoded, is what was left
+// when the node stopped while writing and is truncated from the file. A bad
+// record anywhere else is an error.
+func readRecords(file *os.File, decode func(line []byte) error) ([]int64, int64, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	var offsets []int64
+	var size int64
+
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+
+		switch {
+		case errors.Is(err, io.EOF):
+			if len(line) > 0 {
+				return offsets, size, file.Truncate(size)
+			}
+			return offsets, size, nil
+
+		case err != nil:
+			return nil, 0, err
+		}
+
+		if err := decode(line[:len(line)-1]); err != nil {
+			if _, peekErr := r.Peek(1); errors.Is(peekErr, io.EOF) {
+				return offsets, size, file.Truncate(size)
+			}
+			return nil, 0, fmt.Errorf("record %d: %w", len(offsets)+1, err)
+		}
+
+		offsets = append(offsets, size)
+		size += int64(len(line))
+	}
+}
@@ -0,0 +1,157 @@
+package database_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+)
+
+func Test_Storage(t *testing.T) {
+	tt := []struct {
+		name   string
+		damage func(t *testing.T, path string)
+		count  uint64
+	}{
+		{"clean file", func(t *testing.T, path string) {}, 3},
+		{"partial last block", appendData(`{"header":{"number":4`), 3},
+		{"last block without a newline", appendData(`{"header":{"number":4}}`), 3},
+		{"garbled last block", appendData("not json\n"), 3},
+	}
+
+	t.Log("Given the need to recover the block file after a crash.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					path := filepath.Join(t.TempDir(), "blocks.jsonl")
+					writeBlocks(t, path, 3)
+					tst.damage(t, path)
+
+					strg, err := database.NewStorage(path)
+					if err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould open the storage: %s", failed, testID, err)
+					}
+					defer strg.Close()
+					t.Logf("\t%s\tTest %d:\tShould open the storage.", success, testID)
+
+					if count := strg.Count(); count != tst.count {
+						t.Fatalf("\t%s\tTest %d:\tShould keep the complete blocks: got[%d] exp[%d]", failed, testID, count, tst.count)
+					}
+					t.Logf("\t%s\tTest %d:\tShould keep the complete blocks.", success, testID)
+
+					block := database.Block{Header: database.BlockHeader{Number: tst.count + 1}}
+					if err := strg.Write(block); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould write the next block: %s", failed, testID, err)
+					}
+
+					got, err := strg.Read(tst.count + 1)
+					if err != nil || got.Header.Number != tst.count+1 {
+						t.Fatalf("\t%s\tTest %d:\tShould read the next block back: got[%d] err[%v]", failed, testID, got.Header.Number, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould read the next block back.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_StorageBadRecord(t *testing.T) {
+	t.Log("Given the need to refuse a damaged block file.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a block before the last one is damaged.", testID)
+		{
+			path := filepath.Join(t.TempDir(), "blocks.jsonl")
+			if err := os.WriteFile(path, []byte("not json\n{\"header\":{\"number\":1}}\n"), 0600); err != nil {
+				t.Fatalf("writing file: %s", err)
+			}
+
+			if _, err := database.NewStorage(path); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail to open the storage.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail to open the storage.", success, testID)
+		}
+	}
+}
+
+func Test_StorageTruncate(t *testing.T) {
+	t.Log("Given the need to replace the blocks of a reorg.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the blocks after block 1 are removed.", testID)
+		{
+			path := filepath.Join(t.TempDir(), "blocks.jsonl")
+			writeBlocks(t, path, 3)
+
+			strg, err := database.NewStorage(path)
+			if err != nil {
+				t.Fatalf("opening storage: %s", err)
+			}
+
+			if err := strg.Truncate(1); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould truncate the storage: %s", failed, testID, err)
+			}
+
+			block := database.Block{Header: database.BlockHeader{Number: 2, Nonce: 7}}
+			if err := strg.Write(block); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould write a new block 2: %s", failed, testID, err)
+			}
+			strg.Close()
+			t.Logf("\t%s\tTest %d:\tShould write a new block 2.", success, testID)
+
+			strg, err = database.NewStorage(path)
+			if err != nil {
+				t.Fatalf("reopening storage: %s", err)
+			}
+			defer strg.Close()
+
+			got, err := strg.Read(2)
+			if err != nil || strg.Count() != 2 || got.Header.Nonce != 7 {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the new block after a restart: count[%d] nonce[%d] err[%v]", failed, testID, strg.Count(), got.Header.Nonce, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the new block after a restart.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// writeBlocks writes the specified number of empty blocks to a new file.
+func writeBlocks(t *testing.T, path string, n uint64) {
+	t.Helper()
+
+	strg, err := database.NewStorage(path)
+	if err != nil {
+		t.Fatalf("opening storage: %s", err)
+	}
+	defer strg.Close()
+
+	for number := uint64(1); number <= n; number++ {
+		if err := strg.Write(database.Block{Header: database.BlockHeader{Number: number}}); err != nil {
+			t.Fatalf("writing block %d: %s", number, err)
+		}
+	}
+}
+
+// appendData returns a function that appends the data to the file, as a
+// write cut short by a crash leaves it.
+func appendData(data string) func(t *testing.T, path string) {
+	return func(t *testing.T, path string) {
+		t.Helper()
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatalf("opening file: %s", err)
+		}
+		defer f.Close()
+
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatalf("appending: %s", err)
+		}
+	}
+}
@@ -93,6 +93,10 @@ func (tx SignedTx) Validate(chainID uint16) error {
 		return fmt.Errorf("transaction invalid, sending money to yourself, from %s to %s", tx.FromID, tx.ToID)
 	}
 
+	if tx.V == nil || tx.R == nil || tx.S == nil {
+		return errors.New("missing signature")
+	}
+
 	if err := signature.VerifySignature(tx.V, tx.R, tx.S); err != nil {
 		return err
 	}
@@ -109,6 +113,11 @@ func (tx SignedTx) Validate(chainID uint16) error {
 	return nil
 }
 
+// Returns a unique string that identifies the signed transaction.
+func (tx SignedTx) Hash() string {
+	return signature.Hash(tx)
+}
+
 // Returns the signature as a string
 func (tx SignedTx) SignatureString() string {
 	return signature.SignatureString(tx.V, tx.R, tx.S)
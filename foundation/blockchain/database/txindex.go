@@ -0,0 +1,155 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Receipt represents what a mined transaction cost the sender.
+type Receipt struct {
+	GasCharged uint64 `json:"gas_charged"`
+	TipPaid    uint64 `json:"tip_paid"`
+}
+
+// TxLocation represents where a mined transaction is found in the chain.
+type TxLocation struct {
+	Hash        string  `json:"hash"`
+	BlockNumber uint64  `json:"block_number"`
+	Index       uint64  `json:"index"`
+	Receipt     Receipt `json:"receipt"`
+}
+
+// TxIndex maps transaction hashes to their location in the chain. Every
+// location is appended to a file, in block order, so the index survives
+// restarts.
+type TxIndex struct {
+	mu        sync.RWMutex
+	file      *os.File
+	records   []TxLocation
+	offsets   []int64
+	size      int64
+	locations map[string]int
+}
+
+// NewTxIndex opens the index file at the specified path, creating it if it
+// doesn't exist, and loads the locations it holds. A location that was only
+// partly written when the node stopped is dropped.
+func NewTxIndex(path string) (*TxIndex, error) {
+	file, err := openRecords(path)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := TxIndex{
+		file:      file,
+		locations: make(map[string]int),
+	}
+
+	decode := func(line []byte) error {
+		var loc TxLocation
+		if err := json.Unmarshal(line, &loc); err != nil {
+			return err
+		}
+
+		if n := len(idx.records); n > 0 && loc.BlockNumber < idx.records[n-1].BlockNumber {
+			return fmt.Errorf("block number %d out of order, after %d", loc.BlockNumber, idx.records[n-1].BlockNumber)
+		}
+
+		idx.locations[loc.Hash] = len(idx.records)
+		idx.records = append(idx.records, loc)
+		return nil
+	}
+
+	offsets, size, err := readRecords(file, decode)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading tx index: %w", err)
+	}
+
+	idx.offsets = offsets
+	idx.size = size
+
+	return &idx, nil
+}
+
+// Close closes the index file.
+func (idx *TxIndex) Close() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return idx.file.Close()
+}
+
+// Add records the locations of the transactions of a block that was
+// applied to the chain. Locations must be added in block order.
+func (idx *TxIndex) Add(locs ...TxLocation) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, loc := range locs {
+		if n := len(idx.records); n > 0 && loc.BlockNumber < idx.records[n-1].BlockNumber {
+			return fmt.Errorf("adding block %d after block %d", loc.BlockNumber, idx.records[n-1].BlockNumber)
+		}
+
+		data, err := json.Marshal(loc)
+		if err != nil {
+			return err
+		}
+
+		if _, err := idx.file.WriteAt(append(data, '\n'), idx.size); err != nil {
+			return err
+		}
+
+		idx.locations[loc.Hash] = len(idx.records)
+		idx.records = append(idx.records, loc)
+		idx.offsets = append(idx.offsets, idx.size)
+		idx.size += int64(len(data)) + 1
+	}
+
+	return nil
+}
+
+// RevertFrom removes the locations in the block with the specified number
+// and the blocks after it. It is used when a reorg replaces them.
+func (idx *TxIndex) RevertFrom(number uint64) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	n := sort.Search(len(idx.records), func(i int) bool {
+		return idx.records[i].BlockNumber >= number
+	})
+
+	if n == len(idx.records) {
+		return nil
+	}
+
+	size := idx.offsets[n]
+	if err := idx.file.Truncate(size); err != nil {
+		return err
+	}
+
+	for _, loc := range idx.records[n:] {
+		delete(idx.locations, loc.Hash)
+	}
+	idx.records = idx.records[:n]
+	idx.offsets = idx.offsets[:n]
+	idx.size = size
+
+	return nil
+}
+
+// Query returns the location of the transaction with the specified hash.
+func (idx *TxIndex) Query(hash string) (TxLocation, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	i, exists := idx.locations[hash]
+	if !exists {
+		return TxLocation{}, false
+	}
+
+	return idx.records[i], true
+}
@@ -0,0 +1,123 @@
+package database_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+)
+
+func Test_TxIndex(t *testing.T) {
+	tt := []struct {
+		name   string
+		damage func(t *testing.T, path string)
+		count  int
+	}{
+		{"clean file", func(t *testing.T, path string) {}, 3},
+		{"partial last location", appendData(`{"hash":"0x04","block_num`), 3},
+		{"last location without a newline", appendData(`{"hash":"0x04","block_number":4}`), 3},
+		{"location out of block order", appendData("{\"hash\":\"0x04\",\"block_number\":1}\n"), 3},
+	}
+
+	t.Log("Given the need to recover the transaction index after a crash.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					path := filepath.Join(t.TempDir(), "txindex.jsonl")
+					writeLocations(t, path, 3)
+					tst.damage(t, path)
+
+					idx, err := database.NewTxIndex(path)
+					if err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould open the index: %s", failed, testID, err)
+					}
+					defer idx.Close()
+					t.Logf("\t%s\tTest %d:\tShould open the index.", success, testID)
+
+					for number := 1; number <= 4; number++ {
+						_, exists := idx.Query(fmt.Sprintf("0x%02d", number))
+						if exp := number <= tst.count; exists != exp {
+							t.Fatalf("\t%s\tTest %d:\tShould keep the complete locations: 0x%02d got[%v] exp[%v]", failed, testID, number, exists, exp)
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould keep the complete locations.", success, testID)
+
+					if err := idx.Add(database.TxLocation{Hash: "0x05", BlockNumber: 5}); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould add the next location: %s", failed, testID, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould add the next location.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_TxIndexRevert(t *testing.T) {
+	t.Log("Given the need to remove the transactions of a reorg.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the blocks after block 1 are replaced.", testID)
+		{
+			path := filepath.Join(t.TempDir(), "txindex.jsonl")
+			writeLocations(t, path, 3)
+
+			idx, err := database.NewTxIndex(path)
+			if err != nil {
+				t.Fatalf("opening index: %s", err)
+			}
+
+			if err := idx.RevertFrom(2); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould revert the index: %s", failed, testID, err)
+			}
+
+			// The transaction of block 3 was mined again in the new block 2.
+			if err := idx.Add(database.TxLocation{Hash: "0x03", BlockNumber: 2}); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould add the new block: %s", failed, testID, err)
+			}
+			idx.Close()
+			t.Logf("\t%s\tTest %d:\tShould revert the index.", success, testID)
+
+			idx, err = database.NewTxIndex(path)
+			if err != nil {
+				t.Fatalf("reopening index: %s", err)
+			}
+			defer idx.Close()
+
+			if _, exists := idx.Query("0x02"); exists {
+				t.Fatalf("\t%s\tTest %d:\tShould forget the reverted transaction after a restart.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould forget the reverted transaction after a restart.", success, testID)
+
+			if loc, _ := idx.Query("0x03"); loc.BlockNumber != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould find the transaction in its new block: got[%d] exp[2]", failed, testID, loc.BlockNumber)
+			}
+			t.Logf("\t%s\tTest %d:\tShould find the transaction in its new block.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// writeLocations writes a location for one transaction in each of the
+// specified number of blocks to a new file.
+func writeLocations(t *testing.T, path string, n uint64) {
+	t.Helper()
+
+	idx, err := database.NewTxIndex(path)
+	if err != nil {
+		t.Fatalf("opening index: %s", err)
+	}
+	defer idx.Close()
+
+	for number := uint64(1); number <= n; number++ {
+		loc := database.TxLocation{Hash: fmt.Sprintf("0x%02d", number), BlockNumber: number}
+		if err := idx.Add(loc); err != nil {
+			t.Fatalf("adding block %d: %s", number, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package difficulty retargets the proof of work difficulty so blocks are
+// mined at a steady interval regardless of the number of miners.
+package difficulty
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Set of limits of the difficulty. The difficulty is the number of leading
+// zeros of the hex encoded block hash, so every step multiplies or divides
+// the work needed to mine a block by 16. A block at the max difficulty
+// takes 16^8, about 4 billion, hashes on average, which a miner can still
+// find in hours where the next step up would take days.
+const (
+	MinDifficulty = 1
+	MaxDifficulty = 8
+)
+
+// ErrInvalidDifficulty is returned when a block was not mined at the
+// difficulty required at its height.
+var ErrInvalidDifficulty = errors.New("invalid difficulty")
+
+// Config represents how the difficulty is retargeted. The difficulty is
+// retargeted every window blocks from the time it took to mine the blocks
+// of the window, and only when that time is off the target by 4 times or
+// more. A zero window keeps the difficulty fixed.
+type Config struct {
+	TargetInterval time.Duration
+	Window         uint64
+}
+
+// Enabled reports whether the difficulty is retargeted.
+func (cfg Config) Enabled() bool {
+	return cfg.Window > 0
+}
+
+// Header represents the fields of a block header needed to retarget the
+// difficulty. The timestamp is in Unix milliseconds.
+type Header struct {
+	Number     uint64
+	TimeStamp  uint64
+	Difficulty uint16
+}
+
+// IsRetarget reports whether the difficulty is retargeted at the specified
+// block number.
+func IsRetarget(cfg Config, number uint64) bool {
+	return cfg.Enabled() && number > 0 && number%cfg.Window == 0
+}
+
+// WindowStart returns the number of the block the window used to retarget
+// the specified block starts at.
+func WindowStart(cfg Config, number uint64) uint64 {
+	if number < cfg.Window {
+		return 0
+	}
+	return number - cfg.Window
+}
+
+// Next returns the difficulty the block after parent must be mined at. The
+// first header is the block at WindowStart and is only read when the next
+// block is a retarget block.
+//
+// Since one step changes the work by 16 times, the difficulty only moves
+// when the window was mined 4 times faster or slower than the target, which
+// is where the next step gets closer to the target than the current one.
+// The difficulty moves by a single step per window.
+//
+// A window mined exactly 4 times faster keeps the difficulty. Stepping up
+// from there makes the next window exactly 4 times slower, which would step
+// right back down, so only one of the two limits can be inclusive.
+func Next(cfg Config, parent Header, first Header) uint16 {
+	number := parent.Number + 1
+	if !IsRetarget(cfg, number) {
+		return parent.Difficulty
+	}
+
+	// The window covers the intervals between the first block and the
+	// parent. Timestamps that go backwards count as no time at all.
+	intervals := parent.Number - first.Number
+	expected := uint64(cfg.TargetInterval.Milliseconds()) * intervals
+
+	var actual uint64
+	if parent.TimeStamp > first.TimeStamp {
+		actual = parent.TimeStamp - first.TimeStamp
+	}
+
+	difficulty := int(parent.Difficulty)
+	switch {
+	case actual*4 < expected:
+		difficulty++
+	case actual >= expected*4:
+		difficulty--
+	}
+
+	return clamp(difficulty)
+}
+
+// Validate checks the header was mined at the difficulty required after
+// parent. The first header is the block at WindowStart.
+func Validate(cfg Config, header Header, parent Header, first Header) error {
+	exp := Next(cfg, parent, first)
+	if header.Difficulty != exp {
+		return fmt.Errorf("%w: block %d: got[%d] exp[%d]", ErrInvalidDifficulty, header.Number, header.Difficulty, exp)
+	}
+	return nil
+}
+
+// clamp keeps the difficulty within its limits.
+func clamp(difficulty int) uint16 {
+	switch {
+	case difficulty < MinDifficulty:
+		return MinDifficulty
+	case difficulty > MaxDifficulty:
+		return MaxDifficulty
+	}
+	return uint16(difficulty)
+}
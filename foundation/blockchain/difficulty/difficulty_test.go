@@ -0,0 +1,146 @@
+package difficulty_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/difficulty"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Next(t *testing.T) {
+	cfg := difficulty.Config{
+		TargetInterval: 10 * time.Second,
+		Window:         10,
+	}
+
+	// The window of block 20 runs from block 10 to block 19, which is 9
+	// intervals and 90s at the target.
+	first := difficulty.Header{Number: 10, TimeStamp: 1_000_000}
+
+	tt := []struct {
+		name   string
+		cfg    difficulty.Config
+		parent difficulty.Header
+		first  difficulty.Header
+		exp    uint16
+	}{
+		{"disabled", difficulty.Config{}, difficulty.Header{Number: 19, TimeStamp: 1_000_001, Difficulty: 6}, first, 6},
+		{"not a retarget block", cfg, difficulty.Header{Number: 14, TimeStamp: 1_000_001, Difficulty: 6}, first, 6},
+		{"on target", cfg, difficulty.Header{Number: 19, TimeStamp: 1_090_000, Difficulty: 6}, first, 6},
+		{"twice as fast", cfg, difficulty.Header{Number: 19, TimeStamp: 1_045_000, Difficulty: 6}, first, 6},
+		{"twice as slow", cfg, difficulty.Header{Number: 19, TimeStamp: 1_180_000, Difficulty: 6}, first, 6},
+		{"four times as fast", cfg, difficulty.Header{Number: 19, TimeStamp: 1_022_500, Difficulty: 6}, first, 6},
+		{"over four times as fast", cfg, difficulty.Header{Number: 19, TimeStamp: 1_022_499, Difficulty: 6}, first, 7},
+		{"four times as slow", cfg, difficulty.Header{Number: 19, TimeStamp: 1_360_000, Difficulty: 6}, first, 5},
+		{"single step when much faster", cfg, difficulty.Header{Number: 19, TimeStamp: 1_000_001, Difficulty: 6}, first, 7},
+		{"single step when much slower", cfg, difficulty.Header{Number: 19, TimeStamp: 9_000_000, Difficulty: 6}, first, 5},
+		{"time going backwards", cfg, difficulty.Header{Number: 19, TimeStamp: 900_000, Difficulty: 6}, first, 7},
+		{"clamped at max", cfg, difficulty.Header{Number: 19, TimeStamp: 1_000_001, Difficulty: difficulty.MaxDifficulty}, first, difficulty.MaxDifficulty},
+		{"clamped at min", cfg, difficulty.Header{Number: 19, TimeStamp: 9_000_000, Difficulty: difficulty.MinDifficulty}, first, difficulty.MinDifficulty},
+		{"first window", cfg, difficulty.Header{Number: 9, TimeStamp: 1_009_000, Difficulty: 6}, difficulty.Header{Number: 0, TimeStamp: 1_000_000}, 7},
+	}
+
+	t.Log("Given the need to retarget the difficulty toward the block interval.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling %s.", testID, tst.name)
+				{
+					got := difficulty.Next(tst.cfg, tst.parent, tst.first)
+					if got != tst.exp {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected difficulty: got[%d] exp[%d]", failed, testID, got, tst.exp)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected difficulty.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	cfg := difficulty.Config{
+		TargetInterval: 10 * time.Second,
+		Window:         10,
+	}
+
+	first := difficulty.Header{Number: 10, TimeStamp: 1_000_000}
+	parent := difficulty.Header{Number: 19, TimeStamp: 1_010_000, Difficulty: 6}
+
+	tt := []struct {
+		name       string
+		difficulty uint16
+		err        error
+	}{
+		{"required difficulty", 7, nil},
+		{"parent difficulty", 6, difficulty.ErrInvalidDifficulty},
+		{"too high", 8, difficulty.ErrInvalidDifficulty},
+	}
+
+	t.Log("Given the need to validate the difficulty of an imported block.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a block mined at the %s.", testID, tst.name)
+				{
+					header := difficulty.Header{Number: 20, TimeStamp: 1_011_000, Difficulty: tst.difficulty}
+
+					err := difficulty.Validate(cfg, header, parent, first)
+					if !errors.Is(err, tst.err) {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected error: got[%v] exp[%v]", failed, testID, err, tst.err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected error.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_WindowStart(t *testing.T) {
+	cfg := difficulty.Config{Window: 10}
+
+	tt := []struct {
+		name     string
+		number   uint64
+		retarget bool
+		start    uint64
+	}{
+		{"genesis block", 0, false, 0},
+		{"block in the first window", 5, false, 0},
+		{"first retarget block", 10, true, 0},
+		{"block in the second window", 15, false, 5},
+		{"second retarget block", 20, true, 10},
+	}
+
+	t.Log("Given the need to find the window of a block.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling the %s.", testID, tst.name)
+				{
+					if got := difficulty.IsRetarget(cfg, tst.number); got != tst.retarget {
+						t.Fatalf("\t%s\tTest %d:\tShould know if the block is retargeted: got[%v] exp[%v]", failed, testID, got, tst.retarget)
+					}
+					t.Logf("\t%s\tTest %d:\tShould know if the block is retargeted.", success, testID)
+
+					if got := difficulty.WindowStart(cfg, tst.number); got != tst.start {
+						t.Fatalf("\t%s\tTest %d:\tShould get the start of the window: got[%d] exp[%d]", failed, testID, got, tst.start)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the start of the window.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
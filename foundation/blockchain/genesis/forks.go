@@ -0,0 +1,133 @@
+package genesis
+
+import (
+	"fmt"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/difficulty"
+)
+
+// Set of rules a fork can turn on with its flags.
+const (
+	FlagLimitTxData = "limit_tx_data" // Transactions carry at most MaxTxData bytes of data
+)
+
+// MaxTxData is the most data a transaction can carry once FlagLimitTxData
+// is turned on.
+const MaxTxData = 1024
+
+// flags lists the rules a fork can turn on.
+var flags = map[string]bool{
+	FlagLimitTxData: true,
+}
+
+// Fork represents a change to the rules of the chain that activates at a
+// block height. Parameters left out keep the value they had before the
+// fork. Flags name rules that are turned on from the fork onward.
+type Fork struct {
+	Name          string   `json:"name"`
+	Height        uint64   `json:"height"`
+	TransPerBlock *uint16  `json:"trans_per_block,omitempty"`
+	Difficulty    *uint16  `json:"difficulty,omitempty"`
+	MiningReward  *uint64  `json:"mining_reward,omitempty"`
+	GasPrice      *uint64  `json:"gas_price,omitempty"`
+	Flags         []string `json:"flags,omitempty"`
+}
+
+// Rules represents the parameters of the chain in effect at a block height.
+type Rules struct {
+	Height        uint64          `json:"height"`
+	Forks         []string        `json:"forks"`
+	TransPerBlock uint16          `json:"trans_per_block"`
+	Difficulty    uint16          `json:"difficulty"`
+	MiningReward  uint64          `json:"mining_reward"`
+	GasPrice      uint64          `json:"gas_price"`
+	Flags         map[string]bool `json:"flags"`
+}
+
+// IsActive reports whether the named rule is turned on.
+func (r Rules) IsActive(flag string) bool {
+	return r.Flags[flag]
+}
+
+// Rules returns the parameters in effect at the specified block height,
+// which are the genesis parameters with every fork activated at or before
+// that height applied in order.
+func (g Genesis) Rules(height uint64) Rules {
+	rules := Rules{
+		Height:        height,
+		Forks:         []string{},
+		TransPerBlock: g.TransPerBlock,
+		Difficulty:    g.Difficulty,
+		MiningReward:  g.MiningReward,
+		GasPrice:      g.GasPrice,
+		Flags:         make(map[string]bool),
+	}
+
+	for _, fork := range g.Forks {
+		if fork.Height > height {
+			break
+		}
+
+		rules.Forks = append(rules.Forks, fork.Name)
+
+		if fork.TransPerBlock != nil {
+			rules.TransPerBlock = *fork.TransPerBlock
+		}
+		if fork.Difficulty != nil {
+			rules.Difficulty = *fork.Difficulty
+		}
+		if fork.MiningReward != nil {
+			rules.MiningReward = *fork.MiningReward
+		}
+		if fork.GasPrice != nil {
+			rules.GasPrice = *fork.GasPrice
+		}
+		for _, flag := range fork.Flags {
+			rules.Flags[flag] = true
+		}
+	}
+
+	return rules
+}
+
+// validateForks checks the forks are named, activate after the genesis
+// block in increasing order and only set valid parameters.
+func (g Genesis) validateForks() error {
+	names := make(map[string]bool, len(g.Forks))
+
+	var height uint64
+	for i, fork := range g.Forks {
+		if fork.Name == "" {
+			return fmt.Errorf("fork %d: name must be set", i)
+		}
+
+		if names[fork.Name] {
+			return fmt.Errorf("fork %q: name is used twice", fork.Name)
+		}
+		names[fork.Name] = true
+
+		if fork.Height <= height {
+			if i == 0 {
+				return fmt.Errorf("fork %q: height must be above 0", fork.Name)
+			}
+			return fmt.Errorf("fork %q: height %d must be above the height of the fork before it", fork.Name, fork.Height)
+		}
+		height = fork.Height
+
+		if fork.TransPerBlock != nil && *fork.TransPerBlock == 0 {
+			return fmt.Errorf("fork %q: trans_per_block must not be 0", fork.Name)
+		}
+
+		if fork.Difficulty != nil && (*fork.Difficulty < difficulty.MinDifficulty || *fork.Difficulty > difficulty.MaxDifficulty) {
+			return fmt.Errorf("fork %q: difficulty %d must be between %d and %d", fork.Name, *fork.Difficulty, difficulty.MinDifficulty, difficulty.MaxDifficulty)
+		}
+
+		for _, flag := range fork.Flags {
+			if !flags[flag] {
+				return fmt.Errorf("fork %q: unknown flag %q", fork.Name, flag)
+			}
+		}
+	}
+
+	return nil
+}
@@ -1,26 +1,40 @@
+// Package genesis maintains access to the genesis file.
 package genesis
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"os"
+	"sort"
 	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/difficulty"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/signature"
+	"github.com/ethereum/go-ethereum/common"
 )
 
+// Genesis represents the genesis file.
 type Genesis struct {
-	Date          time.Time         `json:"date"`
-	ChainID       uint16            `json:"chain_id"`        // Unique identifier for the blockchain
-	TransPerBlock uint16            `json:"trans_per_block"` // Maximum number of transactions per block
-	Difficulty    uint16            `json:"difficulty"`      // Difficulty of the proof-of-work algorithm
-	MiningReward  uint64            `json:"mining_reward"`   // Reward for mining a block
-	GasPrice      uint64            `json:"gas_price"`       // Fee paid for each transaction
-	Balances      map[string]uint64 `json:"balances"`
+	Date           time.Time         `json:"date"`
+	ChainID        uint16            `json:"chain_id"`                  // Unique identifier for the blockchain
+	TransPerBlock  uint16            `json:"trans_per_block"`           // Maximum number of transactions per block
+	Difficulty     uint16            `json:"difficulty"`                // Difficulty of the proof-of-work algorithm
+	MiningReward   uint64            `json:"mining_reward"`             // Reward for mining a block
+	GasPrice       uint64            `json:"gas_price"`                 // Fee paid for each transaction
+	BlockInterval  uint64            `json:"block_interval,omitempty"`  // Target seconds between blocks
+	RetargetWindow uint64            `json:"retarget_window,omitempty"` // Blocks between difficulty adjustments, 0 keeps it fixed
+	Balances       map[string]uint64 `json:"balances"`
+	Forks          []Fork            `json:"forks,omitempty"` // Rule changes activated at block heights
 }
 
 // =============================================================================
 
-// Opens and consumes the genesis file
-func load() (Genesis, error) {
-	path := "zblock/genesis.json"
+// Load opens and consumes the genesis file at the specified path. The
+// genesis is validated before it is returned.
+func Load(path string) (Genesis, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return Genesis{}, err
@@ -29,8 +43,103 @@ func load() (Genesis, error) {
 	var genesis Genesis
 	err = json.Unmarshal(content, &genesis)
 	if err != nil {
-		return Genesis{}, err
+		return Genesis{}, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	if err := genesis.Validate(); err != nil {
+		return Genesis{}, fmt.Errorf("validating %s: %w", path, err)
 	}
 
 	return genesis, nil
 }
+
+// Validate checks the genesis describes a chain a node can run.
+func (g Genesis) Validate() error {
+	if g.ChainID == 0 {
+		return errors.New("chain_id must be set")
+	}
+
+	if g.TransPerBlock == 0 {
+		return errors.New("trans_per_block must be set")
+	}
+
+	if g.Difficulty < difficulty.MinDifficulty || g.Difficulty > difficulty.MaxDifficulty {
+		return fmt.Errorf("difficulty %d must be between %d and %d", g.Difficulty, difficulty.MinDifficulty, difficulty.MaxDifficulty)
+	}
+
+	if g.RetargetWindow != 0 {
+		if g.RetargetWindow < 2 {
+			return errors.New("retarget_window must cover at least 2 blocks")
+		}
+		if g.BlockInterval == 0 {
+			return errors.New("block_interval must be set to retarget the difficulty")
+		}
+	}
+
+	// The accounts are checked in order so the same genesis always fails
+	// with the same error.
+	accounts := make([]string, 0, len(g.Balances))
+	for account := range g.Balances {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	// Addresses are compared in their checksum form since the same account
+	// can be written with a different case or without the 0x prefix.
+	seen := make(map[string]string, len(g.Balances))
+
+	var supply uint64
+	for _, account := range accounts {
+		if _, err := database.ToAccountID(account); err != nil {
+			return fmt.Errorf("balance of %q: %w", account, err)
+		}
+
+		canonical := common.HexToAddress(account).Hex()
+		if other, exists := seen[canonical]; exists {
+			return fmt.Errorf("balance of %q: same account as %q", account, other)
+		}
+		seen[canonical] = account
+
+		balance := g.Balances[account]
+		if balance > math.MaxUint64-supply {
+			return errors.New("total supply overflows uint64")
+		}
+		supply += balance
+	}
+
+	return g.validateForks()
+}
+
+// Retarget returns how the difficulty is retargeted.
+func (g Genesis) Retarget() difficulty.Config {
+	return difficulty.Config{
+		TargetInterval: time.Duration(g.BlockInterval) * time.Second,
+		Window:         g.RetargetWindow,
+	}
+}
+
+// RequiredDifficulty returns the difficulty the block after parent must be
+// mined at. A fork that sets the difficulty at that height takes precedence
+// over retargeting. The first header is the block at difficulty.WindowStart.
+func (g Genesis) RequiredDifficulty(parent difficulty.Header, first difficulty.Header) uint16 {
+	number := parent.Number + 1
+
+	cfg := g.Retarget()
+	if !cfg.Enabled() {
+		return g.Rules(number).Difficulty
+	}
+
+	for _, fork := range g.Forks {
+		if fork.Height == number && fork.Difficulty != nil {
+			return *fork.Difficulty
+		}
+	}
+
+	return difficulty.Next(cfg, parent, first)
+}
+
+// Returns the hash that identifies the chain built from this genesis.
+// Two nodes only belong to the same chain if their genesis hashes match.
+func (g Genesis) Hash() string {
+	return signature.Hash(g)
+}
@@ -0,0 +1,279 @@
+package genesis_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/difficulty"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/genesis"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// Set of accounts used by the tests.
+const (
+	accountA = "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32"
+	accountB = "0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4"
+)
+
+func Test_Load(t *testing.T) {
+	const hash = "0xf5556f474561d26bef2b4df9195bf62ec8772c2c5e92c0594bb38a0e7089b341"
+
+	t.Log("Given the need to load the genesis of the chain.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen loading the genesis of the zblock folder.", testID)
+		{
+			gen, err := genesis.Load("../../../zblock/genesis.json")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould load the genesis: %s", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould load the genesis.", success, testID)
+
+			if got := gen.Hash(); got != hash {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the hash of the chain: got[%s] exp[%s]", failed, testID, got, hash)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the hash of the chain.", success, testID)
+		}
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	tt := []struct {
+		name   string
+		change func(g *genesis.Genesis)
+		err    string
+	}{
+		{"valid genesis", func(g *genesis.Genesis) {}, ""},
+		{"genesis without a chain id", func(g *genesis.Genesis) { g.ChainID = 0 }, "chain_id must be set"},
+		{"genesis without transactions per block", func(g *genesis.Genesis) { g.TransPerBlock = 0 }, "trans_per_block must be set"},
+		{"genesis with a difficulty too low", func(g *genesis.Genesis) { g.Difficulty = 0 }, "difficulty 0 must be between 1 and 8"},
+		{"genesis with a difficulty too high", func(g *genesis.Genesis) { g.Difficulty = 9 }, "difficulty 9 must be between 1 and 8"},
+		{"genesis with a window too small", func(g *genesis.Genesis) { g.RetargetWindow = 1; g.BlockInterval = 10 }, "retarget_window must cover at least 2 blocks"},
+		{"genesis retargeting without an interval", func(g *genesis.Genesis) { g.RetargetWindow = 10 }, "block_interval must be set to retarget the difficulty"},
+		{"genesis retargeting every 2 blocks", func(g *genesis.Genesis) { g.RetargetWindow = 2; g.BlockInterval = 10 }, ""},
+		{
+			"genesis with an invalid address",
+			func(g *genesis.Genesis) { g.Balances["0x1234"] = 1 },
+			`balance of "0x1234": invalid account format`,
+		},
+		{
+			"genesis with an account written twice",
+			func(g *genesis.Genesis) { g.Balances["0xf01813e4b85e178a83e29b8e7bf26bd830a25f32"] = 1 },
+			`balance of "0xf01813e4b85e178a83e29b8e7bf26bd830a25f32": same account as "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32"`,
+		},
+		{
+			"genesis with a supply that overflows",
+			func(g *genesis.Genesis) { g.Balances[accountA] = math.MaxUint64 },
+			"total supply overflows uint64",
+		},
+	}
+
+	t.Log("Given the need to validate the genesis of a chain.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					gen := newGenesis()
+					tst.change(&gen)
+
+					// Balances are kept in a map, so validate a few times to
+					// make sure the error doesn't depend on the map order.
+					for i := 0; i < 10; i++ {
+						var got string
+						if err := gen.Validate(); err != nil {
+							got = err.Error()
+						}
+
+						if got != tst.err {
+							t.Fatalf("\t%s\tTest %d:\tShould get the expected error: got[%s] exp[%s]", failed, testID, got, tst.err)
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected error.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_Rules(t *testing.T) {
+	gen := newGenesis()
+	gen.Forks = []genesis.Fork{
+		{Name: "cheaper", Height: 10, GasPrice: uint64Ptr(5), Flags: []string{genesis.FlagLimitTxData}},
+		{Name: "bigger", Height: 20, TransPerBlock: uint16Ptr(50), MiningReward: uint64Ptr(350)},
+		{Name: "harder", Height: 30, Difficulty: uint16Ptr(8)},
+	}
+
+	tt := []struct {
+		name  string
+		block uint64
+		exp   genesis.Rules
+		flags []string
+	}{
+		{"block before any fork", 9, genesis.Rules{Forks: []string{}, TransPerBlock: 10, Difficulty: 6, MiningReward: 700, GasPrice: 15}, nil},
+		{"block at the first fork", 10, genesis.Rules{Forks: []string{"cheaper"}, TransPerBlock: 10, Difficulty: 6, MiningReward: 700, GasPrice: 5}, []string{genesis.FlagLimitTxData}},
+		{"block between two forks", 25, genesis.Rules{Forks: []string{"cheaper", "bigger"}, TransPerBlock: 50, Difficulty: 6, MiningReward: 350, GasPrice: 5}, []string{genesis.FlagLimitTxData}},
+		{"block after every fork", 100, genesis.Rules{Forks: []string{"cheaper", "bigger", "harder"}, TransPerBlock: 50, Difficulty: 8, MiningReward: 350, GasPrice: 5}, []string{genesis.FlagLimitTxData}},
+	}
+
+	t.Log("Given the need to know the rules in effect at a block height.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					got := gen.Rules(tst.block)
+
+					if got.Height != tst.block || strings.Join(got.Forks, ",") != strings.Join(tst.exp.Forks, ",") ||
+						got.TransPerBlock != tst.exp.TransPerBlock || got.Difficulty != tst.exp.Difficulty ||
+						got.MiningReward != tst.exp.MiningReward || got.GasPrice != tst.exp.GasPrice {
+						t.Fatalf("\t%s\tTest %d:\tShould get the parameters in effect: got[%+v] exp[%+v]", failed, testID, got, tst.exp)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the parameters in effect.", success, testID)
+
+					active := strings.Join(tst.flags, ",")
+					for _, flag := range []string{genesis.FlagLimitTxData} {
+						if exp := strings.Contains(active, flag); got.IsActive(flag) != exp {
+							t.Fatalf("\t%s\tTest %d:\tShould turn on the %s flag from its fork: got[%v] exp[%v]", failed, testID, flag, got.IsActive(flag), exp)
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould turn on the flags from their fork.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_ValidateForks(t *testing.T) {
+	tt := []struct {
+		name  string
+		forks []genesis.Fork
+		err   string
+	}{
+		{"valid set of forks", []genesis.Fork{{Name: "a", Height: 1}, {Name: "b", Height: 2}}, ""},
+		{"fork without a name", []genesis.Fork{{Height: 1}}, "fork 0: name must be set"},
+		{"name used twice", []genesis.Fork{{Name: "a", Height: 1}, {Name: "a", Height: 2}}, `fork "a": name is used twice`},
+		{"fork at the genesis block", []genesis.Fork{{Name: "a", Height: 0}}, `fork "a": height must be above 0`},
+		{"set of forks out of order", []genesis.Fork{{Name: "a", Height: 5}, {Name: "b", Height: 5}}, `fork "b": height 5 must be above the height of the fork before it`},
+		{"fork without transactions per block", []genesis.Fork{{Name: "a", Height: 1, TransPerBlock: uint16Ptr(0)}}, `fork "a": trans_per_block must not be 0`},
+		{"fork with a difficulty too high", []genesis.Fork{{Name: "a", Height: 1, Difficulty: uint16Ptr(9)}}, `fork "a": difficulty 9 must be between 1 and 8`},
+		{"fork with a known flag", []genesis.Fork{{Name: "a", Height: 1, Flags: []string{genesis.FlagLimitTxData}}}, ""},
+		{"fork with an unknown flag", []genesis.Fork{{Name: "a", Height: 1, Flags: []string{"fees"}}}, `fork "a": unknown flag "fees"`},
+	}
+
+	t.Log("Given the need to validate the forks of a chain.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					gen := newGenesis()
+					gen.Forks = tst.forks
+
+					var got string
+					if err := gen.Validate(); err != nil {
+						got = err.Error()
+					}
+
+					if got != tst.err {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected error: got[%s] exp[%s]", failed, testID, got, tst.err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected error.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_RequiredDifficulty(t *testing.T) {
+	forks := []genesis.Fork{
+		{Name: "harder", Height: 20, Difficulty: uint16Ptr(9)},
+		{Name: "later", Height: 25, Difficulty: uint16Ptr(3)},
+	}
+
+	// The window of block 20 runs from block 10 to block 19 and was mined
+	// much faster than the 10s target, so retargeting alone would move the
+	// difficulty from 6 to 7.
+	first := difficulty.Header{Number: 10, TimeStamp: 1_000_000}
+	fast := difficulty.Header{Number: 19, TimeStamp: 1_000_001, Difficulty: 6}
+
+	tt := []struct {
+		name   string
+		window uint64
+		forks  []genesis.Fork
+		parent difficulty.Header
+		exp    uint16
+	}{
+		{"fixed difficulty without forks", 0, nil, fast, 6},
+		{"fixed difficulty with a fork at the block", 0, forks, fast, 9},
+		{"fixed difficulty after the forks", 0, forks, difficulty.Header{Number: 29, Difficulty: 6}, 3},
+		{"retarget without forks", 10, nil, fast, 7},
+		{"retarget with a fork at the block", 10, forks, fast, 9},
+		{"retarget after a fork", 10, forks, difficulty.Header{Number: 20, TimeStamp: 1_000_002, Difficulty: 9}, 9},
+		{"retarget before a fork in the window", 10, forks, difficulty.Header{Number: 23, TimeStamp: 1_000_002, Difficulty: 9}, 9},
+	}
+
+	t.Log("Given the need to know the difficulty a block must be mined at.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					gen := newGenesis()
+					gen.BlockInterval = 10
+					gen.RetargetWindow = tst.window
+					gen.Forks = tst.forks
+
+					got := gen.RequiredDifficulty(tst.parent, first)
+					if got != tst.exp {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected difficulty: got[%d] exp[%d]", failed, testID, got, tst.exp)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected difficulty.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+// =============================================================================
+
+// newGenesis returns a valid genesis.
+func newGenesis() genesis.Genesis {
+	return genesis.Genesis{
+		Date:          time.Date(2021, time.December, 17, 0, 0, 0, 0, time.UTC),
+		ChainID:       1,
+		TransPerBlock: 10,
+		Difficulty:    6,
+		MiningReward:  700,
+		GasPrice:      15,
+		Balances: map[string]uint64{
+			accountA: 1000000,
+			accountB: 1000000,
+		},
+	}
+}
+
+// uint16Ptr returns a pointer to the value for the parameters of a fork.
+func uint16Ptr(v uint16) *uint16 {
+	return &v
+}
+
+// uint64Ptr returns a pointer to the value for the parameters of a fork.
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
@@ -0,0 +1,300 @@
+// Package mempool maintains the mempool for the blockchain.
+package mempool
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+)
+
+// Set of reasons a transaction is dropped from the mempool.
+const (
+	ReasonReplaced = "replaced by a transaction with a higher tip"
+	ReasonEvicted  = "evicted from the full mempool by a transaction with a higher tip"
+)
+
+// ErrFull is returned when the mempool is full and the transaction doesn't
+// offer a higher tip than any transaction that can be evicted.
+var ErrFull = errors.New("mempool is full, a higher tip is required")
+
+// Dropped identifies a transaction that left the mempool to make room for
+// another one.
+type Dropped struct {
+	Hash   string
+	Reason string
+}
+
+// Mempool represents a cache of transactions organized by account:nonce.
+// The key of every transaction is also indexed by its hash. A mempool with
+// a size holds at most that many transactions.
+type Mempool struct {
+	mu     sync.RWMutex
+	size   int
+	pool   map[string]database.SignedTx
+	hashes map[string]string
+}
+
+// New constructs a new mempool that holds up to size transactions. A size
+// of zero leaves the mempool unbounded.
+func New(size int) *Mempool {
+	return &Mempool{
+		size:   size,
+		pool:   make(map[string]database.SignedTx),
+		hashes: make(map[string]string),
+	}
+}
+
+// Count returns the current number of transaction in the pool.
+func (mp *Mempool) Count() int {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return len(mp.pool)
+}
+
+// Query returns the transaction in the pool with the specified hash.
+func (mp *Mempool) Query(hash string) (database.SignedTx, bool) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	tx, exists := mp.pool[mp.hashes[hash]]
+	return tx, exists
+}
+
+// Tips returns the tips offered by the transactions in the pool sorted from
+// the highest to the lowest.
+func (mp *Mempool) Tips() []uint64 {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	tips := make([]uint64, 0, len(mp.pool))
+	for _, tx := range mp.pool {
+		tips = append(tips, tx.Tip)
+	}
+
+	sort.Slice(tips, func(i, j int) bool {
+		return tips[i] > tips[j]
+	})
+
+	return tips
+}
+
+// Upsert adds or replaces a transaction from the mempool. When the mempool
+// is full the transaction with the lowest tip is evicted to make room. It
+// returns the transactions that were dropped, if any.
+func (mp *Mempool) Upsert(tx database.SignedTx) ([]Dropped, error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	return mp.upsert(tx)
+}
+
+// UpsertAll adds or replaces all the transactions or none of them. It returns
+// the transactions that were dropped, or the error of every transaction that
+// can't be added.
+func (mp *Mempool) UpsertAll(txs []database.SignedTx) ([]Dropped, []error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	// Add the batch to a copy of the pool so the pool is only changed when
+	// every transaction made it.
+	next := mp.clone()
+
+	var dropped []Dropped
+	errs := make([]error, len(txs))
+	failed := false
+
+	for i, tx := range txs {
+		d, err := next.upsert(tx)
+		if err != nil {
+			errs[i] = err
+			failed = true
+			continue
+		}
+		dropped = append(dropped, d...)
+	}
+
+	if failed {
+		return nil, errs
+	}
+
+	mp.pool = next.pool
+	mp.hashes = next.hashes
+
+	return dropped, nil
+}
+
+// Delete removes the transaction from the pool.
+func (mp *Mempool) Delete(tx database.SignedTx) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	key := mapKey(tx)
+	if etx, exists := mp.pool[key]; exists {
+		delete(mp.hashes, etx.Hash())
+		delete(mp.pool, key)
+	}
+}
+
+// Prune removes the transactions the stale function reports as stale, like
+// the ones with a nonce already used, and returns them.
+func (mp *Mempool) Prune(stale func(tx database.SignedTx) bool) []database.SignedTx {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	var pruned []database.SignedTx
+	for key, tx := range mp.pool {
+		if stale(tx) {
+			delete(mp.hashes, tx.Hash())
+			delete(mp.pool, key)
+			pruned = append(pruned, tx)
+		}
+	}
+
+	return pruned
+}
+
+// PickBest returns up to howMany transactions from the pool, best tips
+// first. The transactions of an account are returned in nonce order, so a
+// transaction is only picked after the ones it depends on.
+func (mp *Mempool) PickBest(howMany int) []database.SignedTx {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	// Queue the transactions of every account in nonce order.
+	queues := make(map[database.AccountID][]database.SignedTx)
+	for _, tx := range mp.pool {
+		queues[tx.FromID] = append(queues[tx.FromID], tx)
+	}
+	for _, queue := range queues {
+		sort.Slice(queue, func(i, j int) bool {
+			return queue[i].Nonce < queue[j].Nonce
+		})
+	}
+
+	// Take the best tip from the head of the queues until there are enough
+	// transactions. Ties go to the lowest hash so every node picks alike.
+	var picked []database.SignedTx
+	for len(picked) < howMany && len(queues) > 0 {
+		var best database.AccountID
+		for accountID, queue := range queues {
+			if best == "" || better(queue[0], queues[best][0]) {
+				best = accountID
+			}
+		}
+
+		picked = append(picked, queues[best][0])
+		if queues[best] = queues[best][1:]; len(queues[best]) == 0 {
+			delete(queues, best)
+		}
+	}
+
+	return picked
+}
+
+// upsert adds or replaces the transaction, evicting a transaction when the
+// pool is full. The caller must hold the lock.
+func (mp *Mempool) upsert(tx database.SignedTx) ([]Dropped, error) {
+	key := mapKey(tx)
+
+	// Ethereum requires a 10% bump in the tip to replace an existing
+	// transaction in the mempool and so do we. We want to limit users
+	// from this sort of behavior.
+	if etx, exists := mp.pool[key]; exists {
+		if tx.Tip < uint64(math.Round(float64(etx.Tip)*1.10)) {
+			return nil, errors.New("replacing a transaction requires a 10% bump in the tip")
+		}
+
+		delete(mp.hashes, etx.Hash())
+		mp.put(tx)
+
+		return []Dropped{{Hash: etx.Hash(), Reason: ReasonReplaced}}, nil
+	}
+
+	var dropped []Dropped
+	if mp.size > 0 && len(mp.pool) >= mp.size {
+		evict, exists := mp.evictable(tx.FromID)
+		if !exists || tx.Tip <= evict.Tip {
+			return nil, ErrFull
+		}
+
+		delete(mp.hashes, evict.Hash())
+		delete(mp.pool, mapKey(evict))
+		dropped = append(dropped, Dropped{Hash: evict.Hash(), Reason: ReasonEvicted})
+	}
+
+	mp.put(tx)
+
+	return dropped, nil
+}
+
+// evictable returns the transaction with the lowest tip among the last
+// transaction of every account, so no transaction left is waiting on an
+// evicted one. The transactions of the account adding a transaction are
+// never evicted for the same reason.
+func (mp *Mempool) evictable(fromID database.AccountID) (database.SignedTx, bool) {
+	last := make(map[database.AccountID]database.SignedTx)
+	for _, tx := range mp.pool {
+		if tx.FromID == fromID {
+			continue
+		}
+		if etx, exists := last[tx.FromID]; !exists || tx.Nonce > etx.Nonce {
+			last[tx.FromID] = tx
+		}
+	}
+
+	var worst database.SignedTx
+	var exists bool
+	for _, tx := range last {
+		if !exists || better(worst, tx) {
+			worst = tx
+			exists = true
+		}
+	}
+
+	return worst, exists
+}
+
+// put stores the transaction in the pool. The caller must hold the lock.
+func (mp *Mempool) put(tx database.SignedTx) {
+	key := mapKey(tx)
+	mp.pool[key] = tx
+	mp.hashes[tx.Hash()] = key
+}
+
+// clone returns a mempool holding the same transactions.
+func (mp *Mempool) clone() *Mempool {
+	next := Mempool{
+		size:   mp.size,
+		pool:   make(map[string]database.SignedTx, len(mp.pool)),
+		hashes: make(map[string]string, len(mp.hashes)),
+	}
+
+	for key, tx := range mp.pool {
+		next.pool[key] = tx
+	}
+	for hash, key := range mp.hashes {
+		next.hashes[hash] = key
+	}
+
+	return &next
+}
+
+// =============================================================================
+
+// better reports whether transaction a should be picked before b.
+func better(a database.SignedTx, b database.SignedTx) bool {
+	if a.Tip != b.Tip {
+		return a.Tip > b.Tip
+	}
+	return a.Hash() < b.Hash()
+}
+
+// mapKey is used to generate the map key.
+func mapKey(tx database.SignedTx) string {
+	return fmt.Sprintf("%s:%d", tx.FromID, tx.Nonce)
+}
@@ -0,0 +1,280 @@
+package mempool_test
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/mempool"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Upsert(t *testing.T) {
+	privateKey := newKey(t)
+	original := signTx(t, privateKey, 1, 20)
+
+	tt := []struct {
+		name     string
+		tx       database.SignedTx
+		success  bool
+		replaced bool
+	}{
+		{"new nonce", signTx(t, privateKey, 2, 10), true, false},
+		{"replacement with a small bump", signTx(t, privateKey, 1, 21), false, false},
+		{"replacement with a 10% bump", signTx(t, privateKey, 1, 22), true, true},
+	}
+
+	t.Log("Given the need to add transactions to the mempool.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					mp := mempool.New(0)
+					if _, err := mp.Upsert(original); err != nil {
+						t.Fatalf("upserting tx: %s", err)
+					}
+
+					dropped, err := mp.Upsert(tst.tx)
+					if success := err == nil; success != tst.success {
+						t.Fatalf("\t%s\tTest %d:\tShould add as expected: got[%v] exp[%v] err[%v]", failed, testID, success, tst.success, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould add as expected.", success, testID)
+
+					replaced := len(dropped) == 1 && dropped[0].Hash == original.Hash() && dropped[0].Reason == mempool.ReasonReplaced
+					if got := replaced; got != tst.replaced {
+						t.Fatalf("\t%s\tTest %d:\tShould report the replaced transaction: got[%v] exp[%v]", failed, testID, got, tst.replaced)
+					}
+					t.Logf("\t%s\tTest %d:\tShould report the replaced transaction.", success, testID)
+
+					_, exists := mp.Query(original.Hash())
+					if exists == tst.replaced {
+						t.Fatalf("\t%s\tTest %d:\tShould only find the original transaction when it stays: got[%v]", failed, testID, exists)
+					}
+					if _, exists := mp.Query(tst.tx.Hash()); exists != tst.success {
+						t.Fatalf("\t%s\tTest %d:\tShould find the new transaction by hash when it was added: got[%v]", failed, testID, exists)
+					}
+					t.Logf("\t%s\tTest %d:\tShould find the transactions by hash.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_UpsertAll(t *testing.T) {
+	privateKey := newKey(t)
+	existing := signTx(t, privateKey, 1, 20)
+
+	tt := []struct {
+		name  string
+		txs   []database.SignedTx
+		count int
+	}{
+		{"valid batch", []database.SignedTx{signTx(t, privateKey, 2, 1), signTx(t, privateKey, 3, 1)}, 3},
+		{"batch with a small bump", []database.SignedTx{signTx(t, privateKey, 2, 1), signTx(t, privateKey, 1, 21)}, 1},
+	}
+
+	t.Log("Given the need to add a batch of transactions at once.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					mp := mempool.New(0)
+					if _, err := mp.Upsert(existing); err != nil {
+						t.Fatalf("upserting tx: %s", err)
+					}
+
+					mp.UpsertAll(tst.txs)
+
+					if count := mp.Count(); count != tst.count {
+						t.Fatalf("\t%s\tTest %d:\tShould add all or none of the batch: got[%d] exp[%d]", failed, testID, count, tst.count)
+					}
+					t.Logf("\t%s\tTest %d:\tShould add all or none of the batch.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_UpsertFull(t *testing.T) {
+	a := newKey(t)
+	b := newKey(t)
+
+	tt := []struct {
+		name    string
+		tx      database.SignedTx
+		success bool
+		evicted int
+	}{
+		{"transaction with a higher tip", signTx(t, newKey(t), 1, 30), true, 1},
+		{"transaction with the lowest tip", signTx(t, newKey(t), 1, 5), false, -1},
+		{"transaction from the account with the lowest tip", signTx(t, a, 3, 30), true, 2},
+	}
+
+	t.Log("Given the need to bound the size of the mempool.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					// The first transaction of account a has the lowest tip
+					// but the second one depends on it, so the second one is
+					// evicted first.
+					txs := []database.SignedTx{
+						signTx(t, a, 1, 5),
+						signTx(t, a, 2, 10),
+						signTx(t, b, 1, 20),
+					}
+
+					mp := mempool.New(len(txs))
+					for _, tx := range txs {
+						if _, err := mp.Upsert(tx); err != nil {
+							t.Fatalf("upserting tx: %s", err)
+						}
+					}
+
+					dropped, err := mp.Upsert(tst.tx)
+					if success := err == nil; success != tst.success {
+						t.Fatalf("\t%s\tTest %d:\tShould add as expected: got[%v] exp[%v] err[%v]", failed, testID, success, tst.success, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould add as expected.", success, testID)
+
+					if tst.evicted >= 0 {
+						if len(dropped) != 1 || dropped[0].Hash != txs[tst.evicted].Hash() || dropped[0].Reason != mempool.ReasonEvicted {
+							t.Fatalf("\t%s\tTest %d:\tShould evict transaction %d: got%v", failed, testID, tst.evicted, dropped)
+						}
+						if _, exists := mp.Query(txs[tst.evicted].Hash()); exists {
+							t.Fatalf("\t%s\tTest %d:\tShould evict transaction %d.", failed, testID, tst.evicted)
+						}
+						t.Logf("\t%s\tTest %d:\tShould evict transaction %d.", success, testID, tst.evicted)
+					}
+
+					if count := mp.Count(); count != len(txs) {
+						t.Fatalf("\t%s\tTest %d:\tShould hold no more than the size: got[%d] exp[%d]", failed, testID, count, len(txs))
+					}
+					t.Logf("\t%s\tTest %d:\tShould hold no more than the size.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_Prune(t *testing.T) {
+	privateKey := newKey(t)
+
+	t.Log("Given the need to remove the transactions that can't be mined.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the nonces of transactions were used.", testID)
+		{
+			mp := mempool.New(0)
+
+			var txs []database.SignedTx
+			for nonce := uint64(1); nonce <= 3; nonce++ {
+				tx := signTx(t, privateKey, nonce, 1)
+				if _, err := mp.Upsert(tx); err != nil {
+					t.Fatalf("upserting tx: %s", err)
+				}
+				txs = append(txs, tx)
+			}
+
+			pruned := mp.Prune(func(tx database.SignedTx) bool {
+				return tx.Nonce <= 2
+			})
+
+			if len(pruned) != 2 || mp.Count() != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould prune the stale transactions: got[%d] left[%d]", failed, testID, len(pruned), mp.Count())
+			}
+			if _, exists := mp.Query(txs[2].Hash()); !exists {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the other transactions.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould prune the stale transactions.", success, testID)
+		}
+	}
+}
+
+func Test_PickBest(t *testing.T) {
+	a := newKey(t)
+	b := newKey(t)
+
+	t.Log("Given the need to pick the transactions to mine.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen two accounts have pending transactions.", testID)
+		{
+			mp := mempool.New(0)
+
+			// The second transaction of account a has the best tip but can
+			// only be picked after the first one.
+			txs := []database.SignedTx{
+				signTx(t, a, 1, 1),
+				signTx(t, a, 2, 50),
+				signTx(t, b, 1, 20),
+			}
+			for _, tx := range txs {
+				if _, err := mp.Upsert(tx); err != nil {
+					t.Fatalf("upserting tx: %s", err)
+				}
+			}
+
+			picked := mp.PickBest(3)
+			exp := []database.SignedTx{txs[2], txs[0], txs[1]}
+			for i := range exp {
+				if i >= len(picked) || picked[i].Hash() != exp[i].Hash() {
+					t.Fatalf("\t%s\tTest %d:\tShould pick by tip in nonce order: got %d transactions", failed, testID, len(picked))
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould pick by tip in nonce order.", success, testID)
+
+			mp.Delete(txs[2])
+			if _, exists := mp.Query(txs[2].Hash()); exists || mp.Count() != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould delete the transaction.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould delete the transaction.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// newKey generates the key of a new account.
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+
+	return privateKey
+}
+
+// signTx signs a transaction with the specified nonce and tip.
+func signTx(t *testing.T, privateKey *ecdsa.PrivateKey, nonce uint64, tip uint64) database.SignedTx {
+	t.Helper()
+
+	tx, err := database.NewTx(1, nonce, database.PublicKeyToAccountID(privateKey.PublicKey), "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76", 10, tip, nil)
+	if err != nil {
+		t.Fatalf("constructing tx: %s", err)
+	}
+
+	signedTx, err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("signing tx: %s", err)
+	}
+
+	return signedTx
+}
@@ -0,0 +1,52 @@
+package peer
+
+import (
+	"fmt"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/genesis"
+)
+
+// ProtocolVersion is the version of the node to node protocol. Nodes only
+// peer with nodes speaking the same version.
+const ProtocolVersion = 1
+
+// Handshake represents the chain identity a node presents to another node
+// before the two are allowed to peer. The signature is only set on the
+// handshake a node replies with so the caller can verify which account
+// runs the node it reached.
+type Handshake struct {
+	ChainID         uint16 `json:"chain_id"`
+	GenesisHash     string `json:"genesis_hash"`
+	ProtocolVersion uint16 `json:"protocol_version"`
+	Height          uint64 `json:"height"`
+	Signature       string `json:"signature,omitempty"`
+}
+
+// NewHandshake constructs the handshake for a node running the chain
+// described by the genesis at the specified height.
+func NewHandshake(gen genesis.Genesis, height uint64) Handshake {
+	return Handshake{
+		ChainID:         gen.ChainID,
+		GenesisHash:     gen.Hash(),
+		ProtocolVersion: ProtocolVersion,
+		Height:          height,
+	}
+}
+
+// Match validates the handshake of another node belongs to the same chain
+// and speaks the same protocol version.
+func (hs Handshake) Match(other Handshake) error {
+	if hs.ChainID != other.ChainID {
+		return fmt.Errorf("chain id mismatch, got[%d] exp[%d]", other.ChainID, hs.ChainID)
+	}
+
+	if hs.GenesisHash != other.GenesisHash {
+		return fmt.Errorf("genesis hash mismatch, got[%s] exp[%s]", other.GenesisHash, hs.GenesisHash)
+	}
+
+	if hs.ProtocolVersion != other.ProtocolVersion {
+		return fmt.Errorf("protocol version mismatch, got[%d] exp[%d]", other.ProtocolVersion, hs.ProtocolVersion)
+	}
+
+	return nil
+}
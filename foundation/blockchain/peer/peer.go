@@ -0,0 +1,345 @@
+// Package peer maintains the peer related information such as the set
+// of known peers and their reputation.
+package peer
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+)
+
+// Set of penalties applied to a peer's score when it misbehaves.
+const (
+	PenaltyInvalidBlock = 50
+	PenaltyProtocol     = 10
+)
+
+// Set of changes reported by the peer change event.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeBanned  = "banned"
+)
+
+// InitialScore is the score every peer starts with and is restored to once
+// a ban expires.
+const InitialScore = 100
+
+// Peer represents information about a node in the network.
+type Peer struct {
+	Host string
+}
+
+// New constructs a new info value.
+func New(host string) Peer {
+	return Peer{
+		Host: host,
+	}
+}
+
+// Match validates if the specified host matches this node.
+func (p Peer) Match(host string) bool {
+	return p.Host == host
+}
+
+// =============================================================================
+
+// Status represents the reputation of a peer at a given point in time.
+type Status struct {
+	Host        string             `json:"host"`
+	AccountID   database.AccountID `json:"account_id"`
+	Score       int                `json:"score"`
+	Height      uint64             `json:"height"`
+	Banned      bool               `json:"banned"`
+	BannedUntil *time.Time         `json:"banned_until,omitempty"`
+	LastReason  string             `json:"last_reason,omitempty"`
+}
+
+// info represents what is known about a single peer. The account is the one
+// whose key the peer proved to hold when it was added.
+type info struct {
+	accountID database.AccountID
+	height    uint64
+}
+
+// reputation tracks the score of the account running one or more peers.
+type reputation struct {
+	score       int
+	bannedUntil time.Time
+	lastReason  string
+}
+
+// Change is the data of the event sent when the peer set changes.
+type Change struct {
+	Host      string             `json:"host"`
+	AccountID database.AccountID `json:"account_id"`
+	Change    string             `json:"change"`
+	Reason    string             `json:"reason,omitempty"`
+}
+
+// =============================================================================
+
+// PeerSet represents the data representation to maintain a set of known
+// peers and their reputation. The reputation belongs to the account that
+// runs a peer, since the account is verified on every request while the
+// host is only what the peer reports about itself. Accounts whose score
+// falls to the ban threshold are banned for the ban duration and their
+// peers are left out of the peer list used for gossip and sync.
+type PeerSet struct {
+	mu           sync.Mutex
+	set          map[Peer]*info
+	reputations  map[database.AccountID]*reputation
+	banThreshold int
+	banDuration  time.Duration
+	evts         *events.Events
+}
+
+// NewPeerSet constructs a new info set to manage node peer information.
+// Changes to the set are sent as events.
+func NewPeerSet(banThreshold int, banDuration time.Duration, evts *events.Events) *PeerSet {
+	return &PeerSet{
+		set:          make(map[Peer]*info),
+		reputations:  make(map[database.AccountID]*reputation),
+		banThreshold: banThreshold,
+		banDuration:  banDuration,
+		evts:         evts,
+	}
+}
+
+// Add adds a new node to the set as run by the specified account. It
+// returns false if the peer is already known to be run by that account.
+func (ps *PeerSet) Add(peer Peer, accountID database.AccountID) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	inf, exists := ps.set[peer]
+	if exists && inf.accountID == accountID {
+		return false
+	}
+
+	ps.set[peer] = &info{accountID: accountID}
+	if exists {
+		ps.forget(inf.accountID)
+	}
+
+	if _, exists := ps.reputations[accountID]; !exists {
+		ps.reputations[accountID] = &reputation{score: InitialScore}
+	}
+
+	ps.send(peer, accountID, ChangeAdded, "")
+	return true
+}
+
+// Remove removes a node from the set.
+func (ps *PeerSet) Remove(peer Peer) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	inf, exists := ps.set[peer]
+	if !exists {
+		return
+	}
+
+	delete(ps.set, peer)
+	ps.forget(inf.accountID)
+	ps.send(peer, inf.accountID, ChangeRemoved, "")
+}
+
+// AccountID returns the account that runs the specified peer.
+func (ps *PeerSet) AccountID(peer Peer) (database.AccountID, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	inf, exists := ps.set[peer]
+	if !exists {
+		return "", false
+	}
+
+	return inf.accountID, true
+}
+
+// SetHeight records the latest block height reported by a known peer.
+func (ps *PeerSet) SetHeight(peer Peer, height uint64) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	inf, exists := ps.set[peer]
+	if !exists {
+		return
+	}
+
+	inf.height = height
+}
+
+// MaxHeight returns the highest block height reported by the known peers
+// that are not banned. It returns false when there is no such peer.
+func (ps *PeerSet) MaxHeight() (uint64, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	now := time.Now()
+
+	var height uint64
+	var found bool
+	for _, inf := range ps.set {
+		if ps.banned(inf.accountID, now) {
+			continue
+		}
+
+		found = true
+		if inf.height > height {
+			height = inf.height
+		}
+	}
+
+	return height, found
+}
+
+// Copy returns a list of the known peers excluding the specified host and
+// any peer that is currently banned.
+func (ps *PeerSet) Copy(host string) []Peer {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	now := time.Now()
+
+	var peers []Peer
+	for peer, inf := range ps.set {
+		if peer.Match(host) || ps.banned(inf.accountID, now) {
+			continue
+		}
+		peers = append(peers, peer)
+	}
+
+	return peers
+}
+
+// Penalize lowers the score of the specified account for the given reason.
+// Accounts that don't run a known peer are ignored. If the score drops to
+// the ban threshold the account is banned. It returns true if the account
+// is banned as a result of the call.
+func (ps *PeerSet) Penalize(accountID database.AccountID, penalty int, reason string) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	rep, exists := ps.reputations[accountID]
+	if !exists {
+		return false
+	}
+
+	now := time.Now()
+	if ps.banned(accountID, now) {
+		return false
+	}
+
+	rep.score -= penalty
+	rep.lastReason = reason
+
+	if rep.score > ps.banThreshold {
+		return false
+	}
+
+	rep.bannedUntil = now.Add(ps.banDuration)
+	for peer, inf := range ps.set {
+		if inf.accountID == accountID {
+			ps.send(peer, accountID, ChangeBanned, reason)
+		}
+	}
+
+	return true
+}
+
+// IsBanned reports whether the specified account is currently banned.
+func (ps *PeerSet) IsBanned(accountID database.AccountID) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return ps.banned(accountID, time.Now())
+}
+
+// Statuses returns the current reputation of every known peer sorted by host.
+func (ps *PeerSet) Statuses() []Status {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	now := time.Now()
+
+	statuses := make([]Status, 0, len(ps.set))
+	for peer, inf := range ps.set {
+		rep := ps.reputations[inf.accountID]
+
+		status := Status{
+			Host:       peer.Host,
+			AccountID:  inf.accountID,
+			Score:      rep.score,
+			Height:     inf.height,
+			Banned:     ps.banned(inf.accountID, now),
+			LastReason: rep.lastReason,
+		}
+		if status.Banned {
+			bannedUntil := rep.bannedUntil
+			status.BannedUntil = &bannedUntil
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Host < statuses[j].Host
+	})
+
+	return statuses
+}
+
+// banned reports whether the account is under an active ban. Once a ban
+// has expired the score is restored so the account can be used again. The
+// caller must hold the write lock.
+func (ps *PeerSet) banned(accountID database.AccountID, now time.Time) bool {
+	rep, exists := ps.reputations[accountID]
+	if !exists || rep.bannedUntil.IsZero() {
+		return false
+	}
+
+	if now.Before(rep.bannedUntil) {
+		return true
+	}
+
+	rep.score = InitialScore
+	rep.bannedUntil = time.Time{}
+	return false
+}
+
+// forget drops the reputation of an account that no longer runs a known
+// peer. A banned account is kept until its ban expires so removing and
+// adding its peer back doesn't lift the ban. The caller must hold the
+// write lock.
+func (ps *PeerSet) forget(accountID database.AccountID) {
+	for _, inf := range ps.set {
+		if inf.accountID == accountID {
+			return
+		}
+	}
+
+	if ps.banned(accountID, time.Now()) {
+		return
+	}
+
+	delete(ps.reputations, accountID)
+}
+
+// send reports a change to the set as an event.
+func (ps *PeerSet) send(peer Peer, accountID database.AccountID, change string, reason string) {
+	if ps.evts == nil {
+		return
+	}
+
+	data := Change{
+		Host:      peer.Host,
+		AccountID: accountID,
+		Change:    change,
+		Reason:    reason,
+	}
+	ps.evts.Send(events.NewEvent(events.TypePeerChange, data))
+}
@@ -0,0 +1,192 @@
+package peer_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+const (
+	accountA = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	accountB = database.AccountID("0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+)
+
+var (
+	hostA = peer.New("0.0.0.0:9080")
+	hostB = peer.New("0.0.0.0:9081")
+)
+
+func Test_PeerSet(t *testing.T) {
+	tt := []struct {
+		name        string
+		banDuration time.Duration
+		run         func(ps *peer.PeerSet)
+		banned      bool
+		peers       []string
+		statuses    int
+		height      uint64
+	}{
+		{
+			name:        "peer added",
+			banDuration: time.Minute,
+			run: func(ps *peer.PeerSet) {
+				ps.Add(hostA, accountA)
+				ps.SetHeight(hostA, 5)
+			},
+			peers:    []string{hostA.Host},
+			statuses: 1,
+			height:   5,
+		},
+		{
+			name:        "penalty for an unknown account",
+			banDuration: time.Minute,
+			run: func(ps *peer.PeerSet) {
+				ps.Penalize(accountA, peer.InitialScore, "invalid")
+				ps.SetHeight(hostA, 5)
+			},
+			statuses: 0,
+		},
+		{
+			name:        "penalties down to the threshold",
+			banDuration: time.Minute,
+			run: func(ps *peer.PeerSet) {
+				ps.Add(hostA, accountA)
+				ps.Penalize(accountA, peer.InitialScore/2, "invalid")
+				ps.Penalize(accountA, peer.InitialScore/2, "invalid")
+			},
+			banned:   true,
+			statuses: 1,
+		},
+		{
+			name:        "ban covering every host of the account",
+			banDuration: time.Minute,
+			run: func(ps *peer.PeerSet) {
+				ps.Add(hostA, accountA)
+				ps.Add(hostB, accountA)
+				ps.SetHeight(hostB, 9)
+				ps.Penalize(accountA, peer.InitialScore, "invalid")
+			},
+			banned:   true,
+			statuses: 2,
+		},
+		{
+			name:        "ban of another account",
+			banDuration: time.Minute,
+			run: func(ps *peer.PeerSet) {
+				ps.Add(hostA, accountA)
+				ps.Add(hostB, accountB)
+				ps.SetHeight(hostA, 5)
+				ps.SetHeight(hostB, 9)
+				ps.Penalize(accountB, peer.InitialScore, "invalid")
+			},
+			peers:    []string{hostA.Host},
+			statuses: 2,
+			height:   5,
+		},
+		{
+			name:        "peer removed and added back while banned",
+			banDuration: time.Minute,
+			run: func(ps *peer.PeerSet) {
+				ps.Add(hostA, accountA)
+				ps.Penalize(accountA, peer.InitialScore, "invalid")
+				ps.Remove(hostA)
+				ps.Add(hostA, accountA)
+			},
+			banned:   true,
+			statuses: 1,
+		},
+		{
+			name:        "expired ban",
+			banDuration: 0,
+			run: func(ps *peer.PeerSet) {
+				ps.Add(hostA, accountA)
+				ps.Penalize(accountA, peer.InitialScore, "invalid")
+			},
+			peers:    []string{hostA.Host},
+			statuses: 1,
+		},
+		{
+			name:        "host taken over by another account",
+			banDuration: time.Minute,
+			run: func(ps *peer.PeerSet) {
+				ps.Add(hostA, accountB)
+				ps.Add(hostA, accountA)
+				ps.Penalize(accountB, peer.InitialScore, "invalid")
+			},
+			peers:    []string{hostA.Host},
+			statuses: 1,
+		},
+	}
+
+	t.Log("Given the need to track the reputation of peers by account.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					ps := peer.NewPeerSet(0, tst.banDuration, nil)
+					tst.run(ps)
+
+					if banned := ps.IsBanned(accountA); banned != tst.banned {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected ban: got[%v] exp[%v]", failed, testID, banned, tst.banned)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected ban.", success, testID)
+
+					var peers []string
+					for _, pr := range ps.Copy("") {
+						peers = append(peers, pr.Host)
+					}
+					sort.Strings(peers)
+
+					if fmt.Sprint(peers) != fmt.Sprint(tst.peers) {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected peers: got%v exp%v", failed, testID, peers, tst.peers)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected peers.", success, testID)
+
+					if statuses := len(ps.Statuses()); statuses != tst.statuses {
+						t.Fatalf("\t%s\tTest %d:\tShould only track known peers: got[%d] exp[%d]", failed, testID, statuses, tst.statuses)
+					}
+					t.Logf("\t%s\tTest %d:\tShould only track known peers.", success, testID)
+
+					if height, _ := ps.MaxHeight(); height != tst.height {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected height: got[%d] exp[%d]", failed, testID, height, tst.height)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected height.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_PeerSetRemove(t *testing.T) {
+	t.Log("Given the need to forget the reputation of removed peers.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a penalized peer is removed and added back.", testID)
+		{
+			ps := peer.NewPeerSet(0, time.Minute, nil)
+			ps.Add(hostA, accountA)
+			ps.Penalize(accountA, peer.PenaltyProtocol, "invalid")
+			ps.Remove(hostA)
+			ps.Add(hostA, accountA)
+
+			statuses := ps.Statuses()
+			if len(statuses) != 1 || statuses[0].Score != peer.InitialScore {
+				t.Fatalf("\t%s\tTest %d:\tShould start over with the initial score: got%+v", failed, testID, statuses)
+			}
+			t.Logf("\t%s\tTest %d:\tShould start over with the initial score.", success, testID)
+		}
+	}
+}
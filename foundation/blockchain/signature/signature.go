@@ -2,6 +2,7 @@ package signature
 
 import (
 	"crypto/ecdsa"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,11 +12,25 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
+// ZeroHash represents a hash code of zeros.
+const ZeroHash string = "0x0000000000000000000000000000000000000000000000000000000000000000"
+
 // sartoriCoinID is an arbitrary number for signing messages. This will make it
 // clear that the signature comes for the Sartori blockchain.
 // Ethereum and Bitcoin do this as well, but they use the value of 27.
 const sartoriCoinID = 29
 
+// Returns a unique string for the value.
+func Hash(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ZeroHash
+	}
+
+	hash := sha256.Sum256(data)
+	return hexutil.Encode(hash[:])
+}
+
 // Returns a hash of 32 bytes that represents this data with
 // the salt embedded into the final hash
 func salt(value any) ([]byte, error) {
@@ -143,3 +158,22 @@ func ToSignatureBytesWithSartoriCoinID(v, r, s *big.Int) []byte {
 func SignatureString(v, r, s *big.Int) string {
 	return hexutil.Encode(ToSignatureBytesWithSartoriCoinID(v, r, s))
 }
+
+// Converts a hex representation of the signature produced by SignatureString
+// into its R, S and V parts.
+func ToVRSFromHexSignature(sigStr string) (v, r, s *big.Int, err error) {
+	sig, err := hexutil.Decode(sigStr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if len(sig) != crypto.SignatureLength {
+		return nil, nil, nil, errors.New("invalid signature length")
+	}
+
+	r = big.NewInt(0).SetBytes(sig[:32])
+	s = big.NewInt(0).SetBytes(sig[32:64])
+	v = big.NewInt(0).SetBytes([]byte{sig[64]})
+
+	return v, r, s, nil
+}
@@ -0,0 +1,640 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/difficulty"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/genesis"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/signature"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
+)
+
+// maxFutureDrift is how far ahead of the local clock the timestamp of a
+// block is allowed to be.
+const maxFutureDrift = 2 * time.Minute
+
+// ErrNotLonger is returned when imported blocks don't take the chain past
+// its latest block. The chain only switches to a longer chain.
+var ErrNotLonger = errors.New("blocks don't extend the chain past the latest block")
+
+// NewBlock is the data of the event sent when a block is added to the chain.
+type NewBlock struct {
+	Number     uint64 `json:"number"`
+	Hash       string `json:"hash"`
+	TransCount int    `json:"trans_count"`
+}
+
+// Reorg is the data of the event sent when blocks of the chain are replaced
+// by the blocks of a longer chain. The blocks after the ancestor are gone.
+type Reorg struct {
+	Ancestor uint64 `json:"ancestor"`
+	OldTip   uint64 `json:"old_tip"`
+	NewTip   uint64 `json:"new_tip"`
+}
+
+// QueryHeaders returns up to limit headers of the chain starting at the
+// specified block number.
+func (s *State) QueryHeaders(from uint64, limit int) []database.BlockHeader {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if from >= uint64(len(s.headers)) || limit <= 0 {
+		return []database.BlockHeader{}
+	}
+
+	to := from + uint64(limit)
+	if to > uint64(len(s.headers)) {
+		to = uint64(len(s.headers))
+	}
+
+	return append([]database.BlockHeader{}, s.headers[from:to]...)
+}
+
+// QueryBlocks returns up to limit blocks of the chain starting at the
+// specified block number. The genesis block has no transactions and is
+// never returned.
+func (s *State) QueryBlocks(from uint64, limit int) ([]database.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if from == 0 {
+		return nil, errors.New("block 0 is the genesis block")
+	}
+
+	blocks := []database.Block{}
+	for number := from; number < uint64(len(s.headers)) && len(blocks) < limit; number++ {
+		block, err := s.storage.Read(number)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
+// QueryBlock returns the block of the chain with the specified number. The
+// genesis block is returned with its header only.
+func (s *State) QueryBlock(number uint64) (database.Block, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if number >= uint64(len(s.headers)) {
+		return database.Block{}, false
+	}
+
+	if number == 0 {
+		return database.Block{Header: s.headers[0]}, true
+	}
+
+	block, err := s.storage.Read(number)
+	if err != nil {
+		return database.Block{}, false
+	}
+
+	return block, true
+}
+
+// ValidateHeaders checks the headers form a valid chain on top of the block
+// of the chain before the first header. Errors caused by an invalid header
+// wrap database.ErrInvalidBlock.
+func (s *State) ValidateHeaders(ctx context.Context, headers []database.BlockHeader) error {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	_, span := tracing.Start(ctx, "state.ValidateHeaders")
+	defer span.End()
+
+	first := headers[0].Number
+	span.SetAttribute("block.from", first)
+	span.SetAttribute("block.count", len(headers))
+
+	s.mu.RLock()
+	if first == 0 || first > uint64(len(s.headers)) {
+		s.mu.RUnlock()
+		err := fmt.Errorf("block %d doesn't follow a block in the chain", first)
+		span.RecordError(err)
+		return err
+	}
+	chain := append([]database.BlockHeader{}, s.headers[:first]...)
+	s.mu.RUnlock()
+
+	for _, header := range headers {
+		var err error
+		if chain, err = s.extend(chain, header); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportBlocks validates the blocks and adds them to the chain. The first
+// block must follow a block of the chain. When it doesn't follow the latest
+// block, the blocks after its parent are replaced, which only happens when
+// the new blocks make the chain longer. Nothing changes unless every block
+// is valid. Errors caused by an invalid block wrap database.ErrInvalidBlock.
+func (s *State) ImportBlocks(ctx context.Context, blocks []database.Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	_, span := tracing.Start(ctx, "state.ImportBlocks")
+	defer span.End()
+
+	span.SetAttribute("block.from", blocks[0].Header.Number)
+	span.SetAttribute("block.count", len(blocks))
+
+	err := s.importBlocks(blocks)
+	span.RecordError(err)
+
+	return err
+}
+
+// MineNewBlock mines a block on top of the latest block with the best
+// transactions from the mempool and adds it to the chain. The search for
+// the nonce starts at zero, so mining the same transactions on the same
+// parent at the same time yields the same block. Mining stops when the
+// context is cancelled.
+func (s *State) MineNewBlock(ctx context.Context) (database.Block, error) {
+	ctx, span := tracing.Start(ctx, "state.MineNewBlock")
+	defer span.End()
+
+	s.mu.RLock()
+	parent := s.headers[len(s.headers)-1]
+	first := s.windowStart(s.headers, parent.Number+1)
+	db := s.db.Copy()
+	s.mu.RUnlock()
+
+	number := parent.Number + 1
+	rules := s.genesis.Rules(number)
+	gasFee := rules.GasPrice * gasUnits
+
+	// Only pick the transactions that apply, since a block with a failed
+	// transaction is invalid.
+	var trans []database.SignedTx
+	for _, tx := range s.mempool.PickBest(s.mempool.Count()) {
+		if len(trans) == int(rules.TransPerBlock) {
+			break
+		}
+
+		if validateRules(rules, tx) != nil {
+			continue
+		}
+
+		account, _ := db.Query(tx.FromID)
+		if !canApply(account, tx.Tx, gasFee) {
+			continue
+		}
+
+		// A transaction can still fail when it overflows a balance. The
+		// gas it was charged only makes the next picks more cautious.
+		if err := db.ApplyTransaction(tx.Tx, gasFee, s.beneficiaryID); err != nil {
+			continue
+		}
+		trans = append(trans, tx)
+	}
+
+	// The timestamp must move forward even if the clock doesn't.
+	timestamp := uint64(s.clock().UTC().UnixMilli())
+	if timestamp <= parent.TimeStamp {
+		timestamp = parent.TimeStamp + 1
+	}
+
+	header := database.BlockHeader{
+		Number:        number,
+		PrevBlockHash: parent.Hash(),
+		TimeStamp:     timestamp,
+		BeneficiaryID: s.beneficiaryID,
+		Difficulty:    s.genesis.RequiredDifficulty(parent.Retarget(), first.Retarget()),
+		MiningReward:  rules.MiningReward,
+		TransRoot:     database.TransRoot(trans),
+	}
+
+	span.SetAttribute("block.number", number)
+	span.SetAttribute("block.trans", len(trans))
+	span.SetAttribute("block.difficulty", header.Difficulty)
+
+	start := time.Now()
+	header, err := s.pow(ctx, header)
+	if err != nil {
+		span.RecordError(err)
+		return database.Block{}, err
+	}
+
+	// The search started at nonce zero so the nonce found tells how many
+	// hashes were computed.
+	if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+		s.mu.Lock()
+		s.hashRate = float64(header.Nonce+1) / elapsed
+		s.mu.Unlock()
+	}
+
+	block := database.Block{
+		Header: header,
+		Trans:  trans,
+	}
+
+	if err := s.ImportBlocks(ctx, []database.Block{block}); err != nil {
+		span.RecordError(err)
+		return database.Block{}, err
+	}
+
+	return block, nil
+}
+
+// =============================================================================
+
+// pow searches for the nonce that solves the proof of work of the header.
+func (s *State) pow(ctx context.Context, header database.BlockHeader) (database.BlockHeader, error) {
+	ctx, span := tracing.Start(ctx, "state.POW")
+	defer span.End()
+
+	header, err := database.POW(ctx, header)
+	if err != nil {
+		span.RecordError(err)
+		return database.BlockHeader{}, err
+	}
+
+	span.SetAttribute("block.nonce", header.Nonce)
+
+	return header, nil
+}
+
+// importBlocks validates the blocks and adds them to the chain.
+func (s *State) importBlocks(blocks []database.Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := uint64(len(s.headers) - 1)
+
+	first := blocks[0].Header.Number
+	if first == 0 || first > latest+1 {
+		return fmt.Errorf("block %d doesn't follow a block in the chain", first)
+	}
+	ancestor := first - 1
+
+	if blocks[len(blocks)-1].Header.Number <= latest {
+		return ErrNotLonger
+	}
+
+	// Start from the accounts as they were at the ancestor.
+	db := s.db.Copy()
+	if ancestor < latest {
+		var err error
+		if db, err = s.replay(ancestor); err != nil {
+			return err
+		}
+	}
+
+	chain := append([]database.BlockHeader{}, s.headers[:ancestor+1]...)
+	for _, block := range blocks {
+		var err error
+		if chain, err = s.extend(chain, block.Header); err != nil {
+			return err
+		}
+
+		if err := s.applyBlock(db, block); err != nil {
+			return err
+		}
+	}
+
+	// Keep the transactions of the replaced blocks so they can go back to
+	// the mempool.
+	var reverted []database.Block
+	for number := ancestor + 1; number <= latest; number++ {
+		block, err := s.storage.Read(number)
+		if err != nil {
+			return err
+		}
+		reverted = append(reverted, block)
+	}
+
+	if err := s.storage.Truncate(ancestor); err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		if err := s.storage.Write(block); err != nil {
+			return err
+		}
+	}
+
+	if err := s.txIndex.RevertFrom(ancestor + 1); err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		if err := s.txIndex.Add(s.txLocations(block)...); err != nil {
+			return err
+		}
+	}
+
+	s.accounts.RevertFrom(ancestor + 1)
+	for _, block := range blocks {
+		s.accounts.ApplyBlock(block.Header.Number, block.Header.BeneficiaryID, block.Header.MiningReward, block.Trans)
+	}
+
+	s.db = db
+	s.headers = chain
+
+	s.updateMempool(blocks, reverted)
+
+	if len(reverted) > 0 {
+		reorg := Reorg{
+			Ancestor: ancestor,
+			OldTip:   latest,
+			NewTip:   uint64(len(chain) - 1),
+		}
+		s.events.Send(events.NewEvent(events.TypeReorg, reorg))
+	}
+
+	for _, block := range blocks {
+		s.sendNewBlock(block)
+	}
+
+	return nil
+}
+
+// load validates and applies the blocks in storage to rebuild the chain,
+// the accounts and their history. The transaction index is repaired from the first block
+// it doesn't match, since the node can stop between writing the blocks and
+// indexing them.
+func (s *State) load() error {
+	db, err := database.New(s.genesis.Balances)
+	if err != nil {
+		return err
+	}
+
+	count := s.storage.Count()
+	unindexed := count + 1
+
+	chain := []database.BlockHeader{genesisHeader(s.genesis)}
+	for number := uint64(1); number <= count; number++ {
+		block, err := s.storage.Read(number)
+		if err != nil {
+			return err
+		}
+
+		if chain, err = s.extend(chain, block.Header); err != nil {
+			return err
+		}
+
+		if err := s.applyBlock(db, block); err != nil {
+			return err
+		}
+
+		if unindexed > count && !s.indexed(block) {
+			unindexed = number
+		}
+
+		s.accounts.ApplyBlock(number, block.Header.BeneficiaryID, block.Header.MiningReward, block.Trans)
+	}
+
+	if err := s.txIndex.RevertFrom(unindexed); err != nil {
+		return err
+	}
+	for number := unindexed; number <= count; number++ {
+		block, err := s.storage.Read(number)
+		if err != nil {
+			return err
+		}
+
+		if err := s.txIndex.Add(s.txLocations(block)...); err != nil {
+			return err
+		}
+	}
+
+	s.db = db
+	s.headers = chain
+
+	return nil
+}
+
+// replay rebuilds the accounts as they were at the specified block by
+// applying the blocks in storage from the genesis. The blocks were already
+// validated when they were added. The caller must hold the lock.
+func (s *State) replay(number uint64) (*database.Database, error) {
+	db, err := database.New(s.genesis.Balances)
+	if err != nil {
+		return nil, err
+	}
+
+	for n := uint64(1); n <= number; n++ {
+		block, err := s.storage.Read(n)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.applyBlock(db, block); err != nil {
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// extend validates the header follows the last header of the chain under
+// the rules of the genesis and returns the chain with the header added.
+func (s *State) extend(chain []database.BlockHeader, header database.BlockHeader) ([]database.BlockHeader, error) {
+	parent := chain[len(chain)-1]
+
+	if err := header.ValidateLink(parent); err != nil {
+		return nil, err
+	}
+
+	if limit := uint64(s.clock().Add(maxFutureDrift).UnixMilli()); header.TimeStamp > limit {
+		return nil, fmt.Errorf("%w: block %d: timestamp is too far in the future", database.ErrInvalidBlock, header.Number)
+	}
+
+	first := s.windowStart(chain, header.Number)
+	if exp := s.genesis.RequiredDifficulty(parent.Retarget(), first.Retarget()); header.Difficulty != exp {
+		return nil, fmt.Errorf("%w: block %d: %s: got[%d] exp[%d]", database.ErrInvalidBlock, header.Number, difficulty.ErrInvalidDifficulty, header.Difficulty, exp)
+	}
+
+	if exp := s.genesis.Rules(header.Number).MiningReward; header.MiningReward != exp {
+		return nil, fmt.Errorf("%w: block %d: mining reward got[%d] exp[%d]", database.ErrInvalidBlock, header.Number, header.MiningReward, exp)
+	}
+
+	return append(chain, header), nil
+}
+
+// windowStart returns the header the difficulty window of the block with
+// the specified number starts at. The chain must hold the block's parent.
+func (s *State) windowStart(chain []database.BlockHeader, number uint64) database.BlockHeader {
+	cfg := s.genesis.Retarget()
+	if !cfg.Enabled() {
+		return chain[len(chain)-1]
+	}
+
+	return chain[difficulty.WindowStart(cfg, number)]
+}
+
+// applyBlock validates the transactions of the block and applies them and
+// the mining reward to the database.
+func (s *State) applyBlock(db *database.Database, block database.Block) error {
+	number := block.Header.Number
+	rules := s.genesis.Rules(number)
+
+	if len(block.Trans) > int(rules.TransPerBlock) {
+		return fmt.Errorf("%w: block %d: %d transactions, max %d", database.ErrInvalidBlock, number, len(block.Trans), rules.TransPerBlock)
+	}
+
+	if err := block.ValidateBody(); err != nil {
+		return err
+	}
+
+	gasFee := rules.GasPrice * gasUnits
+	for i, tx := range block.Trans {
+		if err := tx.Validate(s.genesis.ChainID); err != nil {
+			return fmt.Errorf("%w: block %d: transaction %d: %s", database.ErrInvalidBlock, number, i, err)
+		}
+
+		if err := validateRules(rules, tx); err != nil {
+			return fmt.Errorf("%w: block %d: transaction %d: %s", database.ErrInvalidBlock, number, i, err)
+		}
+
+		if err := db.ApplyTransaction(tx.Tx, gasFee, block.Header.BeneficiaryID); err != nil {
+			return fmt.Errorf("%w: block %d: transaction %d: %s", database.ErrInvalidBlock, number, i, err)
+		}
+	}
+
+	if err := db.ApplyMiningReward(block.Header); err != nil {
+		return fmt.Errorf("%w: block %d: %s", database.ErrInvalidBlock, number, err)
+	}
+
+	return nil
+}
+
+// txLocations returns the locations of the transactions of the block for
+// the transaction index. Every transaction of a valid block paid the full
+// gas fee and its tip.
+func (s *State) txLocations(block database.Block) []database.TxLocation {
+	gasFee := s.genesis.Rules(block.Header.Number).GasPrice * gasUnits
+
+	locs := make([]database.TxLocation, len(block.Trans))
+	for i, tx := range block.Trans {
+		locs[i] = database.TxLocation{
+			Hash:        tx.Hash(),
+			BlockNumber: block.Header.Number,
+			Index:       uint64(i),
+			Receipt: database.Receipt{
+				GasCharged: gasFee,
+				TipPaid:    tx.Tip,
+			},
+		}
+	}
+
+	return locs
+}
+
+// indexed reports whether the transaction index holds the location of
+// every transaction of the block.
+func (s *State) indexed(block database.Block) bool {
+	for _, loc := range s.txLocations(block) {
+		if got, exists := s.txIndex.Query(loc.Hash); !exists || got != loc {
+			return false
+		}
+	}
+	return true
+}
+
+// updateMempool puts back the transactions of the replaced blocks that can
+// still be mined and removes the transactions whose nonce is used now, or
+// that break the rules of the next block. The ones that weren't mined in
+// the added blocks are recorded as dropped. The caller must hold the lock.
+func (s *State) updateMempool(added []database.Block, reverted []database.Block) {
+	mined := make(map[string]bool)
+	for _, block := range added {
+		for _, tx := range block.Trans {
+			mined[tx.Hash()] = true
+		}
+	}
+
+	for _, block := range reverted {
+		for _, tx := range block.Trans {
+			if mined[tx.Hash()] {
+				continue
+			}
+
+			if account, _ := s.db.Query(tx.FromID); tx.Nonce <= account.Nonce {
+				continue
+			}
+
+			dropped, err := s.mempool.Upsert(tx)
+			if err != nil {
+				continue
+			}
+
+			for _, d := range dropped {
+				s.outcomes.record(d.Hash, TxStatusDropped, d.Reason)
+			}
+			s.sendPendingTx(tx)
+		}
+	}
+
+	stale := func(tx database.SignedTx) bool {
+		account, err := s.db.Query(tx.FromID)
+		return err == nil && tx.Nonce <= account.Nonce
+	}
+
+	for _, tx := range s.mempool.Prune(stale) {
+		if !mined[tx.Hash()] {
+			s.outcomes.record(tx.Hash(), TxStatusDropped, "nonce already used by a mined transaction")
+		}
+	}
+
+	rules := s.genesis.Rules(uint64(len(s.headers)))
+	broken := func(tx database.SignedTx) bool {
+		return validateRules(rules, tx) != nil
+	}
+
+	for _, tx := range s.mempool.Prune(broken) {
+		s.outcomes.record(tx.Hash(), TxStatusDropped, validateRules(rules, tx).Error())
+	}
+}
+
+// sendNewBlock sends the event for a block added to the chain. The event
+// involves the beneficiary and the accounts of every transaction.
+func (s *State) sendNewBlock(block database.Block) {
+	accounts := []string{string(block.Header.BeneficiaryID)}
+	for _, tx := range block.Trans {
+		accounts = append(accounts, string(tx.FromID), string(tx.ToID))
+	}
+
+	newBlock := NewBlock{
+		Number:     block.Header.Number,
+		Hash:       block.Hash(),
+		TransCount: len(block.Trans),
+	}
+	s.events.Send(events.NewEvent(events.TypeNewBlock, newBlock, accounts...))
+}
+
+// =============================================================================
+
+// genesisHeader returns the header of block 0. The genesis hash is its
+// previous block hash so every chain starts from a different block.
+func genesisHeader(gen genesis.Genesis) database.BlockHeader {
+	return database.BlockHeader{
+		PrevBlockHash: gen.Hash(),
+		TimeStamp:     uint64(gen.Date.UTC().UnixMilli()),
+		Difficulty:    gen.Difficulty,
+		TransRoot:     signature.ZeroHash,
+	}
+}
+
+// canApply reports whether the transaction applies to the account without
+// failing, which is what ApplyTransaction checks after the gas is charged.
+func canApply(account database.Account, tx database.Tx, gasFee uint64) bool {
+	if tx.Nonce != account.Nonce+1 || account.Balance <= gasFee {
+		return false
+	}
+
+	balance := account.Balance - gasFee
+	return tx.Value <= balance && tx.Tip <= balance-tx.Value
+}
@@ -0,0 +1,111 @@
+package state
+
+import (
+	"math"
+	"sort"
+)
+
+// FeeEstimate represents the suggested tips for a new transaction. The low,
+// medium and high tips aim for inclusion within three blocks, two blocks
+// and the next block. Every transaction also pays the flat gas price.
+type FeeEstimate struct {
+	GasPrice      uint64 `json:"gas_price"`
+	Low           uint64 `json:"low"`
+	Medium        uint64 `json:"medium"`
+	High          uint64 `json:"high"`
+	Pending       int    `json:"pending"`
+	SlotsPerBlock uint16 `json:"slots_per_block"`
+}
+
+// feeHistoryBlocks is the number of recent blocks whose tips are sampled
+// to suggest fees.
+const feeHistoryBlocks = 20
+
+// EstimateFees suggests tips from the tips paid in recent blocks and the
+// competition in the mempool. A tip is suggested from the percentiles of
+// the tips mined in the recent blocks, or of the pending tips when the
+// recent blocks have no transactions, raised to outbid the transactions
+// that would take the slots of the blocks being aimed for.
+func (s *State) EstimateFees() FeeEstimate {
+	rules := s.Rules()
+	pending := s.mempool.Tips()
+	slots := int(rules.TransPerBlock)
+
+	sample := s.recentTips()
+	if len(sample) == 0 {
+		sample = pending
+	}
+
+	// cutoff returns the tip needed to be picked within the specified number
+	// of blocks, since the miner picks the transactions with the best tips.
+	cutoff := func(blocks int) uint64 {
+		if slots == 0 || len(pending) < blocks*slots {
+			return 0
+		}
+
+		tip := pending[blocks*slots-1]
+		if tip == math.MaxUint64 {
+			return tip
+		}
+		return tip + 1
+	}
+
+	return FeeEstimate{
+		GasPrice:      rules.GasPrice,
+		Low:           maxTip(cutoff(3), percentile(sample, 25)),
+		Medium:        maxTip(cutoff(2), percentile(sample, 50)),
+		High:          maxTip(cutoff(1), percentile(sample, 75)),
+		Pending:       len(pending),
+		SlotsPerBlock: rules.TransPerBlock,
+	}
+}
+
+// recentTips returns the tips of the transactions mined in the recent
+// blocks sorted from the highest to the lowest.
+func (s *State) recentTips() []uint64 {
+	latest := s.LatestBlockNumber()
+	if latest == 0 {
+		return nil
+	}
+
+	from := uint64(1)
+	if latest > feeHistoryBlocks {
+		from = latest - feeHistoryBlocks + 1
+	}
+
+	blocks, err := s.QueryBlocks(from, feeHistoryBlocks)
+	if err != nil {
+		return nil
+	}
+
+	var tips []uint64
+	for _, block := range blocks {
+		for _, tx := range block.Trans {
+			tips = append(tips, tx.Tip)
+		}
+	}
+
+	sort.Slice(tips, func(i, j int) bool {
+		return tips[i] > tips[j]
+	})
+
+	return tips
+}
+
+// percentile returns the nearest rank percentile of the tips, which are
+// sorted from the highest to the lowest.
+func percentile(tips []uint64, p int) uint64 {
+	if len(tips) == 0 {
+		return 0
+	}
+
+	return tips[(100-p)*(len(tips)-1)/100]
+}
+
+// maxTip returns the highest of the two tips.
+func maxTip(a uint64, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
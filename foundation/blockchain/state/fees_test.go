@@ -0,0 +1,62 @@
+package state_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+)
+
+func Test_EstimateFees(t *testing.T) {
+	tt := []struct {
+		name    string
+		slots   uint16
+		mined   []uint64
+		pending []uint64
+		exp     state.FeeEstimate
+	}{
+		{"node without transactions", 10, nil, nil, state.FeeEstimate{}},
+		{"node with recently mined tips", 10, []uint64{10, 20, 30, 40}, nil, state.FeeEstimate{Low: 20, Medium: 30, High: 40}},
+		{"node with pending tips only", 10, nil, []uint64{5, 6, 7}, state.FeeEstimate{Low: 6, Medium: 6, High: 7}},
+		{"node with full blocks pending", 2, []uint64{1}, []uint64{1, 2, 3, 4, 5, 6}, state.FeeEstimate{Low: 2, Medium: 4, High: 6}},
+		{"node with the highest tip pending", 1, []uint64{1}, []uint64{math.MaxUint64}, state.FeeEstimate{Low: 1, Medium: 1, High: math.MaxUint64}},
+	}
+
+	t.Log("Given the need to suggest tips for a new transaction.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					privateKey, gen := newGenesis(t)
+					gen.TransPerBlock = tst.slots
+					st := newState(t, t.TempDir(), gen, minerA)
+
+					nonce := uint64(1)
+					for _, tip := range tst.mined {
+						if err := st.UpsertWalletTransaction(signTx(t, privateKey, nonce, 1, tip)); err != nil {
+							t.Fatalf("upserting tx: %s", err)
+						}
+						mineBlock(t, st)
+						nonce++
+					}
+
+					for _, tip := range tst.pending {
+						if err := st.UpsertWalletTransaction(signTx(t, privateKey, nonce, 1, tip)); err != nil {
+							t.Fatalf("upserting tx: %s", err)
+						}
+						nonce++
+					}
+
+					got := st.EstimateFees()
+					if got.Low != tst.exp.Low || got.Medium != tst.exp.Medium || got.High != tst.exp.High {
+						t.Fatalf("\t%s\tTest %d:\tShould suggest the expected tips: got[%d %d %d] exp[%d %d %d]", failed, testID, got.Low, got.Medium, got.High, tst.exp.Low, tst.exp.Medium, tst.exp.High)
+					}
+					t.Logf("\t%s\tTest %d:\tShould suggest the expected tips.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+// Package state is the core API for the blockchain and implements all the
+// business rules and processing.
+package state
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/genesis"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/mempool"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+)
+
+// ErrBatchRejected is returned for the valid transactions of an atomic batch
+// that was rejected because of the other transactions.
+var ErrBatchRejected = errors.New("batch rejected, another transaction in the batch failed")
+
+// Config represents the configuration required to start
+// the blockchain node.
+type Config struct {
+	BeneficiaryID database.AccountID
+	DBPath        string
+	Genesis       genesis.Genesis
+	Evts          *events.Events
+	Clock         func() time.Time
+	MempoolSize   int
+}
+
+// PendingTx is the data of the event sent when a transaction is accepted
+// into the mempool.
+type PendingTx struct {
+	Hash string            `json:"hash"`
+	Tx   database.SignedTx `json:"tx"`
+}
+
+// State manages the blockchain database.
+type State struct {
+	beneficiaryID database.AccountID
+	dbPath        string
+	genesis       genesis.Genesis
+	mempool       *mempool.Mempool
+	storage       *database.Storage
+	txIndex       *database.TxIndex
+	accounts      *database.AccountIndex
+	outcomes      *outcomes
+	events        *events.Events
+	clock         func() time.Time
+
+	mu       sync.RWMutex
+	db       *database.Database
+	headers  []database.BlockHeader
+	hashRate float64
+}
+
+// New constructs a new blockchain for data management.
+func New(cfg Config) (*State, error) {
+
+	// Access the storage for the blockchain.
+	storage, err := database.NewStorage(filepath.Join(cfg.DBPath, "blocks.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Access the index of mined transactions.
+	txIndex, err := database.NewTxIndex(filepath.Join(cfg.DBPath, "txindex.jsonl"))
+	if err != nil {
+		storage.Close()
+		return nil, err
+	}
+
+	// Blocks are mined at the current time unless a clock is provided.
+	clock := cfg.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+
+	// Create the State to provide support for managing the blockchain.
+	state := State{
+		beneficiaryID: cfg.BeneficiaryID,
+		dbPath:        cfg.DBPath,
+		genesis:       cfg.Genesis,
+		mempool:       mempool.New(cfg.MempoolSize),
+		storage:       storage,
+		txIndex:       txIndex,
+		accounts:      database.NewAccountIndex(),
+		outcomes:      newOutcomes(),
+		events:        cfg.Evts,
+		clock:         clock,
+	}
+
+	// Rebuild the accounts and their history by validating and applying the
+	// blocks in storage.
+	if err := state.load(); err != nil {
+		state.Shutdown()
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// Shutdown cleanly brings the node down.
+func (s *State) Shutdown() error {
+	err := s.storage.Close()
+	if idxErr := s.txIndex.Close(); err == nil {
+		err = idxErr
+	}
+	return err
+}
+
+// CheckStorage validates the node can still write to the database folder by
+// writing and removing a file in it.
+func (s *State) CheckStorage() error {
+	f, err := os.CreateTemp(s.dbPath, ".check-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write([]byte("ok")); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Genesis returns a copy of the genesis information.
+func (s *State) Genesis() genesis.Genesis {
+	return s.genesis
+}
+
+// Rules returns the rules of the chain the next block is validated with.
+func (s *State) Rules() genesis.Rules {
+	return s.genesis.Rules(s.LatestBlockNumber() + 1)
+}
+
+// LatestBlockNumber returns the number of the latest block in the chain.
+func (s *State) LatestBlockNumber() uint64 {
+	return s.LatestBlock().Number
+}
+
+// LatestBlock returns the header of the latest block in the chain.
+func (s *State) LatestBlock() database.BlockHeader {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.headers[len(s.headers)-1]
+}
+
+// HashRate returns the number of hashes per second computed to mine the
+// last block this node mined. It's zero until the node mines a block.
+func (s *State) HashRate() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.hashRate
+}
+
+// QueryAccount returns a copy of the account from the database.
+func (s *State) QueryAccount(accountID database.AccountID) (database.Account, error) {
+	return s.accountsDB().Query(accountID)
+}
+
+// QueryAccountHistory returns a page of the history of the specified account,
+// newest first. See database.AccountIndex.Query for how the cursor works.
+func (s *State) QueryAccountHistory(accountID database.AccountID, direction string, cursor uint64, limit int) ([]database.AccountTx, uint64) {
+	return s.accounts.Query(accountID, direction, cursor, limit)
+}
+
+// QueryMempoolLength returns the current length of the mempool.
+func (s *State) QueryMempoolLength() int {
+	return s.mempool.Count()
+}
+
+// QueryMempoolTransaction returns the transaction in the mempool with the
+// specified hash.
+func (s *State) QueryMempoolTransaction(hash string) (database.SignedTx, bool) {
+	return s.mempool.Query(hash)
+}
+
+// QueryMinedTransaction returns the transaction of the chain with the
+// specified hash and where it was found.
+func (s *State) QueryMinedTransaction(hash string) (database.SignedTx, database.TxLocation, bool) {
+	loc, exists := s.txIndex.Query(hash)
+	if !exists {
+		return database.SignedTx{}, database.TxLocation{}, false
+	}
+
+	block, exists := s.QueryBlock(loc.BlockNumber)
+	if !exists || loc.Index >= uint64(len(block.Trans)) {
+		return database.SignedTx{}, database.TxLocation{}, false
+	}
+
+	tx := block.Trans[loc.Index]
+	if tx.Hash() != hash {
+		return database.SignedTx{}, database.TxLocation{}, false
+	}
+
+	return tx, loc, true
+}
+
+// QueryTransaction returns the status of the transaction with the specified
+// hash. Mined transactions are found in the transaction index, pending ones
+// in the mempool and the others in the recent outcomes.
+func (s *State) QueryTransaction(hash string) (TxStatus, bool) {
+	if loc, exists := s.txIndex.Query(hash); exists {
+		status := TxStatus{
+			Hash:        hash,
+			Status:      TxStatusMined,
+			BlockNumber: &loc.BlockNumber,
+			Index:       &loc.Index,
+			Receipt:     &loc.Receipt,
+		}
+		return status, true
+	}
+
+	if tx, exists := s.mempool.Query(hash); exists {
+		status := TxStatus{
+			Hash:   hash,
+			Status: TxStatusPending,
+			Tx:     &tx,
+		}
+		return status, true
+	}
+
+	return s.outcomes.query(hash)
+}
+
+// SimulateTransaction applies the transaction to a copy of the accounts and
+// reports the outcome. A wrong nonce or insufficient funds is part of the
+// outcome, an error is only returned when the transaction is malformed.
+func (s *State) SimulateTransaction(tx database.Tx) (Simulation, error) {
+	if tx.ChainID != s.genesis.ChainID {
+		return Simulation{}, fmt.Errorf("invalid chain id, got[%d] exp[%d]", tx.ChainID, s.genesis.ChainID)
+	}
+
+	if _, err := database.NewTx(tx.ChainID, tx.Nonce, tx.FromID, tx.ToID, tx.Value, tx.Tip, tx.Data); err != nil {
+		return Simulation{}, err
+	}
+
+	if tx.FromID == tx.ToID {
+		return Simulation{}, errors.New("transaction invalid, sending money to yourself")
+	}
+
+	gasFee := s.Rules().GasPrice * gasUnits
+
+	if tx.Tip > math.MaxUint64-tx.Value || gasFee > math.MaxUint64-tx.Value-tx.Tip {
+		return Simulation{}, errors.New("transaction invalid, total cost overflows")
+	}
+
+	db := s.accountsDB().Copy()
+	before, _ := db.Query(tx.FromID)
+
+	applyErr := db.ApplyTransaction(tx, gasFee, s.beneficiaryID)
+
+	// Accounts that never transacted have a zero balance and nonce.
+	from, _ := db.Query(tx.FromID)
+	to, err := db.Query(tx.ToID)
+	if err != nil {
+		to = database.Account{AccountID: tx.ToID}
+	}
+
+	// The gas fee is charged up to the balance of the sender.
+	gasCharged := gasFee
+	if gasCharged > before.Balance {
+		gasCharged = before.Balance
+	}
+
+	sim := Simulation{
+		Success:    applyErr == nil,
+		GasCharged: gasCharged,
+		Tip:        tx.Tip,
+		Value:      tx.Value,
+		TotalCost:  gasFee + tx.Tip + tx.Value,
+		From:       from,
+		To:         to,
+	}
+	if applyErr != nil {
+		sim.Error = applyErr.Error()
+	}
+
+	return sim, nil
+}
+
+// UpsertWalletTransaction accepts a transaction from a wallet for inclusion.
+func (s *State) UpsertWalletTransaction(signedTx database.SignedTx) error {
+
+	// It's up to the wallet to make sure the account has a proper balance
+	// and this transaction has the next nonce.
+
+	// Check the signed transaction has a proper signature, the from matches the
+	// signature, the from and to fields are properly formatted and the nonce
+	// isn't used yet.
+	if err := s.validateTx(signedTx); err != nil {
+		s.outcomes.record(signedTx.Hash(), TxStatusRejected, err.Error())
+		return err
+	}
+
+	dropped, err := s.mempool.Upsert(signedTx)
+	if err != nil {
+		s.outcomes.record(signedTx.Hash(), TxStatusRejected, err.Error())
+		return err
+	}
+
+	for _, d := range dropped {
+		s.outcomes.record(d.Hash, TxStatusDropped, d.Reason)
+	}
+
+	s.sendPendingTx(signedTx)
+
+	return nil
+}
+
+// UpsertWalletTransactions accepts a batch of transactions from a wallet for
+// inclusion and returns an error for every transaction that is rejected.
+// When atomic is true no transaction is accepted unless all of them are,
+// and the transactions that were fine fail with ErrBatchRejected.
+func (s *State) UpsertWalletTransactions(signedTxs []database.SignedTx, atomic bool) []error {
+	errs := make([]error, len(signedTxs))
+
+	if !atomic {
+		for i, signedTx := range signedTxs {
+			errs[i] = s.UpsertWalletTransaction(signedTx)
+		}
+		return errs
+	}
+
+	// Validate every transaction first, including that no two transactions
+	// of the batch would take the same nonce.
+	failed := false
+	nonces := make(map[string]bool, len(signedTxs))
+	for i, signedTx := range signedTxs {
+		if err := s.validateTx(signedTx); err != nil {
+			errs[i] = err
+			failed = true
+			continue
+		}
+
+		if nonces[signedTx.String()] {
+			errs[i] = fmt.Errorf("duplicate nonce %d for account %s in batch", signedTx.Nonce, signedTx.FromID)
+			failed = true
+			continue
+		}
+		nonces[signedTx.String()] = true
+	}
+
+	var dropped []mempool.Dropped
+	if !failed {
+		var upsertErrs []error
+		dropped, upsertErrs = s.mempool.UpsertAll(signedTxs)
+		if upsertErrs != nil {
+			errs = upsertErrs
+			failed = true
+		}
+	}
+
+	if failed {
+		for i, signedTx := range signedTxs {
+			if errs[i] == nil {
+				errs[i] = ErrBatchRejected
+				continue
+			}
+			s.outcomes.record(signedTx.Hash(), TxStatusRejected, errs[i].Error())
+		}
+		return errs
+	}
+
+	for _, d := range dropped {
+		s.outcomes.record(d.Hash, TxStatusDropped, d.Reason)
+	}
+
+	for _, signedTx := range signedTxs {
+		s.sendPendingTx(signedTx)
+	}
+
+	return errs
+}
+
+// =============================================================================
+
+// accountsDB returns the accounts at the latest block. Importing blocks
+// replaces the database, so it is read under the lock.
+func (s *State) accountsDB() *database.Database {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db
+}
+
+// validateTx checks the signature and the format of the transaction, that
+// its nonce wasn't used by the account yet and that it follows the rules
+// of the next block.
+func (s *State) validateTx(signedTx database.SignedTx) error {
+	if err := signedTx.Validate(s.genesis.ChainID); err != nil {
+		return err
+	}
+
+	if account, err := s.accountsDB().Query(signedTx.FromID); err == nil && signedTx.Nonce <= account.Nonce {
+		return fmt.Errorf("nonce %d already used by account %s, next nonce is %d", signedTx.Nonce, signedTx.FromID, account.Nonce+1)
+	}
+
+	return validateRules(s.Rules(), signedTx)
+}
+
+// sendPendingTx sends the event for a transaction accepted into the mempool.
+func (s *State) sendPendingTx(signedTx database.SignedTx) {
+	pendingTx := PendingTx{
+		Hash: signedTx.Hash(),
+		Tx:   signedTx,
+	}
+	s.events.Send(events.NewEvent(events.TypePendingTx, pendingTx, string(signedTx.FromID), string(signedTx.ToID)))
+}
@@ -0,0 +1,405 @@
+package state_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/genesis"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+const (
+	minerA = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	minerB = database.AccountID("0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+	toID   = database.AccountID("0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76")
+)
+
+func Test_MineNewBlock(t *testing.T) {
+	privateKey, gen := newGenesis(t)
+	fromID := database.PublicKeyToAccountID(privateKey.PublicKey)
+	dir := t.TempDir()
+	tx := signTx(t, privateKey, 1, 100, 5)
+
+	t.Log("Given the need to mine and store blocks.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a block is mined with a pending transaction.", testID)
+		{
+			st := newState(t, dir, gen, minerA)
+
+			if err := st.UpsertWalletTransaction(tx); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the transaction: %s", failed, testID, err)
+			}
+
+			block, err := st.MineNewBlock(context.Background())
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould mine the block: %s", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould mine the block.", success, testID)
+
+			if block.Header.Number != 1 || len(block.Trans) != 1 || st.QueryMempoolLength() != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould move the transaction into block 1: number[%d] trans[%d] mempool[%d]", failed, testID, block.Header.Number, len(block.Trans), st.QueryMempoolLength())
+			}
+			t.Logf("\t%s\tTest %d:\tShould move the transaction into block 1.", success, testID)
+
+			// The sender pays the value, the tip and the gas. The miner gets
+			// the reward, the tip and the gas.
+			checkBalance(t, testID, st, fromID, 1000-100-5-15)
+			checkBalance(t, testID, st, toID, 100)
+			checkBalance(t, testID, st, minerA, 700+5+15)
+			checkHistory(t, testID, st, fromID, database.DirectionSent, 1)
+			checkHistory(t, testID, st, minerA, database.DirectionReward, 1)
+
+			st.Shutdown()
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the node restarts.", testID)
+		{
+			st := newState(t, dir, gen, minerA)
+
+			if latest := st.LatestBlockNumber(); latest != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould load the stored block: got[%d] exp[1]", failed, testID, latest)
+			}
+			t.Logf("\t%s\tTest %d:\tShould load the stored block.", success, testID)
+
+			checkBalance(t, testID, st, fromID, 1000-100-5-15)
+			checkBalance(t, testID, st, minerA, 700+5+15)
+			checkMined(t, testID, st, tx.Hash(), 1)
+			checkHistory(t, testID, st, fromID, database.DirectionSent, 1)
+
+			st.Shutdown()
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the node restarts without its transaction index.", testID)
+		{
+			if err := os.Remove(filepath.Join(dir, "txindex.jsonl")); err != nil {
+				t.Fatalf("removing index: %s", err)
+			}
+
+			st := newState(t, dir, gen, minerA)
+			checkMined(t, testID, st, tx.Hash(), 1)
+		}
+	}
+}
+
+func Test_ImportBlocks(t *testing.T) {
+	privateKey, gen := newGenesis(t)
+
+	// Node A mines a block with a transaction while node B mines two empty
+	// blocks on its own.
+	a := newState(t, t.TempDir(), gen, minerA)
+	if err := a.UpsertWalletTransaction(signTx(t, privateKey, 1, 100, 5)); err != nil {
+		t.Fatalf("upserting tx: %s", err)
+	}
+	blockA := mineBlock(t, a)
+
+	b := newState(t, t.TempDir(), gen, minerB)
+	chainB := []database.Block{mineBlock(t, b), mineBlock(t, b)}
+
+	tampered := chainB[0]
+	tampered.Header.MiningReward++
+
+	tt := []struct {
+		name   string
+		blocks []database.Block
+		err    error
+		latest uint64
+	}{
+		{"block with a changed reward", []database.Block{tampered, chainB[1]}, database.ErrInvalidBlock, 1},
+		{"shorter chain", chainB[:1], state.ErrNotLonger, 1},
+		{"block that doesn't connect", chainB[1:], errAny, 1},
+		{"longer chain", chainB, nil, 2},
+		{"known block", chainB[1:], state.ErrNotLonger, 2},
+	}
+
+	t.Log("Given the need to import the blocks of a peer.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen importing a %s.", testID, tst.name)
+				{
+					err := a.ImportBlocks(context.Background(), tst.blocks)
+
+					switch {
+					case tst.err == nil && err != nil:
+						t.Fatalf("\t%s\tTest %d:\tShould import the blocks: %s", failed, testID, err)
+					case tst.err == errAny && err == nil:
+						t.Fatalf("\t%s\tTest %d:\tShould refuse the blocks.", failed, testID)
+					case tst.err != nil && tst.err != errAny && !errors.Is(err, tst.err):
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected error: got[%v] exp[%v]", failed, testID, err, tst.err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected error.", success, testID)
+
+					if latest := a.LatestBlockNumber(); latest != tst.latest {
+						t.Fatalf("\t%s\tTest %d:\tShould be at the expected block: got[%d] exp[%d]", failed, testID, latest, tst.latest)
+					}
+					t.Logf("\t%s\tTest %d:\tShould be at the expected block.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+
+	t.Log("Given the need to undo the blocks replaced by a reorg.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the chain of node B replaced block 1 of node A.", testID)
+		{
+			if a.LatestBlock().Hash() != chainB[1].Hash() {
+				t.Fatalf("\t%s\tTest %d:\tShould follow the chain of node B.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould follow the chain of node B.", success, testID)
+
+			checkBalance(t, testID, a, minerA, 0)
+			checkBalance(t, testID, a, minerB, 2*700)
+			checkHistory(t, testID, a, minerA, database.DirectionReward, 0)
+			checkHistory(t, testID, a, minerB, database.DirectionReward, 2)
+
+			tx := blockA.Trans[0]
+			if _, exists := a.QueryMempoolTransaction(tx.Hash()); !exists {
+				t.Fatalf("\t%s\tTest %d:\tShould return the reverted transaction to the mempool.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould return the reverted transaction to the mempool.", success, testID)
+		}
+	}
+}
+
+func Test_Nonces(t *testing.T) {
+	privateKey, gen := newGenesis(t)
+
+	a := newState(t, t.TempDir(), gen, minerA)
+	mined := signTx(t, privateKey, 1, 100, 5)
+	if err := a.UpsertWalletTransaction(mined); err != nil {
+		t.Fatalf("upserting tx: %s", err)
+	}
+	blockA := mineBlock(t, a)
+
+	t.Log("Given the need to keep transactions with a used nonce out of the mempool.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a transaction reuses the nonce of a mined one.", testID)
+		{
+			stale := signTx(t, privateKey, 1, 50, 5)
+			if err := a.UpsertWalletTransaction(stale); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the transaction.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the transaction.", success, testID)
+
+			if status, _ := a.QueryTransaction(stale.Hash()); status.Status != state.TxStatusRejected {
+				t.Fatalf("\t%s\tTest %d:\tShould record the transaction as rejected: got[%s]", failed, testID, status.Status)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the transaction as rejected.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen an imported block uses the nonce of a pending transaction.", testID)
+		{
+			b := newState(t, t.TempDir(), gen, minerB)
+
+			pending := signTx(t, privateKey, 1, 50, 5)
+			if err := b.UpsertWalletTransaction(pending); err != nil {
+				t.Fatalf("upserting tx: %s", err)
+			}
+
+			if err := b.ImportBlocks(context.Background(), []database.Block{blockA}); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould import the block: %s", failed, testID, err)
+			}
+
+			if b.QueryMempoolLength() != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould evict the transaction: mempool[%d]", failed, testID, b.QueryMempoolLength())
+			}
+			t.Logf("\t%s\tTest %d:\tShould evict the transaction.", success, testID)
+
+			if status, _ := b.QueryTransaction(pending.Hash()); status.Status != state.TxStatusDropped {
+				t.Fatalf("\t%s\tTest %d:\tShould record the transaction as dropped: got[%s]", failed, testID, status.Status)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the transaction as dropped.", success, testID)
+		}
+	}
+}
+
+func Test_ForkFlags(t *testing.T) {
+	privateKey, gen := newGenesis(t)
+	gen.Forks = []genesis.Fork{{Name: "limit", Height: 2, Flags: []string{genesis.FlagLimitTxData}}}
+
+	st := newState(t, t.TempDir(), gen, minerA)
+	large := make([]byte, genesis.MaxTxData+1)
+
+	t.Log("Given the need to turn on a rule at the height of its fork.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a transaction with large data goes in the block before the fork.", testID)
+		{
+			if err := st.UpsertWalletTransaction(signDataTx(t, privateKey, 1, large)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the transaction: %s", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept the transaction.", success, testID)
+
+			if block := mineBlock(t, st); len(block.Trans) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould mine the transaction: got[%d]", failed, testID, len(block.Trans))
+			}
+			t.Logf("\t%s\tTest %d:\tShould mine the transaction.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen a transaction with large data goes in the block at the fork.", testID)
+		{
+			if err := st.UpsertWalletTransaction(signDataTx(t, privateKey, 2, large)); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the transaction.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the transaction.", success, testID)
+
+			if err := st.UpsertWalletTransaction(signDataTx(t, privateKey, 2, large[:genesis.MaxTxData])); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the data up to the limit: %s", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept the data up to the limit.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// errAny marks a test case that must fail without checking the error.
+var errAny = errors.New("any error")
+
+// newGenesis returns a genesis that funds a new account with 1000.
+func newGenesis(t *testing.T) (*ecdsa.PrivateKey, genesis.Genesis) {
+	t.Helper()
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+
+	gen := genesis.Genesis{
+		Date:          time.Date(2021, time.December, 17, 0, 0, 0, 0, time.UTC),
+		ChainID:       1,
+		TransPerBlock: 10,
+		Difficulty:    1,
+		MiningReward:  700,
+		GasPrice:      15,
+		Balances: map[string]uint64{
+			string(database.PublicKeyToAccountID(privateKey.PublicKey)): 1000,
+		},
+	}
+
+	return privateKey, gen
+}
+
+// newState starts a state in the specified folder that is shut down when
+// the test completes.
+func newState(t *testing.T, dir string, gen genesis.Genesis, beneficiaryID database.AccountID) *state.State {
+	t.Helper()
+
+	st, err := state.New(state.Config{
+		BeneficiaryID: beneficiaryID,
+		DBPath:        dir,
+		Genesis:       gen,
+		Evts:          events.New(),
+	})
+	if err != nil {
+		t.Fatalf("starting state: %s", err)
+	}
+	t.Cleanup(func() { st.Shutdown() })
+
+	return st
+}
+
+// signTx signs a transaction sending the value to toID.
+func signTx(t *testing.T, privateKey *ecdsa.PrivateKey, nonce uint64, value uint64, tip uint64) database.SignedTx {
+	t.Helper()
+
+	tx, err := database.NewTx(1, nonce, database.PublicKeyToAccountID(privateKey.PublicKey), toID, value, tip, nil)
+	if err != nil {
+		t.Fatalf("constructing tx: %s", err)
+	}
+
+	signedTx, err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("signing tx: %s", err)
+	}
+
+	return signedTx
+}
+
+// signDataTx signs a transaction from the funded account carrying the
+// specified data.
+func signDataTx(t *testing.T, privateKey *ecdsa.PrivateKey, nonce uint64, data []byte) database.SignedTx {
+	t.Helper()
+
+	tx, err := database.NewTx(1, nonce, database.PublicKeyToAccountID(privateKey.PublicKey), toID, 1, 0, data)
+	if err != nil {
+		t.Fatalf("constructing tx: %s", err)
+	}
+
+	signedTx, err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("signing tx: %s", err)
+	}
+
+	return signedTx
+}
+
+// mineBlock mines the next block of the state.
+func mineBlock(t *testing.T, st *state.State) database.Block {
+	t.Helper()
+
+	block, err := st.MineNewBlock(context.Background())
+	if err != nil {
+		t.Fatalf("mining block: %s", err)
+	}
+
+	return block
+}
+
+// checkMined fails the test unless the transaction is indexed as mined in
+// the block.
+func checkMined(t *testing.T, testID int, st *state.State, hash string, number uint64) {
+	t.Helper()
+
+	status, _ := st.QueryTransaction(hash)
+	if status.Status != state.TxStatusMined || *status.BlockNumber != number {
+		t.Fatalf("\t%s\tTest %d:\tShould index the transaction as mined in block %d: got[%+v]", failed, testID, number, status)
+	}
+	t.Logf("\t%s\tTest %d:\tShould index the transaction as mined in block %d.", success, testID, number)
+}
+
+// checkHistory fails the test unless the history of the account has the
+// number of entries in the direction.
+func checkHistory(t *testing.T, testID int, st *state.State, accountID database.AccountID, direction string, exp int) {
+	t.Helper()
+
+	entries, _ := st.QueryAccountHistory(accountID, direction, 0, 100)
+	if len(entries) != exp {
+		t.Fatalf("\t%s\tTest %d:\tShould have the expected %s history for %s: got[%d] exp[%d]", failed, testID, direction, accountID, len(entries), exp)
+	}
+	t.Logf("\t%s\tTest %d:\tShould have the expected %s history for %s.", success, testID, direction, accountID)
+}
+
+// checkBalance fails the test unless the account has the balance.
+func checkBalance(t *testing.T, testID int, st *state.State, accountID database.AccountID, exp uint64) {
+	t.Helper()
+
+	account, _ := st.QueryAccount(accountID)
+	if account.Balance != exp {
+		t.Fatalf("\t%s\tTest %d:\tShould have the expected balance for %s: got[%d] exp[%d]", failed, testID, accountID, account.Balance, exp)
+	}
+	t.Logf("\t%s\tTest %d:\tShould have the expected balance for %s.", success, testID, accountID)
+}
@@ -0,0 +1,106 @@
+package state
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/genesis"
+)
+
+// Set of statuses a transaction goes through.
+const (
+	TxStatusPending  = "pending"
+	TxStatusMined    = "mined"
+	TxStatusDropped  = "dropped"
+	TxStatusRejected = "rejected"
+)
+
+// gasUnits is the gas every transaction costs. The gas price comes from
+// the genesis file.
+const gasUnits = 1
+
+// maxOutcomes is the number of dropped and rejected transactions remembered.
+// These outcomes are only kept in memory since they never reach the chain.
+const maxOutcomes = 10_000
+
+// TxStatus represents where a transaction is in its lifecycle.
+type TxStatus struct {
+	Hash        string             `json:"hash"`
+	Status      string             `json:"status"`
+	BlockNumber *uint64            `json:"block_number,omitempty"`
+	Index       *uint64            `json:"index,omitempty"`
+	Receipt     *database.Receipt  `json:"receipt,omitempty"`
+	Reason      string             `json:"reason,omitempty"`
+	Tx          *database.SignedTx `json:"tx,omitempty"`
+}
+
+// Simulation represents the predicted outcome of applying a transaction to
+// the current state. The accounts hold the balances after the transaction.
+type Simulation struct {
+	Hash       string           `json:"hash,omitempty"`
+	Success    bool             `json:"success"`
+	Error      string           `json:"error,omitempty"`
+	GasCharged uint64           `json:"gas_charged"`
+	Tip        uint64           `json:"tip"`
+	Value      uint64           `json:"value"`
+	TotalCost  uint64           `json:"total_cost"`
+	From       database.Account `json:"from"`
+	To         database.Account `json:"to"`
+}
+
+// =============================================================================
+
+// validateRules checks the transaction follows the rules turned on by the
+// forks in effect.
+func validateRules(rules genesis.Rules, tx database.SignedTx) error {
+	if rules.IsActive(genesis.FlagLimitTxData) && len(tx.Data) > genesis.MaxTxData {
+		return fmt.Errorf("transaction data of %d bytes exceeds the limit of %d", len(tx.Data), genesis.MaxTxData)
+	}
+
+	return nil
+}
+
+// outcomes remembers the transactions that left the mempool without being
+// mined. The oldest outcome is forgotten once the limit is reached.
+type outcomes struct {
+	mu     sync.Mutex
+	status map[string]TxStatus
+	order  []string
+}
+
+// newOutcomes constructs an empty set of outcomes.
+func newOutcomes() *outcomes {
+	return &outcomes{
+		status: make(map[string]TxStatus),
+	}
+}
+
+// record remembers the status of the transaction with the specified hash.
+func (o *outcomes) record(hash string, status string, reason string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, exists := o.status[hash]; !exists {
+		if len(o.order) == maxOutcomes {
+			delete(o.status, o.order[0])
+			o.order = o.order[1:]
+		}
+		o.order = append(o.order, hash)
+	}
+
+	o.status[hash] = TxStatus{
+		Hash:   hash,
+		Status: status,
+		Reason: reason,
+	}
+}
+
+// query returns the status of the transaction with the specified hash.
+func (o *outcomes) query(hash string) (TxStatus, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	status, exists := o.status[hash]
+	return status, exists
+}
@@ -0,0 +1,150 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
+)
+
+// Shaker is the behavior required to run the handshake with a peer. It
+// returns the handshake of the peer and the account that signed it.
+type Shaker interface {
+	Handshake(ctx context.Context, pr peer.Peer, hs peer.Handshake) (peer.Handshake, database.AccountID, error)
+}
+
+// HandshakerConfig represents the systems the handshaker needs.
+type HandshakerConfig struct {
+	Host     string
+	Origins  []string
+	Allowed  []database.AccountID
+	State    *state.State
+	Peers    *peer.PeerSet
+	Shaker   Shaker
+	Exporter tracing.Exporter
+	Log      Logger
+}
+
+// Handshaker runs the handshake with the origin peers the node isn't peered
+// with. An origin peer that is down or refuses the handshake is tried again
+// on the next run, so nodes can be started in any order.
+type Handshaker struct {
+	origins  []peer.Peer
+	allowed  map[database.AccountID]struct{}
+	state    *state.State
+	peers    *peer.PeerSet
+	shaker   Shaker
+	exporter tracing.Exporter
+	log      Logger
+}
+
+// NewHandshaker constructs a handshaker for the node listening on the
+// specified private host.
+func NewHandshaker(cfg HandshakerConfig) *Handshaker {
+	log := cfg.Log
+	if log == nil {
+		log = func(string, ...any) {}
+	}
+
+	var origins []peer.Peer
+	for _, host := range cfg.Origins {
+		if pr := peer.New(host); !pr.Match(cfg.Host) {
+			origins = append(origins, pr)
+		}
+	}
+
+	allowed := make(map[database.AccountID]struct{}, len(cfg.Allowed))
+	for _, accountID := range cfg.Allowed {
+		allowed[accountID] = struct{}{}
+	}
+
+	return &Handshaker{
+		origins:  origins,
+		allowed:  allowed,
+		state:    cfg.State,
+		peers:    cfg.Peers,
+		shaker:   cfg.Shaker,
+		exporter: cfg.Exporter,
+		log:      log,
+	}
+}
+
+// Run runs the handshakes every interval until the context is cancelled.
+func (hs *Handshaker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		hs.Handshake(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Handshake runs the handshake with every origin peer that isn't in the
+// peer set yet. The handshake presents the height of the chain at the time
+// it is sent.
+func (hs *Handshaker) Handshake(ctx context.Context) {
+	for _, pr := range hs.origins {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if _, exists := hs.peers.AccountID(pr); exists {
+			continue
+		}
+
+		// Every handshake is its own trace so it can be followed in the
+		// logs of the peer.
+		hsCtx, span := tracing.Start(tracing.NewContext(ctx, hs.exporter), "worker.Handshake")
+		span.SetAttribute("peer.host", pr.Host)
+
+		traceID, _, _ := tracing.FromContext(hsCtx)
+
+		remote, accountID, err := hs.handshake(hsCtx, pr)
+		if err != nil {
+			span.RecordError(err)
+			hs.log("handshake", "traceid", traceID, "status", "peer unreachable or refused", "host", pr.Host, "ERROR", err)
+		} else {
+			hs.log("handshake", "traceid", traceID, "status", "peer added", "host", pr.Host, "accountid", accountID, "height", remote.Height)
+		}
+
+		span.End()
+	}
+}
+
+// =============================================================================
+
+// handshake runs the handshake with the peer and adds it to the peer set
+// when it belongs to the same chain and is run by an allowed account.
+func (hs *Handshaker) handshake(ctx context.Context, pr peer.Peer) (peer.Handshake, database.AccountID, error) {
+	local := peer.NewHandshake(hs.state.Genesis(), hs.state.LatestBlockNumber())
+
+	remote, accountID, err := hs.shaker.Handshake(ctx, pr, local)
+	if err != nil {
+		return peer.Handshake{}, "", err
+	}
+
+	// The peer is only trusted when it is run by an allowed account, the
+	// same rule the peer's requests to this node are held to.
+	if _, exists := hs.allowed[accountID]; !exists {
+		return peer.Handshake{}, "", errors.New("account is not an allowed peer")
+	}
+
+	if err := local.Match(remote); err != nil {
+		return peer.Handshake{}, "", err
+	}
+
+	hs.peers.Add(pr, accountID)
+	hs.peers.SetHeight(pr, remote.Height)
+
+	return remote, accountID, nil
+}
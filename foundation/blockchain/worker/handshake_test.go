@@ -0,0 +1,123 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/worker"
+)
+
+func Test_Handshake(t *testing.T) {
+	t.Log("Given the need to peer with the origin peers.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen an origin peer is down on the first run.", testID)
+		{
+			_, gen := newGenesis(t)
+			local := newState(t, gen, "0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+
+			allowed := accountID(t)
+			shaker := shaker{
+				down:      true,
+				remote:    peer.NewHandshake(gen, 5),
+				accountID: allowed,
+			}
+
+			peers := peer.NewPeerSet(0, time.Minute, nil)
+			handshaker := worker.NewHandshaker(worker.HandshakerConfig{
+				Host:    "local",
+				Origins: []string{"local", "peer0"},
+				Allowed: []database.AccountID{allowed},
+				State:   local,
+				Peers:   peers,
+				Shaker:  &shaker,
+			})
+
+			handshaker.Handshake(context.Background())
+			if _, exists := peers.AccountID(peer.New("peer0")); exists {
+				t.Fatalf("\t%s\tTest %d:\tShould not add a peer that is down.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not add a peer that is down.", success, testID)
+
+			mineBlock(t, local)
+			shaker.down = false
+			handshaker.Handshake(context.Background())
+
+			if id, exists := peers.AccountID(peer.New("peer0")); !exists || id != allowed {
+				t.Fatalf("\t%s\tTest %d:\tShould add the peer once it is up: got[%s] exp[%s]", failed, testID, id, allowed)
+			}
+			t.Logf("\t%s\tTest %d:\tShould add the peer once it is up.", success, testID)
+
+			if shaker.sent.Height != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould send the current height: got[%d] exp[%d]", failed, testID, shaker.sent.Height, 1)
+			}
+			t.Logf("\t%s\tTest %d:\tShould send the current height.", success, testID)
+
+			if len(shaker.hosts) != 2 || shaker.hosts[0] != "peer0" || shaker.hosts[1] != "peer0" {
+				t.Fatalf("\t%s\tTest %d:\tShould only handshake with the other origin peers: got%v", failed, testID, shaker.hosts)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only handshake with the other origin peers.", success, testID)
+
+			handshaker.Handshake(context.Background())
+			if len(shaker.hosts) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould not handshake again with a known peer: got%v", failed, testID, shaker.hosts)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not handshake again with a known peer.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen an origin peer is run by an account that isn't allowed.", testID)
+		{
+			_, gen := newGenesis(t)
+			local := newState(t, gen, "0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+
+			shaker := shaker{
+				remote:    peer.NewHandshake(gen, 0),
+				accountID: accountID(t),
+			}
+
+			peers := peer.NewPeerSet(0, time.Minute, nil)
+			handshaker := worker.NewHandshaker(worker.HandshakerConfig{
+				Host:    "local",
+				Origins: []string{"peer0"},
+				Allowed: []database.AccountID{accountID(t)},
+				State:   local,
+				Peers:   peers,
+				Shaker:  &shaker,
+			})
+			handshaker.Handshake(context.Background())
+
+			if _, exists := peers.AccountID(peer.New("peer0")); exists {
+				t.Fatalf("\t%s\tTest %d:\tShould not add the peer.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not add the peer.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// shaker simulates the handshake with a peer and records the handshakes
+// sent.
+type shaker struct {
+	down      bool
+	remote    peer.Handshake
+	accountID database.AccountID
+	sent      peer.Handshake
+	hosts     []string
+}
+
+func (s *shaker) Handshake(ctx context.Context, pr peer.Peer, hs peer.Handshake) (peer.Handshake, database.AccountID, error) {
+	s.hosts = append(s.hosts, pr.Host)
+	s.sent = hs
+
+	if s.down {
+		return peer.Handshake{}, "", errors.New("connection refused")
+	}
+
+	return s.remote, s.accountID, nil
+}
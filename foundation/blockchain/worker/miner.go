@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
+)
+
+// MinerConfig represents the systems the miner needs.
+type MinerConfig struct {
+	State    *state.State
+	Exporter tracing.Exporter
+	Log      Logger
+}
+
+// Miner mines a new block on top of the chain whenever the mempool holds
+// transactions. The blocks reach the peers when they sync with this node.
+type Miner struct {
+	state    *state.State
+	exporter tracing.Exporter
+	log      Logger
+
+	mu      sync.Mutex
+	running bool
+}
+
+// NewMiner constructs a miner for the node.
+func NewMiner(cfg MinerConfig) *Miner {
+	log := cfg.Log
+	if log == nil {
+		log = func(string, ...any) {}
+	}
+
+	return &Miner{
+		state:    cfg.State,
+		exporter: cfg.Exporter,
+		log:      log,
+	}
+}
+
+// Running reports whether the miner is running.
+func (mn *Miner) Running() bool {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	return mn.running
+}
+
+// Run checks the mempool every interval and mines a block when it holds
+// transactions, until the context is cancelled. Every block mined is its
+// own trace.
+func (mn *Miner) Run(ctx context.Context, interval time.Duration) {
+	mn.setRunning(true)
+	defer mn.setRunning(false)
+
+	ctx = tracing.NewContext(ctx, mn.exporter)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if mn.state.QueryMempoolLength() == 0 {
+			continue
+		}
+
+		block, err := mn.state.MineNewBlock(ctx)
+		switch {
+		case err == nil:
+			mn.log("miner", "status", "block mined", "number", block.Header.Number, "trans", len(block.Trans), "hashrate", mn.state.HashRate())
+
+		case errors.Is(err, context.Canceled):
+			return
+
+		default:
+			mn.log("miner", "status", "mining failed", "ERROR", err)
+		}
+	}
+}
+
+// =============================================================================
+
+// setRunning records whether the miner is running.
+func (mn *Miner) setRunning(running bool) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	mn.running = running
+}
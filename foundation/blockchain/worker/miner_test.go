@@ -0,0 +1,61 @@
+package worker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/worker"
+)
+
+func Test_Miner(t *testing.T) {
+	t.Log("Given the need to mine the transactions of the mempool.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the mempool holds a transaction.", testID)
+		{
+			privateKey, gen := newGenesis(t)
+			st := newState(t, gen, "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+
+			if err := st.UpsertWalletTransaction(signTx(t, privateKey, 1)); err != nil {
+				t.Fatalf("upserting tx: %s", err)
+			}
+
+			miner := worker.NewMiner(worker.MinerConfig{State: st})
+			if miner.Running() {
+				t.Fatalf("\t%s\tTest %d:\tShould not be running before it is started.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be running before it is started.", success, testID)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				miner.Run(ctx, 10*time.Millisecond)
+			}()
+
+			deadline := time.Now().Add(5 * time.Second)
+			for st.LatestBlockNumber() == 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			if st.LatestBlockNumber() != 1 || st.QueryMempoolLength() != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould mine the transaction: got[%d] blocks, [%d] pending", failed, testID, st.LatestBlockNumber(), st.QueryMempoolLength())
+			}
+			t.Logf("\t%s\tTest %d:\tShould mine the transaction.", success, testID)
+
+			if !miner.Running() {
+				t.Fatalf("\t%s\tTest %d:\tShould be running until it is stopped.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be running until it is stopped.", success, testID)
+
+			cancel()
+			<-done
+
+			if miner.Running() {
+				t.Fatalf("\t%s\tTest %d:\tShould not be running once it is stopped.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be running once it is stopped.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+// Package worker implements the background work a node performs to keep
+// its chain in line with its peers and to mine new blocks.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
+)
+
+// Set of limits on how much of the chain is requested at once. They match
+// the limits the private API enforces.
+const (
+	headersPerRequest = 256
+	blocksPerRequest  = 16
+)
+
+// maxSyncHeaders is the number of headers downloaded from a peer in one
+// sync. A chain further ahead is caught up with over several syncs.
+const maxSyncHeaders = 4096
+
+// Fetcher is the behavior required to download the chain from a peer.
+type Fetcher interface {
+	Headers(ctx context.Context, pr peer.Peer, from uint64, limit int) ([]database.BlockHeader, error)
+	Blocks(ctx context.Context, pr peer.Peer, from uint64, limit int) ([]database.Block, error)
+}
+
+// Logger is the function used to report the progress of a sync.
+type Logger func(msg string, keysAndValues ...any)
+
+// Config represents the systems the syncer needs.
+type Config struct {
+	Host     string
+	State    *state.State
+	Peers    *peer.PeerSet
+	Fetcher  Fetcher
+	Exporter tracing.Exporter
+	Log      Logger
+}
+
+// Syncer brings the chain of the node up to date with the longest chain
+// of its peers. The header chain is downloaded and validated first, then
+// the blocks are downloaded from every peer in parallel and checked
+// against their headers before they are imported.
+type Syncer struct {
+	host     string
+	state    *state.State
+	peers    *peer.PeerSet
+	fetcher  Fetcher
+	exporter tracing.Exporter
+	log      Logger
+}
+
+// NewSyncer constructs a syncer for the node listening on the specified
+// private host.
+func NewSyncer(cfg Config) *Syncer {
+	log := cfg.Log
+	if log == nil {
+		log = func(string, ...any) {}
+	}
+
+	return &Syncer{
+		host:     cfg.Host,
+		state:    cfg.State,
+		peers:    cfg.Peers,
+		fetcher:  cfg.Fetcher,
+		exporter: cfg.Exporter,
+		log:      log,
+	}
+}
+
+// Run syncs with the peers every interval until the context is cancelled.
+func (sy *Syncer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sy.Sync(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync syncs with every peer that isn't banned in turn. A peer that sends
+// an invalid header or block is penalized. Every sync is its own trace and
+// the requests to the peers carry it.
+func (sy *Syncer) Sync(ctx context.Context) {
+	ctx, span := tracing.Start(tracing.NewContext(ctx, sy.exporter), "worker.Sync")
+	defer span.End()
+
+	for _, pr := range sy.peers.Copy(sy.host) {
+		if ctx.Err() != nil {
+			return
+		}
+
+		err := sy.syncPeer(ctx, pr)
+		switch {
+		case err == nil:
+
+		case errors.Is(err, database.ErrInvalidBlock):
+			sy.log("sync", "status", "invalid chain", "host", pr.Host, "ERROR", err)
+			sy.penalize(pr, err)
+
+		default:
+			sy.log("sync", "status", "sync failed", "host", pr.Host, "ERROR", err)
+		}
+	}
+}
+
+// =============================================================================
+
+// syncPeer imports the blocks the peer has past the last block both chains
+// share, as long as they make the local chain longer.
+func (sy *Syncer) syncPeer(ctx context.Context, pr peer.Peer) error {
+	ctx, span := tracing.Start(ctx, "worker.syncPeer")
+	defer span.End()
+
+	span.SetAttribute("peer.host", pr.Host)
+
+	if err := sy.syncChain(ctx, pr); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return nil
+}
+
+// syncChain downloads the headers of the peer past the ancestor, validates
+// them and imports the blocks that go with them.
+func (sy *Syncer) syncChain(ctx context.Context, pr peer.Peer) error {
+	latest := sy.state.LatestBlockNumber()
+
+	ancestor, err := sy.findAncestor(ctx, pr, latest)
+	if err != nil {
+		return err
+	}
+
+	headers, err := sy.downloadHeaders(ctx, pr, ancestor)
+	if err != nil {
+		return err
+	}
+
+	// The ancestor found is only a lower bound, so skip the headers that are
+	// already in the chain.
+	for len(headers) > 0 && headers[0].Number <= latest {
+		local := sy.state.QueryHeaders(headers[0].Number, 1)
+		if len(local) == 0 || local[0].Hash() != headers[0].Hash() {
+			break
+		}
+		headers = headers[1:]
+	}
+
+	if len(headers) == 0 {
+		return nil
+	}
+
+	tip := headers[len(headers)-1].Number
+	sy.peers.SetHeight(pr, tip)
+
+	if tip <= latest {
+		return nil
+	}
+
+	if err := sy.state.ValidateHeaders(ctx, headers); err != nil {
+		return err
+	}
+
+	sy.log("sync", "status", "headers validated", "host", pr.Host, "from", headers[0].Number, "to", tip)
+
+	blocks, err := sy.downloadBlocks(ctx, pr, headers)
+	if err != nil {
+		return err
+	}
+
+	// Import the blocks in batches, except that the first batch must take
+	// the chain past its latest block when it replaces blocks.
+	for start := 0; start < len(blocks); {
+		end := start + blocksPerRequest
+		if start == 0 && blocks[0].Header.Number <= latest {
+			end = int(latest-blocks[0].Header.Number) + 2
+		}
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+
+		if err := sy.state.ImportBlocks(ctx, blocks[start:end]); err != nil {
+			// The chain grew while the blocks were downloaded.
+			if errors.Is(err, state.ErrNotLonger) {
+				return nil
+			}
+			return err
+		}
+
+		start = end
+	}
+
+	sy.log("sync", "status", "blocks imported", "host", pr.Host, "from", blocks[0].Header.Number, "to", tip)
+
+	return nil
+}
+
+// findAncestor returns the number of a block the chain shares with the
+// peer. It steps back from the latest block, doubling the step, until the
+// header of the peer matches the local one.
+func (sy *Syncer) findAncestor(ctx context.Context, pr peer.Peer, latest uint64) (uint64, error) {
+	number := latest
+	step := uint64(1)
+
+	for {
+		remote, err := sy.fetcher.Headers(ctx, pr, number, 1)
+		if err != nil {
+			return 0, err
+		}
+
+		local := sy.state.QueryHeaders(number, 1)
+		if len(remote) == 1 && len(local) == 1 && remote[0].Hash() == local[0].Hash() {
+			return number, nil
+		}
+
+		if number == 0 {
+			return 0, fmt.Errorf("%w: genesis block doesn't match", database.ErrInvalidBlock)
+		}
+
+		if number < step {
+			number = 0
+		} else {
+			number -= step
+		}
+		step *= 2
+	}
+}
+
+// downloadHeaders downloads the headers of the peer after the ancestor.
+func (sy *Syncer) downloadHeaders(ctx context.Context, pr peer.Peer, ancestor uint64) ([]database.BlockHeader, error) {
+	var headers []database.BlockHeader
+
+	from := ancestor + 1
+	for len(headers) < maxSyncHeaders {
+		batch, err := sy.fetcher.Headers(ctx, pr, from, headersPerRequest)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, header := range batch {
+			if header.Number != from+uint64(i) {
+				return nil, fmt.Errorf("%w: got header %d, exp %d", database.ErrInvalidBlock, header.Number, from+uint64(i))
+			}
+		}
+
+		headers = append(headers, batch...)
+		if len(batch) < headersPerRequest {
+			break
+		}
+		from += uint64(len(batch))
+	}
+
+	return headers, nil
+}
+
+// downloadBlocks downloads the blocks of the headers in batches from every
+// peer in parallel, with the batches dealt to the peers in turn. Every
+// block must match its header. A batch a peer fails to provide is
+// downloaded again from the peer the headers came from.
+func (sy *Syncer) downloadBlocks(ctx context.Context, source peer.Peer, headers []database.BlockHeader) ([]database.Block, error) {
+	blocks := make([]database.Block, len(headers))
+
+	var batches []int
+	for start := 0; start < len(headers); start += blocksPerRequest {
+		batches = append(batches, start)
+	}
+
+	peers := sy.peers.Copy(sy.host)
+	if len(peers) == 0 {
+		peers = []peer.Peer{source}
+	}
+	if len(peers) > len(batches) {
+		peers = peers[:len(batches)]
+	}
+
+	var mu sync.Mutex
+	var failed []int
+
+	var wg sync.WaitGroup
+	wg.Add(len(peers))
+	for i, pr := range peers {
+		go func(i int, pr peer.Peer) {
+			defer wg.Done()
+
+			for b := i; b < len(batches); b += len(peers) {
+				start := batches[b]
+				if err := sy.downloadBatch(ctx, pr, headers, blocks, start); err != nil {
+					sy.log("sync", "status", "block download failed", "host", pr.Host, "from", headers[start].Number, "ERROR", err)
+					if errors.Is(err, database.ErrInvalidBlock) {
+						sy.penalize(pr, err)
+					}
+
+					mu.Lock()
+					failed = append(failed, start)
+					mu.Unlock()
+				}
+			}
+		}(i, pr)
+	}
+	wg.Wait()
+
+	// The batches no peer provided are retried with the source of the
+	// headers, which must have the blocks.
+	for _, start := range failed {
+		if err := sy.downloadBatch(ctx, source, headers, blocks, start); err != nil {
+			return nil, err
+		}
+	}
+
+	return blocks, nil
+}
+
+// downloadBatch downloads the batch of blocks starting at the specified
+// index of the headers and checks every block matches its header.
+func (sy *Syncer) downloadBatch(ctx context.Context, pr peer.Peer, headers []database.BlockHeader, blocks []database.Block, start int) error {
+	end := start + blocksPerRequest
+	if end > len(headers) {
+		end = len(headers)
+	}
+
+	batch, err := sy.fetcher.Blocks(ctx, pr, headers[start].Number, end-start)
+	if err != nil {
+		return err
+	}
+
+	if len(batch) != end-start {
+		return fmt.Errorf("got %d blocks, exp %d", len(batch), end-start)
+	}
+
+	for i, block := range batch {
+		header := headers[start+i]
+		if block.Hash() != header.Hash() {
+			return fmt.Errorf("%w: block %d doesn't match its header", database.ErrInvalidBlock, header.Number)
+		}
+
+		if err := block.ValidateBody(); err != nil {
+			return err
+		}
+
+		blocks[start+i] = block
+	}
+
+	return nil
+}
+
+// penalize lowers the score of the account running the peer.
+func (sy *Syncer) penalize(pr peer.Peer, err error) {
+	if accountID, exists := sy.peers.AccountID(pr); exists {
+		sy.peers.Penalize(accountID, peer.PenaltyInvalidBlock, err.Error())
+	}
+}
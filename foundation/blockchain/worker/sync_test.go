@@ -0,0 +1,354 @@
+package worker_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/database"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/genesis"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/peer"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/state"
+	"github.com/bruno-sartori/go-blockchain/foundation/blockchain/worker"
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// Set of behaviors of a simulated peer.
+const (
+	honest    = "honest"
+	badBodies = "bad bodies"
+	badHeader = "bad header"
+)
+
+func Test_Sync(t *testing.T) {
+	tt := []struct {
+		name      string
+		local     int
+		peers     []string
+		synced    bool
+		penalized []int
+	}{
+		{"node behind an honest peer", 0, []string{honest}, true, nil},
+		{"node on a shorter fork", 1, []string{honest}, true, nil},
+		{"peer sending bad bodies", 0, []string{honest, badBodies}, true, []int{1}},
+		{"peer sending a bad header", 0, []string{badHeader}, false, []int{0}},
+	}
+
+	t.Log("Given the need to sync the chain from peers.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					privateKey, gen := newGenesis(t)
+
+					// The remote chain has blocks with transactions so a
+					// changed body can be detected.
+					remote := newState(t, gen, "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+					for nonce := uint64(1); nonce <= 20; nonce++ {
+						if err := remote.UpsertWalletTransaction(signTx(t, privateKey, nonce)); err != nil {
+							t.Fatalf("upserting tx: %s", err)
+						}
+						mineBlock(t, remote)
+					}
+
+					local := newState(t, gen, "0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+					for i := 0; i < tst.local; i++ {
+						mineBlock(t, local)
+					}
+
+					peers := peer.NewPeerSet(0, time.Minute, nil)
+					fetcher := fetcher{state: remote, behaviors: make(map[string]string)}
+					for i, behavior := range tst.peers {
+						pr := peer.New(fmt.Sprintf("peer%d", i))
+						peers.Add(pr, accountID(t))
+						fetcher.behaviors[pr.Host] = behavior
+					}
+
+					syncer := worker.NewSyncer(worker.Config{
+						Host:    "local",
+						State:   local,
+						Peers:   peers,
+						Fetcher: fetcher,
+					})
+					syncer.Sync(context.Background())
+
+					synced := local.LatestBlock().Hash() == remote.LatestBlock().Hash()
+					if synced != tst.synced {
+						t.Fatalf("\t%s\tTest %d:\tShould sync as expected: got[%v] exp[%v] at block %d", failed, testID, synced, tst.synced, local.LatestBlockNumber())
+					}
+					t.Logf("\t%s\tTest %d:\tShould sync as expected.", success, testID)
+
+					statuses := peers.Statuses()
+					for i, status := range statuses {
+						exp := false
+						for _, p := range tst.penalized {
+							exp = exp || p == i
+						}
+
+						if penalized := status.Score < peer.InitialScore; penalized != exp {
+							t.Fatalf("\t%s\tTest %d:\tShould only penalize the peers sending invalid data: %+v", failed, testID, status)
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould only penalize the peers sending invalid data.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_SyncCancel(t *testing.T) {
+	t.Log("Given the need to stop syncing on shutdown.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the context is cancelled.", testID)
+		{
+			_, gen := newGenesis(t)
+
+			remote := newState(t, gen, "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+			mineBlock(t, remote)
+
+			local := newState(t, gen, "0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+
+			peers := peer.NewPeerSet(0, time.Minute, nil)
+			peers.Add(peer.New("peer0"), accountID(t))
+
+			syncer := worker.NewSyncer(worker.Config{
+				Host:    "local",
+				State:   local,
+				Peers:   peers,
+				Fetcher: fetcher{state: remote, behaviors: map[string]string{"peer0": honest}},
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			syncer.Sync(ctx)
+
+			if latest := local.LatestBlockNumber(); latest != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould not import blocks: got[%d]", failed, testID, latest)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not import blocks.", success, testID)
+		}
+	}
+}
+
+func Test_SyncSpans(t *testing.T) {
+	t.Log("Given the need to trace the work of a sync.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen syncing blocks from a peer.", testID)
+		{
+			_, gen := newGenesis(t)
+
+			remote := newState(t, gen, "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+			mineBlock(t, remote)
+
+			local := newState(t, gen, "0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+
+			peers := peer.NewPeerSet(0, time.Minute, nil)
+			peers.Add(peer.New("peer0"), accountID(t))
+
+			var rec recorder
+			syncer := worker.NewSyncer(worker.Config{
+				Host:     "local",
+				State:    local,
+				Peers:    peers,
+				Fetcher:  fetcher{state: remote, behaviors: map[string]string{"peer0": honest}},
+				Exporter: &rec,
+			})
+			syncer.Sync(context.Background())
+
+			if latest := local.LatestBlockNumber(); latest != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould import the block: got[%d]", failed, testID, latest)
+			}
+			t.Logf("\t%s\tTest %d:\tShould import the block.", success, testID)
+
+			spans := rec.byName()
+
+			root, exists := spans["worker.Sync"]
+			if !exists || root.ParentID != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould export the sync as the root span: %v", failed, testID, spans)
+			}
+			t.Logf("\t%s\tTest %d:\tShould export the sync as the root span.", success, testID)
+
+			parents := map[string]string{
+				"worker.syncPeer":       "worker.Sync",
+				"state.ValidateHeaders": "worker.syncPeer",
+				"state.ImportBlocks":    "worker.syncPeer",
+			}
+			for name, parent := range parents {
+				span, exists := spans[name]
+				if !exists || span.TraceID != root.TraceID || span.ParentID != spans[parent].SpanID {
+					t.Fatalf("\t%s\tTest %d:\tShould export %s as a child of %s: %v", failed, testID, name, parent, spans)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould export the peer sync, the validation and the import as child spans.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// recorder keeps the spans exported to it.
+type recorder struct {
+	mu    sync.Mutex
+	spans []tracing.Span
+}
+
+// Export implements the tracing.Exporter interface.
+func (r *recorder) Export(span tracing.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans = append(r.spans, span)
+}
+
+// byName returns the spans recorded by their name.
+func (r *recorder) byName() map[string]tracing.Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spans := make(map[string]tracing.Span)
+	for _, span := range r.spans {
+		spans[span.Name] = span
+	}
+
+	return spans
+}
+
+// fetcher serves the chain of a state as a set of peers with different
+// behaviors.
+type fetcher struct {
+	state     *state.State
+	behaviors map[string]string
+}
+
+// Headers implements the worker.Fetcher interface.
+func (f fetcher) Headers(ctx context.Context, pr peer.Peer, from uint64, limit int) ([]database.BlockHeader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	headers := f.state.QueryHeaders(from, limit)
+	if f.behaviors[pr.Host] == badHeader && len(headers) > 1 {
+		headers[1].MiningReward++
+	}
+
+	return headers, nil
+}
+
+// Blocks implements the worker.Fetcher interface.
+func (f fetcher) Blocks(ctx context.Context, pr peer.Peer, from uint64, limit int) ([]database.Block, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	blocks, err := f.state.QueryBlocks(from, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.behaviors[pr.Host] == badBodies {
+		for i := range blocks {
+			blocks[i].Trans = nil
+		}
+	}
+
+	return blocks, nil
+}
+
+// newGenesis returns a genesis that funds a new account.
+func newGenesis(t *testing.T) (*ecdsa.PrivateKey, genesis.Genesis) {
+	t.Helper()
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+
+	gen := genesis.Genesis{
+		Date:          time.Date(2021, time.December, 17, 0, 0, 0, 0, time.UTC),
+		ChainID:       1,
+		TransPerBlock: 10,
+		Difficulty:    1,
+		MiningReward:  700,
+		GasPrice:      15,
+		Balances: map[string]uint64{
+			string(database.PublicKeyToAccountID(privateKey.PublicKey)): 1_000_000,
+		},
+	}
+
+	return privateKey, gen
+}
+
+// newState starts a state in a temporary folder.
+func newState(t *testing.T, gen genesis.Genesis, beneficiaryID database.AccountID) *state.State {
+	t.Helper()
+
+	st, err := state.New(state.Config{
+		BeneficiaryID: beneficiaryID,
+		DBPath:        t.TempDir(),
+		Genesis:       gen,
+		Evts:          events.New(),
+	})
+	if err != nil {
+		t.Fatalf("starting state: %s", err)
+	}
+	t.Cleanup(func() { st.Shutdown() })
+
+	return st
+}
+
+// signTx signs a transaction from the funded account.
+func signTx(t *testing.T, privateKey *ecdsa.PrivateKey, nonce uint64) database.SignedTx {
+	t.Helper()
+
+	tx, err := database.NewTx(1, nonce, database.PublicKeyToAccountID(privateKey.PublicKey), "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76", 10, 1, nil)
+	if err != nil {
+		t.Fatalf("constructing tx: %s", err)
+	}
+
+	signedTx, err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("signing tx: %s", err)
+	}
+
+	return signedTx
+}
+
+// mineBlock mines the next block of the state.
+func mineBlock(t *testing.T, st *state.State) database.Block {
+	t.Helper()
+
+	block, err := st.MineNewBlock(context.Background())
+	if err != nil {
+		t.Fatalf("mining block: %s", err)
+	}
+
+	return block
+}
+
+// accountID returns the account of a new key.
+func accountID(t *testing.T) database.AccountID {
+	t.Helper()
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+
+	return database.PublicKeyToAccountID(privateKey.PublicKey)
+}
@@ -0,0 +1,332 @@
+// Package config adds support for a YAML configuration file to the conf
+// package. Values in the file sit between the defaults and the environment
+// variables, so env vars and flags still override the file.
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ardanlabs/conf/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Set of layers a configuration value can come from.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// key is the name of the flag and env var that hold the path of the file.
+const key = "config"
+
+// File represents a configuration file. It implements the conf.Parsers
+// interface so it can be handed to conf.Parse.
+type File struct {
+	path   string
+	values map[string]*yaml.Node
+	set    map[string]bool
+}
+
+// Path returns the path of the configuration file from the --config flag
+// or the config env var of the prefix. It returns an empty string when
+// no file was specified.
+func Path(prefix string, args []string) string {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != key {
+			continue
+		}
+
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+
+	return os.Getenv(envName(prefix, key))
+}
+
+// Load reads the configuration file at the specified path. Only YAML files
+// are supported.
+func Load(path string) (*File, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	default:
+		return nil, fmt.Errorf("config file %q: only yaml files are supported", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	// An empty file holds no document and decoding returns io.EOF.
+	var root yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&root); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing config file %q: %w", path, err)
+	}
+
+	values := make(map[string]*yaml.Node)
+	if len(root.Content) > 0 {
+		if err := flatten(root.Content[0], "", values); err != nil {
+			return nil, fmt.Errorf("config file %q: %w", path, err)
+		}
+	}
+
+	f := File{
+		path:   path,
+		values: values,
+		set:    make(map[string]bool),
+	}
+
+	return &f, nil
+}
+
+// Parse parses the configuration like conf.Parse with the file layered
+// between the defaults and the environment variables. The file can be nil
+// when none was loaded.
+//
+// conf only applies a default to a field that is still zero, so the file is
+// applied before conf runs. A zero value in the file is replaced by the
+// default though, so the file is applied again afterwards to the fields
+// that no env var or flag set.
+func Parse(prefix string, cfg any, f *File) (string, error) {
+	if f == nil {
+		return conf.Parse(prefix, cfg)
+	}
+
+	help, err := conf.Parse(prefix, cfg, f)
+	if err != nil {
+		return help, err
+	}
+
+	args := os.Args[1:]
+	overridden := func(name string) bool {
+		return hasFlag(args, name) || hasEnv(prefix, name)
+	}
+	if err := f.apply(cfg, overridden); err != nil {
+		return "", fmt.Errorf("parsing config: %w", err)
+	}
+
+	return "", nil
+}
+
+// Process implements the conf.Parsers interface. Every value of the file is
+// decoded into the field of the config it names. Keys that don't match a
+// field of the config fail the parsing.
+func (f *File) Process(prefix string, cfg any) error {
+	return f.apply(cfg, func(string) bool { return false })
+}
+
+// String returns the configuration like conf.String with the layer every
+// value came from. The file can be nil when none was loaded.
+func String(prefix string, cfg any, f *File, args []string) (string, error) {
+	out, err := conf.String(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(out, "\n")
+	for i, line := range lines {
+		name := normalize(flagName(line))
+
+		source := SourceDefault
+		switch {
+		case hasFlag(args, name):
+			source = SourceFlag
+		case hasEnv(prefix, name):
+			source = SourceEnv
+		case f != nil && f.set[name]:
+			source = SourceFile
+		}
+
+		lines[i] = line + " (" + source + ")"
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// =============================================================================
+
+// apply decodes every value of the file into its field of the config,
+// except for the fields the skip function reports. The fields set are
+// recorded so String can report them.
+func (f *File) apply(cfg any, skip func(name string) bool) error {
+	flds, err := fields(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Match the keys of the file against the fields ignoring case and
+	// separators, so web.read_timeout and web.readtimeout both work.
+	keys := make([]string, 0, len(f.values))
+	for k := range f.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var unknown []string
+	for _, k := range keys {
+		name := normalize(k)
+		field, exists := flds[name]
+		if !exists || name == key {
+			unknown = append(unknown, k)
+			continue
+		}
+
+		if skip(name) {
+			continue
+		}
+
+		if err := f.values[k].Decode(field.Addr().Interface()); err != nil {
+			return fmt.Errorf("config file %q: %s: %w", f.path, k, err)
+		}
+		f.set[name] = true
+	}
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("config file %q: unknown keys: %s", f.path, strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// flatten walks the YAML mapping and collects the values by their dotted
+// key. Mappings are walked into, anything else is a value.
+func flatten(node *yaml.Node, prefix string, values map[string]*yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: expected a mapping", keyName(prefix))
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k := node.Content[i].Value
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+
+		v := node.Content[i+1]
+		switch v.Kind {
+		case yaml.ScalarNode, yaml.SequenceNode:
+			values[k] = v
+
+		case yaml.MappingNode:
+			if err := flatten(v, k, values); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("%s: unsupported value", k)
+		}
+	}
+
+	return nil
+}
+
+// fields returns every field of the config that conf sets, including the
+// ones it doesn't print, by the normalized path of their names. The fields
+// of embedded structs sit at the level of the struct like conf has them.
+func fields(cfg any) (map[string]reflect.Value, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, conf.ErrInvalidStruct
+	}
+
+	flds := make(map[string]reflect.Value)
+	walk(v.Elem(), "", flds)
+
+	return flds, nil
+}
+
+// walk adds the fields of the struct to the map, walking into the structs
+// that can't decode themselves.
+func walk(v reflect.Value, prefix string, flds map[string]reflect.Value) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		sf := t.Field(i)
+		fv := v.Field(i)
+		if !fv.CanSet() || sf.Tag.Get("conf") == "-" {
+			continue
+		}
+
+		name := prefix + sf.Name
+		if sf.Anonymous {
+			name = prefix
+		}
+
+		if _, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); !ok && fv.Kind() == reflect.Struct {
+			walk(fv, name, flds)
+			continue
+		}
+
+		flds[normalize(name)] = fv
+	}
+}
+
+// flagName returns the flag name of a line written by conf.String, which
+// looks like --name=value or --name/-n=value.
+func flagName(line string) string {
+	flag, _, _ := strings.Cut(strings.TrimPrefix(line, "--"), "=")
+	flag, _, _ = strings.Cut(flag, "/")
+	return flag
+}
+
+// hasFlag reports whether the flag of the field with the normalized name
+// was provided on the command line.
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		flag, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if normalize(flag) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEnv reports whether the env var of the field with the normalized name
+// is set.
+func hasEnv(prefix string, name string) bool {
+	prefix = strings.ToUpper(prefix) + "_"
+	for _, kv := range os.Environ() {
+		env, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(env, prefix) && normalize(strings.TrimPrefix(env, prefix)) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// envName returns the env var conf reads for the flag.
+func envName(prefix string, flag string) string {
+	return strings.ToUpper(prefix + "_" + strings.ReplaceAll(flag, "-", "_"))
+}
+
+// normalize drops the separators and case of a key so keys written in
+// different styles match.
+func normalize(k string) string {
+	return strings.NewReplacer(".", "", "-", "", "_", "").Replace(strings.ToLower(k))
+}
+
+// keyName returns the key for error messages.
+func keyName(k string) string {
+	if k == "" {
+		return "document"
+	}
+	return k
+}
@@ -0,0 +1,206 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/conf/v3"
+	"github.com/bruno-sartori/go-blockchain/foundation/config"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// prefix is the prefix of the env vars of the tests.
+const prefix = "CFGTEST"
+
+// testConfig represents a configuration like the one of the node.
+type testConfig struct {
+	conf.Version
+	Config string
+	Web    struct {
+		ReadTimeout time.Duration `conf:"default:5s"`
+		PublicHost  string        `conf:"default:0.0.0.0:8080"`
+		APIKey      string        `conf:"default:none,noprint"`
+	}
+	State struct {
+		OriginPeers     []string `conf:"default:0.0.0.0:9080"`
+		MaxBlocksBehind uint64   `conf:"default:2"`
+	}
+}
+
+func Test_Parse(t *testing.T) {
+	tt := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		args    []string
+		success bool
+		check   func(cfg testConfig) bool
+	}{
+		{
+			"file over the defaults",
+			"web:\n  read_timeout: 7s\nstate:\n  origin_peers: [a, b]\n",
+			nil, nil, true,
+			func(cfg testConfig) bool {
+				return cfg.Web.ReadTimeout == 7*time.Second && strings.Join(cfg.State.OriginPeers, ";") == "a;b" && cfg.Web.PublicHost == "0.0.0.0:8080"
+			},
+		},
+		{
+			"env over the file",
+			"web:\n  public_host: file:1\n",
+			map[string]string{prefix + "_WEB_PUBLIC_HOST": "env:1"}, nil, true,
+			func(cfg testConfig) bool { return cfg.Web.PublicHost == "env:1" },
+		},
+		{
+			"flag over the env",
+			"web:\n  public_host: file:1\n",
+			map[string]string{prefix + "_WEB_PUBLIC_HOST": "env:1"}, []string{"--web-public-host=flag:1"}, true,
+			func(cfg testConfig) bool { return cfg.Web.PublicHost == "flag:1" },
+		},
+		{
+			"zero value over a default",
+			"state:\n  max_blocks_behind: 0\n",
+			nil, nil, true,
+			func(cfg testConfig) bool { return cfg.State.MaxBlocksBehind == 0 },
+		},
+		{
+			"field that is not printed",
+			"web:\n  api_key: secret\n",
+			nil, nil, true,
+			func(cfg testConfig) bool { return cfg.Web.APIKey == "secret" },
+		},
+		{
+			"key in camel case",
+			"Web:\n  ReadTimeout: 9s\n",
+			nil, nil, true,
+			func(cfg testConfig) bool { return cfg.Web.ReadTimeout == 9*time.Second },
+		},
+		{
+			"dotted key without separators",
+			"web.readtimeout: 9s\n",
+			nil, nil, true,
+			func(cfg testConfig) bool { return cfg.Web.ReadTimeout == 9*time.Second },
+		},
+		{
+			"unknown key",
+			"web:\n  bogus: 1\n",
+			nil, nil, false, nil,
+		},
+		{
+			"config key",
+			"config: other.yaml\n",
+			nil, nil, false, nil,
+		},
+		{
+			"value of the wrong type",
+			"web:\n  read_timeout: soon\n",
+			nil, nil, false, nil,
+		},
+	}
+
+	t.Log("Given the need to layer a config file between the defaults and the env vars.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					for k, v := range tst.env {
+						t.Setenv(k, v)
+					}
+					setArgs(t, tst.args)
+
+					file := loadFile(t, tst.file)
+
+					var cfg testConfig
+					_, err := config.Parse(prefix, &cfg, file)
+					if success := err == nil; success != tst.success {
+						t.Fatalf("\t%s\tTest %d:\tShould parse as expected: got[%v] exp[%v] err[%v]", failed, testID, success, tst.success, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould parse as expected.", success, testID)
+
+					if tst.check != nil && !tst.check(cfg) {
+						t.Fatalf("\t%s\tTest %d:\tShould get the expected config: got[%+v]", failed, testID, cfg)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the expected config.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_String(t *testing.T) {
+	t.Log("Given the need to show where every config value came from.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the values come from every layer.", testID)
+		{
+			args := []string{"--state-origin-peers=flag:1"}
+			t.Setenv(prefix+"_WEB_PUBLIC_HOST", "env:1")
+			setArgs(t, args)
+
+			file := loadFile(t, "web:\n  read_timeout: 7s\n  public_host: file:1\n")
+
+			var cfg testConfig
+			if _, err := config.Parse(prefix, &cfg, file); err != nil {
+				t.Fatalf("parsing config: %s", err)
+			}
+
+			out, err := config.String(prefix, &cfg, file, args)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould write the config: %s", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould write the config.", success, testID)
+
+			exp := []string{
+				"--web-read-timeout=7s (file)",
+				"--web-public-host=env:1 (env)",
+				"--state-origin-peers=[flag:1] (flag)",
+				"--state-max-blocks-behind=2 (default)",
+			}
+			for _, line := range exp {
+				if !strings.Contains(out, line) {
+					t.Fatalf("\t%s\tTest %d:\tShould show the layer of %q: got\n%s", failed, testID, line, out)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould show the layer of every value.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// loadFile writes the config file to a temporary folder and loads it.
+func loadFile(t *testing.T, data string) *config.File {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("writing config file: %s", err)
+	}
+
+	file, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("loading config file: %s", err)
+	}
+
+	return file
+}
+
+// setArgs replaces the command line arguments conf parses until the test
+// completes.
+func setArgs(t *testing.T, args []string) {
+	t.Helper()
+
+	orig := os.Args
+	os.Args = append([]string{"test"}, args...)
+	t.Cleanup(func() { os.Args = orig })
+}
@@ -0,0 +1,224 @@
+// Package events allows for the registration and receiving of events
+// generated by the blockchain.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Set of event types the blockchain generates.
+const (
+	TypeNewBlock   = "new_block"
+	TypePendingTx  = "pending_tx"
+	TypeReorg      = "reorg"
+	TypePeerChange = "peer_change"
+)
+
+// bufferSize is the number of events a subscriber can fall behind before
+// it is dropped. Publishing never blocks on a slow subscriber.
+const bufferSize = 100
+
+// historySize is the number of past events kept so a client that
+// reconnects can resume from the last event it received.
+const historySize = 1000
+
+// Event represents something that happened in the blockchain. The ID is
+// assigned in sending order when the event is sent. Accounts holds the
+// accounts involved so subscribers can filter on them.
+//
+// The IDs continue from the time the node started in microseconds, so the
+// IDs of a node that restarted are higher than the ones clients received
+// before the restart.
+type Event struct {
+	ID       uint64    `json:"id"`
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Accounts []string  `json:"accounts,omitempty"`
+	Data     any       `json:"data"`
+}
+
+// NewEvent constructs an event of the specified type.
+func NewEvent(typ string, data any, accounts ...string) Event {
+	return Event{
+		Type:     typ,
+		Time:     time.Now().UTC(),
+		Accounts: accounts,
+		Data:     data,
+	}
+}
+
+// =============================================================================
+
+// subscriber represents a single receiver of events.
+type subscriber struct {
+	ch       chan Event
+	accounts map[string]struct{}
+}
+
+// match reports whether the event involves one of the accounts the
+// subscriber is filtering on. A subscriber with no filter gets everything.
+func (s subscriber) match(ev Event) bool {
+	if len(s.accounts) == 0 {
+		return true
+	}
+
+	for _, account := range ev.Accounts {
+		if _, exists := s.accounts[account]; exists {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Events maintains a mapping of unique id and channels so goroutines
+// can register and receive events.
+type Events struct {
+	mu      sync.Mutex
+	m       map[string]subscriber
+	lastID  uint64
+	history []Event
+	start   int
+	closed  bool
+}
+
+// New constructs an events for registering and receiving events.
+func New() *Events {
+	return &Events{
+		m:       make(map[string]subscriber),
+		lastID:  uint64(time.Now().UnixMicro()),
+		history: make([]Event, 0, historySize),
+	}
+}
+
+// Shutdown closes and removes all channels that were provided by
+// the call to Acquire.
+func (evt *Events) Shutdown() {
+	evt.mu.Lock()
+	defer evt.mu.Unlock()
+
+	for id, sub := range evt.m {
+		delete(evt.m, id)
+		close(sub.ch)
+	}
+	evt.closed = true
+}
+
+// Acquire takes a unique id and returns a channel that can be used
+// to receive events. Only events involving one of the specified accounts
+// are received unless no account is specified. The channel is closed if
+// the receiver falls too far behind or after a shutdown.
+func (evt *Events) Acquire(id string, accounts ...string) <-chan Event {
+	evt.mu.Lock()
+	defer evt.mu.Unlock()
+
+	if evt.closed {
+		ch := make(chan Event)
+		close(ch)
+		return ch
+	}
+
+	sub, exists := evt.m[id]
+	if exists {
+		return sub.ch
+	}
+
+	sub = subscriber{
+		ch:       make(chan Event, bufferSize),
+		accounts: make(map[string]struct{}, len(accounts)),
+	}
+	for _, account := range accounts {
+		sub.accounts[account] = struct{}{}
+	}
+	evt.m[id] = sub
+
+	return sub.ch
+}
+
+// Release closes and removes the channel that was provided by
+// the call to Acquire.
+func (evt *Events) Release(id string) {
+	evt.mu.Lock()
+	defer evt.mu.Unlock()
+
+	sub, exists := evt.m[id]
+	if !exists {
+		return
+	}
+
+	delete(evt.m, id)
+	close(sub.ch)
+}
+
+// Subscribers returns the number of channels provided by Acquire that are
+// still open.
+func (evt *Events) Subscribers() int {
+	evt.mu.Lock()
+	defer evt.mu.Unlock()
+
+	return len(evt.m)
+}
+
+// Since returns the events kept in history that were sent after the event
+// with the specified id and involve one of the specified accounts, along
+// with the id of the last event sent. Events that are older than the
+// history are lost. An id that was never sent, like one from another node,
+// returns the whole history.
+func (evt *Events) Since(id uint64, accounts ...string) ([]Event, uint64) {
+	evt.mu.Lock()
+	defer evt.mu.Unlock()
+
+	if id > evt.lastID {
+		id = 0
+	}
+
+	sub := subscriber{
+		accounts: make(map[string]struct{}, len(accounts)),
+	}
+	for _, account := range accounts {
+		sub.accounts[account] = struct{}{}
+	}
+
+	var evs []Event
+	for i := range evt.history {
+		ev := evt.history[(evt.start+i)%len(evt.history)]
+		if ev.ID > id && sub.match(ev) {
+			evs = append(evs, ev)
+		}
+	}
+
+	return evs, evt.lastID
+}
+
+// Send signals an event to every matching subscriber. A subscriber whose
+// buffer is full is dropped instead of blocking the sender.
+func (evt *Events) Send(ev Event) {
+	evt.mu.Lock()
+	defer evt.mu.Unlock()
+
+	evt.lastID++
+	ev.ID = evt.lastID
+
+	// Once the history is full it is used as a ring buffer where the
+	// oldest event is overwritten.
+	if len(evt.history) < historySize {
+		evt.history = append(evt.history, ev)
+	} else {
+		evt.history[evt.start] = ev
+		evt.start = (evt.start + 1) % historySize
+	}
+
+	for id, sub := range evt.m {
+		if !sub.match(ev) {
+			continue
+		}
+
+		select {
+		case sub.ch <- ev:
+		default:
+			delete(evt.m, id)
+			close(sub.ch)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/events"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// historySize matches the number of events the history keeps.
+const historySize = 1000
+
+func Test_Since(t *testing.T) {
+	evts := events.New()
+
+	// Send more events than the history holds so the oldest are dropped.
+	// Every tenth event involves account a.
+	const sent = historySize + 5
+	ids := make([]uint64, sent)
+	ch := evts.Acquire("test")
+	for i := range ids {
+		var accounts []string
+		if i%10 == 0 {
+			accounts = []string{"a"}
+		}
+		evts.Send(events.NewEvent(events.TypePendingTx, i, accounts...))
+		ids[i] = (<-ch).ID
+	}
+
+	tt := []struct {
+		name     string
+		id       uint64
+		accounts []string
+		exp      int
+	}{
+		{"client at the last event", ids[sent-1], nil, 0},
+		{"client a few events behind", ids[sent-4], nil, 3},
+		{"client behind the history", ids[0], nil, historySize},
+		{"client without an id", 0, nil, historySize},
+		{"client ahead of the node", ids[sent-1] + 1000, nil, historySize},
+		{"client filtering on an account", 0, []string{"a"}, historySize / 10},
+	}
+
+	t.Log("Given the need to resume an event stream.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					evs, lastID := evts.Since(tst.id, tst.accounts...)
+
+					if len(evs) != tst.exp {
+						t.Fatalf("\t%s\tTest %d:\tShould get the missed events: got[%d] exp[%d]", failed, testID, len(evs), tst.exp)
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the missed events.", success, testID)
+
+					for i := 1; i < len(evs); i++ {
+						if evs[i].ID <= evs[i-1].ID {
+							t.Fatalf("\t%s\tTest %d:\tShould get the events in order: got[%d] after[%d]", failed, testID, evs[i].ID, evs[i-1].ID)
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the events in order.", success, testID)
+
+					if lastID != ids[sent-1] {
+						t.Fatalf("\t%s\tTest %d:\tShould get the id of the last event: got[%d] exp[%d]", failed, testID, lastID, ids[sent-1])
+					}
+					t.Logf("\t%s\tTest %d:\tShould get the id of the last event.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_Restart(t *testing.T) {
+	t.Log("Given the need to keep event ids unique across restarts.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the node restarts.", testID)
+		{
+			before := events.New()
+			ch := before.Acquire("test")
+			before.Send(events.NewEvent(events.TypePendingTx, nil))
+			old := (<-ch).ID
+
+			time.Sleep(time.Millisecond)
+
+			after := events.New()
+			ch = after.Acquire("test")
+			after.Send(events.NewEvent(events.TypePendingTx, nil))
+
+			if id := (<-ch).ID; id <= old {
+				t.Fatalf("\t%s\tTest %d:\tShould continue after the ids sent before: got[%d] old[%d]", failed, testID, id, old)
+			}
+			t.Logf("\t%s\tTest %d:\tShould continue after the ids sent before.", success, testID)
+
+			if evs, _ := after.Since(old); len(evs) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould send the new events to a client resuming: got[%d] exp[1]", failed, testID, len(evs))
+			}
+			t.Logf("\t%s\tTest %d:\tShould send the new events to a client resuming.", success, testID)
+		}
+	}
+}
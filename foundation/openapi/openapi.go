@@ -0,0 +1,346 @@
+// Package openapi generates an OpenAPI 3 document from the routes registered
+// with a web application.
+package openapi
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/web"
+)
+
+// Version is the version of the OpenAPI specification generated.
+const Version = "3.0.3"
+
+// Info represents the metadata about the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Document represents an OpenAPI document.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Components holds the schemas referenced from the operations.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Operation represents a single method on a path.
+type Operation struct {
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter represents a path or query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody represents the body an operation accepts.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response represents a response an operation returns.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema represents the subset of the JSON schema used to describe Go types.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// =============================================================================
+
+// New generates the document for the specified routes. The error response is
+// a value of the type returned by every route that fails. Preflight routes
+// are left out.
+func New(info Info, routes []web.Route, errorResponse any) Document {
+	doc := Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]map[string]Operation),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+		},
+	}
+
+	for _, route := range routes {
+		if route.Method == http.MethodOptions {
+			continue
+		}
+
+		path, params := convertPath(route.Path)
+
+		op := Operation{
+			Summary:     route.Doc.Summary,
+			Description: route.Doc.Description,
+			Parameters:  params,
+			Responses:   make(map[string]Response),
+		}
+
+		for _, qp := range route.Doc.Query {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        qp.Name,
+				In:          "query",
+				Description: qp.Description,
+				Schema:      &Schema{Type: "string"},
+			})
+		}
+
+		if route.Doc.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"application/json": {Schema: doc.schema(reflect.TypeOf(route.Doc.Request))},
+				},
+			}
+		}
+
+		statusCode := route.Doc.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+
+		resp := Response{
+			Description: http.StatusText(statusCode),
+		}
+		if route.Doc.Response != nil {
+			contentType := route.Doc.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			resp.Content = map[string]MediaType{
+				contentType: {Schema: doc.schema(reflect.TypeOf(route.Doc.Response))},
+			}
+		}
+		op.Responses[strconv.Itoa(statusCode)] = resp
+
+		if errorResponse != nil {
+			op.Responses["default"] = Response{
+				Description: "Error",
+				Content: map[string]MediaType{
+					"application/json": {Schema: doc.schema(reflect.TypeOf(errorResponse))},
+				},
+			}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]Operation)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	return doc
+}
+
+// =============================================================================
+
+// convertPath converts the httptreemux path parameters to the OpenAPI form
+// and returns them.
+func convertPath(path string) (string, []Parameter) {
+	var params []Parameter
+
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if !strings.HasPrefix(part, ":") && !strings.HasPrefix(part, "*") {
+			continue
+		}
+
+		name := part[1:]
+		parts[i] = "{" + name + "}"
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	return strings.Join(parts, "/"), params
+}
+
+// Set of types that need a schema other than the one of their kind.
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	bigIntType     = reflect.TypeOf(big.Int{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema returns the schema of the Go type as it is encoded to JSON. Named
+// struct types are added to the components and referenced.
+func (doc *Document) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case bigIntType:
+		return &Schema{Type: "integer"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer", Format: intFormat(t)}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0
+		return &Schema{Type: "integer", Format: intFormat(t), Minimum: &zero}
+
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+
+	case reflect.String:
+		return &Schema{Type: "string"}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: doc.schema(t.Elem())}
+
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: doc.schema(t.Elem())}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return doc.structSchema(t)
+		}
+
+		name := schemaName(t)
+		if _, exists := doc.Components.Schemas[name]; !exists {
+
+			// Reserve the name first so recursive types terminate.
+			doc.Components.Schemas[name] = &Schema{}
+			doc.Components.Schemas[name] = doc.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	// Interfaces and anything else can hold any value.
+	return &Schema{}
+}
+
+// structSchema returns the schema of the struct type following the rules of
+// the json package for field names and embedded structs.
+func (doc *Document) structSchema(t reflect.Type) *Schema {
+	s := Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// Fields of embedded structs are promoted.
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded := doc.structSchema(ft)
+				for n, p := range embedded.Properties {
+					s.Properties[n] = p
+				}
+				s.Required = append(s.Required, embedded.Required...)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = doc.schema(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return &s
+}
+
+// schemaName returns the name of the component for the named type, which
+// is qualified by its package to avoid collisions.
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+
+	name := t.Name()
+
+	// Instances of generic types carry their type arguments in the name.
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
+
+// intFormat returns the format of the integer type.
+func intFormat(t reflect.Type) string {
+	if t.Bits() > 32 {
+		return "int64"
+	}
+	return "int32"
+}
@@ -0,0 +1,154 @@
+// Package prometheus writes metrics in the Prometheus text exposition format.
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Set of metric types.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// Labels represents the labels of a sample.
+type Labels map[string]string
+
+// =============================================================================
+
+// Histogram counts observations into cumulative buckets. It is not safe for
+// concurrent use.
+type Histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewHistogram constructs a histogram with the specified upper bounds, which
+// must be sorted in increasing order.
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// Observe adds a single observation to the histogram.
+func (h *Histogram) Observe(v float64) {
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// =============================================================================
+
+// Writer writes metrics to the underlying writer. The first error is kept
+// and every write after it is skipped.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+// NewWriter constructs a writer for the specified writer.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w: w,
+	}
+}
+
+// Err returns the first error that happened while writing.
+func (pw *Writer) Err() error {
+	return pw.err
+}
+
+// Header writes the help and type lines that must precede the samples of
+// a metric.
+func (pw *Writer) Header(name string, help string, typ string) {
+	pw.printf("# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+// Sample writes a single sample of a metric.
+func (pw *Writer) Sample(name string, labels Labels, value float64) {
+	pw.printf("%s%s %s\n", name, formatLabels(labels, "", ""), formatValue(value))
+}
+
+// Histogram writes the buckets, sum and count of the histogram.
+func (pw *Writer) Histogram(name string, labels Labels, h *Histogram) {
+	for i, bound := range h.buckets {
+		pw.printf("%s_bucket%s %d\n", name, formatLabels(labels, "le", formatValue(bound)), h.counts[i])
+	}
+	pw.printf("%s_bucket%s %d\n", name, formatLabels(labels, "le", "+Inf"), h.count)
+	pw.printf("%s_sum%s %s\n", name, formatLabels(labels, "", ""), formatValue(h.sum))
+	pw.printf("%s_count%s %d\n", name, formatLabels(labels, "", ""), h.count)
+}
+
+// printf writes to the underlying writer unless a write already failed.
+func (pw *Writer) printf(format string, args ...any) {
+	if pw.err != nil {
+		return
+	}
+	_, pw.err = fmt.Fprintf(pw.w, format, args...)
+}
+
+// =============================================================================
+
+// formatLabels returns the labels sorted by name in the exposition format.
+// The extra label is added when its name is not empty.
+func formatLabels(labels Labels, extraName string, extraValue string) string {
+	if len(labels) == 0 && extraName == "" {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escapeLabel(labels[name])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extraName, escapeLabel(extraValue)))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatValue returns the value in the exposition format.
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeHelp escapes the characters that can't appear in a help line.
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// escapeLabel escapes the characters that can't appear in a label value.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
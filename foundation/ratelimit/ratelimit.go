@@ -0,0 +1,146 @@
+// Package ratelimit provides token bucket rate limiting keyed by an arbitrary
+// string such as a client IP or an account.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that refilled completely are removed
+// so idle keys don't hold memory.
+const sweepInterval = time.Minute
+
+// Limit represents the number of tokens added to a bucket every second and
+// the number of tokens a bucket can hold. A zero rate disables the limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// bucket tracks the tokens left for a single key.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter maintains a token bucket for every key.
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New constructs a limiter that applies the specified limit to every key.
+func New(limit Limit) *Limiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	return &Limiter{
+		limit:     limit,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Enabled reports whether the limiter limits anything.
+func (l *Limiter) Enabled() bool {
+	return l != nil && l.limit.Rate > 0
+}
+
+// Allow takes a token from the bucket of the key. When the bucket is empty
+// the time to wait before a token is available is returned.
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	return l.take(map[string]int{key: 1}, now)
+}
+
+// AllowN takes n tokens from the bucket of the key, or none when the bucket
+// holds fewer so a refused request costs nothing. The time to wait before
+// the bucket holds n tokens is returned. More tokens than the burst are
+// never allowed and no time to wait is returned for them.
+func (l *Limiter) AllowN(key string, n int, now time.Time) (bool, time.Duration) {
+	return l.take(map[string]int{key: n}, now)
+}
+
+// AllowAll takes a token from the bucket of every key, or none of them when
+// any bucket is short so a refused request costs nothing. A key listed more
+// than once takes a token for every time it is listed. The time to wait
+// before every bucket has the tokens is returned.
+func (l *Limiter) AllowAll(keys []string, now time.Time) (bool, time.Duration) {
+	counts := make(map[string]int, len(keys))
+	for _, key := range keys {
+		counts[key]++
+	}
+
+	return l.take(counts, now)
+}
+
+// =============================================================================
+
+// take takes the specified number of tokens from the bucket of every key,
+// or none of them when any bucket is short.
+func (l *Limiter) take(counts map[string]int, now time.Time) (bool, time.Duration) {
+	if !l.Enabled() {
+		return true, 0
+	}
+
+	for _, n := range counts {
+		if n > l.limit.Burst {
+			return false, 0
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	// Refill the buckets for the time elapsed since they were last used
+	// and find the longest wait for the tokens.
+	var wait time.Duration
+	for key, n := range counts {
+		b, exists := l.buckets[key]
+		if !exists {
+			b = &bucket{tokens: float64(l.limit.Burst), last: now}
+			l.buckets[key] = b
+		}
+
+		b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+		b.last = now
+
+		if need := float64(n) - b.tokens; need > 0 {
+			if w := time.Duration(need / l.limit.Rate * float64(time.Second)); w > wait {
+				wait = w
+			}
+		}
+	}
+
+	if wait > 0 {
+		return false, wait
+	}
+
+	for key, n := range counts {
+		l.buckets[key].tokens -= float64(n)
+	}
+
+	return true, 0
+}
+
+// sweep removes the buckets that would be full by now.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	full := float64(l.limit.Burst) / l.limit.Rate
+	for key, b := range l.buckets {
+		if now.Sub(b.last).Seconds() >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
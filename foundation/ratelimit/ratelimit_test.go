@@ -0,0 +1,172 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/ratelimit"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Allow(t *testing.T) {
+	now := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	type request struct {
+		key     string
+		elapsed time.Duration
+		allowed bool
+		wait    time.Duration
+	}
+
+	tt := []struct {
+		name     string
+		limit    ratelimit.Limit
+		requests []request
+	}{
+		{
+			"disabled limit",
+			ratelimit.Limit{Rate: 0, Burst: 1},
+			[]request{{"a", 0, true, 0}, {"a", 0, true, 0}, {"a", 0, true, 0}},
+		},
+		{
+			"burst of requests",
+			ratelimit.Limit{Rate: 1, Burst: 2},
+			[]request{{"a", 0, true, 0}, {"a", 0, true, 0}, {"a", 0, false, time.Second}},
+		},
+		{
+			"request after a refill",
+			ratelimit.Limit{Rate: 2, Burst: 1},
+			[]request{{"a", 0, true, 0}, {"a", 250 * time.Millisecond, false, 250 * time.Millisecond}, {"a", 500 * time.Millisecond, true, 0}},
+		},
+		{
+			"request from another key",
+			ratelimit.Limit{Rate: 1, Burst: 1},
+			[]request{{"a", 0, true, 0}, {"a", 0, false, time.Second}, {"b", 0, true, 0}},
+		},
+	}
+
+	t.Log("Given the need to limit the requests of a key.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					limiter := ratelimit.New(tst.limit)
+
+					for i, req := range tst.requests {
+						allowed, wait := limiter.Allow(req.key, now.Add(req.elapsed))
+						if allowed != req.allowed || wait != req.wait {
+							t.Fatalf("\t%s\tTest %d:\tShould limit request %d: got[%v %v] exp[%v %v]", failed, testID, i, allowed, wait, req.allowed, req.wait)
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould limit the requests.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_AllowAll(t *testing.T) {
+	now := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	limit := ratelimit.Limit{Rate: 1, Burst: 2}
+
+	tt := []struct {
+		name    string
+		spent   []string
+		keys    []string
+		allowed bool
+		left    map[string]bool
+	}{
+		{"request with keys that have tokens", nil, []string{"a", "b"}, true, map[string]bool{"a": true, "b": true}},
+		{"request with a key listed twice", nil, []string{"a", "a"}, true, map[string]bool{"a": false}},
+		{"request with a key listed past the burst", nil, []string{"a", "a", "a"}, false, map[string]bool{"a": true}},
+		{"request with one key out of tokens", []string{"b", "b"}, []string{"a", "b"}, false, map[string]bool{"a": true, "b": false}},
+	}
+
+	t.Log("Given the need to limit the requests of several keys at once.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					limiter := ratelimit.New(limit)
+					for _, key := range tst.spent {
+						limiter.Allow(key, now)
+					}
+
+					if allowed, _ := limiter.AllowAll(tst.keys, now); allowed != tst.allowed {
+						t.Fatalf("\t%s\tTest %d:\tShould limit the request: got[%v] exp[%v]", failed, testID, allowed, tst.allowed)
+					}
+					t.Logf("\t%s\tTest %d:\tShould limit the request.", success, testID)
+
+					// With a burst of 2 every key with a token left after
+					// the request can take one more.
+					for key, left := range tst.left {
+						if allowed, _ := limiter.Allow(key, now); allowed != left {
+							t.Fatalf("\t%s\tTest %d:\tShould leave a token for %s: got[%v] exp[%v]", failed, testID, key, allowed, left)
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould only take tokens when the request is allowed.", success, testID)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
+
+func Test_AllowN(t *testing.T) {
+	now := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	limit := ratelimit.Limit{Rate: 1, Burst: 3}
+
+	tt := []struct {
+		name    string
+		spent   int
+		n       int
+		allowed bool
+		wait    time.Duration
+		left    int
+	}{
+		{"request within the tokens left", 0, 2, true, 0, 1},
+		{"request past the tokens left", 2, 2, false, time.Second, 1},
+		{"request past the burst", 0, 4, false, 0, 3},
+	}
+
+	t.Log("Given the need to limit a request that costs several tokens.")
+	{
+		for testID, tst := range tt {
+			f := func(t *testing.T) {
+				t.Logf("\tTest %d:\tWhen handling a %s.", testID, tst.name)
+				{
+					limiter := ratelimit.New(limit)
+					for i := 0; i < tst.spent; i++ {
+						limiter.Allow("a", now)
+					}
+
+					allowed, wait := limiter.AllowN("a", tst.n, now)
+					if allowed != tst.allowed || wait != tst.wait {
+						t.Fatalf("\t%s\tTest %d:\tShould limit the request: got[%v %v] exp[%v %v]", failed, testID, allowed, wait, tst.allowed, tst.wait)
+					}
+					t.Logf("\t%s\tTest %d:\tShould limit the request.", success, testID)
+
+					if ok, _ := limiter.AllowN("a", tst.left, now); !ok {
+						t.Fatalf("\t%s\tTest %d:\tShould leave %d tokens.", failed, testID, tst.left)
+					}
+					if ok, _ := limiter.Allow("a", now); ok {
+						t.Fatalf("\t%s\tTest %d:\tShould leave %d tokens.", failed, testID, tst.left)
+					}
+					t.Logf("\t%s\tTest %d:\tShould leave %d tokens.", success, testID, tst.left)
+				}
+			}
+
+			t.Run(tst.name, f)
+		}
+	}
+}
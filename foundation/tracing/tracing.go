@@ -0,0 +1,244 @@
+// Package tracing provides support for distributed tracing using the W3C
+// Trace Context format to propagate traces between services.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header is the HTTP header used to propagate a trace.
+const Header = "traceparent"
+
+// Set of zero ids which are not valid for a trace or a span.
+const (
+	ZeroTraceID = "00000000000000000000000000000000"
+	ZeroSpanID  = "0000000000000000"
+)
+
+// version is the only version of the traceparent header supported.
+const version = "00"
+
+// NewTraceID generates a random trace id.
+func NewTraceID() string {
+	return randomHex(16)
+}
+
+// NewSpanID generates a random span id.
+func NewSpanID() string {
+	return randomHex(8)
+}
+
+// ParseTraceParent returns the trace id and parent span id carried by the
+// traceparent header. It returns false if the header is not valid.
+func ParseTraceParent(header string) (traceID string, parentID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != version {
+		return "", "", false
+	}
+
+	traceID, parentID = parts[1], parts[2]
+
+	if !isHex(traceID, 32) || traceID == ZeroTraceID {
+		return "", "", false
+	}
+
+	if !isHex(parentID, 16) || parentID == ZeroSpanID {
+		return "", "", false
+	}
+
+	if !isHex(parts[3], 2) {
+		return "", "", false
+	}
+
+	return traceID, parentID, true
+}
+
+// FormatTraceParent returns the traceparent header for the specified span.
+// Spans are always sampled.
+func FormatTraceParent(traceID string, spanID string) string {
+	return version + "-" + traceID + "-" + spanID + "-01"
+}
+
+// =============================================================================
+
+// Span represents a single unit of work in a trace.
+type Span struct {
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_id,omitempty"`
+	Name       string         `json:"name"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// Exporter sends finished spans to a tracing backend.
+type Exporter interface {
+	Export(span Span)
+}
+
+// NopExporter drops every span.
+type NopExporter struct{}
+
+// Export implements the Exporter interface.
+func (NopExporter) Export(span Span) {}
+
+// JSONExporter writes every span as a JSON document on its own line. It is
+// meant for local use where spans are read from the output.
+type JSONExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONExporter constructs an exporter writing to the specified writer.
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{
+		enc: json.NewEncoder(w),
+	}
+}
+
+// Export implements the Exporter interface.
+func (exp *JSONExporter) Export(span Span) {
+	exp.mu.Lock()
+	defer exp.mu.Unlock()
+
+	exp.enc.Encode(struct {
+		Span Span `json:"span"`
+	}{
+		Span: span,
+	})
+}
+
+// =============================================================================
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// key is how the span of a context is stored/retrieved.
+const key ctxKey = 1
+
+// spanContext identifies the span the work of a context belongs to and
+// the exporter its child spans are sent to.
+type spanContext struct {
+	traceID  string
+	spanID   string
+	exporter Exporter
+}
+
+// NewContext returns a context whose spans are sent to the exporter. The
+// first span started with it begins a new trace.
+func NewContext(ctx context.Context, exporter Exporter) context.Context {
+	return context.WithValue(ctx, key, &spanContext{exporter: exporter})
+}
+
+// ContextWithSpan returns a context whose spans are children of the
+// specified span and are sent to the exporter.
+func ContextWithSpan(ctx context.Context, exporter Exporter, traceID string, spanID string) context.Context {
+	sc := spanContext{
+		traceID:  traceID,
+		spanID:   spanID,
+		exporter: exporter,
+	}
+	return context.WithValue(ctx, key, &sc)
+}
+
+// FromContext returns the trace and span ids of the span the work of the
+// context belongs to. It returns false when there is no span.
+func FromContext(ctx context.Context) (traceID string, spanID string, ok bool) {
+	sc, exists := ctx.Value(key).(*spanContext)
+	if !exists || sc.spanID == "" {
+		return "", "", false
+	}
+	return sc.traceID, sc.spanID, true
+}
+
+// ActiveSpan is a span that has started and is exported when it ends.
+type ActiveSpan struct {
+	span     Span
+	exporter Exporter
+}
+
+// Start starts a span as a child of the span of the context, or as the
+// first span of a new trace when there is none. The returned context
+// carries the new span so the work it starts belongs to it.
+func Start(ctx context.Context, name string) (context.Context, *ActiveSpan) {
+	parent, exists := ctx.Value(key).(*spanContext)
+	if !exists {
+		parent = &spanContext{}
+	}
+
+	span := Span{
+		TraceID:  parent.traceID,
+		SpanID:   NewSpanID(),
+		ParentID: parent.spanID,
+		Name:     name,
+		Start:    time.Now().UTC(),
+	}
+	if span.TraceID == "" {
+		span.TraceID = NewTraceID()
+	}
+
+	ctx = ContextWithSpan(ctx, parent.exporter, span.TraceID, span.SpanID)
+
+	return ctx, &ActiveSpan{span: span, exporter: parent.exporter}
+}
+
+// SetAttribute records an attribute of the span.
+func (as *ActiveSpan) SetAttribute(key string, value any) {
+	if as.span.Attributes == nil {
+		as.span.Attributes = make(map[string]any)
+	}
+	as.span.Attributes[key] = value
+}
+
+// RecordError records the error as an attribute of the span. A nil error
+// is ignored.
+func (as *ActiveSpan) RecordError(err error) {
+	if err != nil {
+		as.SetAttribute("error", err.Error())
+	}
+}
+
+// End ends the span and exports it. Spans started from a context without
+// an exporter are dropped.
+func (as *ActiveSpan) End() {
+	if as.exporter == nil {
+		return
+	}
+
+	as.span.End = time.Now().UTC()
+	as.exporter.Export(as.span)
+}
+
+// =============================================================================
+
+// randomHex returns n random bytes encoded as hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// isHex reports whether s is made of n lower case hex characters.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+
+	for _, c := range []byte(s) {
+		if !('0' <= c && c <= '9') && !('a' <= c && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"time"
+
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
 )
 
 // ctxKey represents the type of value for the context key.
@@ -14,9 +16,12 @@ const key ctxKey = 1
 
 // Values represent state for each request.
 type Values struct {
-	TraceID    string
-	Now        time.Time
-	StatusCode int
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Route        string
+	Now          time.Time
+	StatusCode   int
 }
 
 // GetValues returns the values from the context.
@@ -30,13 +35,43 @@ func GetValues(ctx context.Context) (*Values, error) {
 
 // GetTraceID returns the trace id from the context.
 func GetTraceID(ctx context.Context) string {
+	if traceID, _, ok := tracing.FromContext(ctx); ok {
+		return traceID
+	}
+
 	v, ok := ctx.Value(key).(*Values)
 	if !ok {
-		return "00000000-0000-0000-0000-000000000000"
+		return tracing.ZeroTraceID
 	}
 	return v.TraceID
 }
 
+// GetTraceParent returns the traceparent header that continues the trace of
+// the request, or of the span the work of the context belongs to. It
+// returns an empty string when there is neither.
+func GetTraceParent(ctx context.Context) string {
+	if traceID, spanID, ok := tracing.FromContext(ctx); ok {
+		return tracing.FormatTraceParent(traceID, spanID)
+	}
+
+	v, ok := ctx.Value(key).(*Values)
+	if !ok {
+		return ""
+	}
+	return tracing.FormatTraceParent(v.TraceID, v.SpanID)
+}
+
+// NewTraceContext returns a context carrying a new trace for work that isn't
+// started by a request, like the calls a node makes to its peers on its own.
+func NewTraceContext(ctx context.Context) context.Context {
+	v := Values{
+		TraceID: tracing.NewTraceID(),
+		SpanID:  tracing.NewSpanID(),
+		Now:     time.Now().UTC(),
+	}
+	return context.WithValue(ctx, key, &v)
+}
+
 // SetStatusCode sets the status code back into the context.
 func SetStatusCode(ctx context.Context, statusCode int) error {
 	v, ok := ctx.Value(key).(*Values)
@@ -9,6 +9,12 @@ import (
 // Respond converts a Go value to JSON and sends it to the client.
 func Respond(ctx context.Context, w http.ResponseWriter, data any, statusCode int) error {
 
+	// If the connection was switched to another protocol, like a websocket,
+	// there is no longer a response that can be written.
+	if v, err := GetValues(ctx); err == nil && v.StatusCode == http.StatusSwitchingProtocols {
+		return http.ErrHijacked
+	}
+
 	// Set the status code for the request logger middleware.
 	SetStatusCode(ctx, statusCode)
 
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Streamer writes a long lived response to the client a piece at a time.
+// The server's write timeout is replaced by a deadline for every write so a
+// stream can stay open for as long as the client keeps reading.
+type Streamer struct {
+	w         http.ResponseWriter
+	rc        *http.ResponseController
+	writeWait time.Duration
+}
+
+// NewStreamer sends the response headers with the specified content type and
+// returns a streamer for writing the body. Once a stream is started the
+// handler can no longer respond with an error.
+func NewStreamer(ctx context.Context, w http.ResponseWriter, contentType string, writeWait time.Duration) (*Streamer, error) {
+	rc := http.NewResponseController(w)
+
+	// Lift the deadline set by the server's WriteTimeout. Every write sets
+	// its own deadline from here on.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("streaming not supported: %w", err)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	SetStatusCode(ctx, http.StatusOK)
+	w.WriteHeader(http.StatusOK)
+
+	s := Streamer{
+		w:         w,
+		rc:        rc,
+		writeWait: writeWait,
+	}
+
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Write sends the data to the client immediately.
+func (s *Streamer) Write(data []byte) error {
+	if err := s.rc.SetWriteDeadline(time.Now().Add(s.writeWait)); err != nil {
+		return err
+	}
+
+	if _, err := s.w.Write(data); err != nil {
+		return err
+	}
+
+	return s.flush()
+}
+
+// flush pushes any buffered data to the client and clears the deadline so
+// an idle stream is not closed between writes.
+func (s *Streamer) flush() error {
+	if err := s.rc.Flush(); err != nil {
+		return err
+	}
+
+	return s.rc.SetWriteDeadline(time.Time{})
+}
@@ -8,8 +8,8 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/bruno-sartori/go-blockchain/foundation/tracing"
 	"github.com/dimfeld/httptreemux/v5"
-	"github.com/google/uuid"
 )
 
 // A Handler is a type that handles a http request within our own little mini
@@ -23,6 +23,34 @@ type App struct {
 	*httptreemux.ContextMux
 	shutdown chan os.Signal
 	mw       []Middleware
+	routes   []Route
+}
+
+// QueryParam describes a query parameter a route accepts.
+type QueryParam struct {
+	Name        string
+	Description string
+}
+
+// Doc describes a route for the API documentation. Request and Response
+// hold a value of the types the route decodes and responds with. The
+// response content type defaults to application/json and the status code
+// to 200.
+type Doc struct {
+	Summary     string
+	Description string
+	Query       []QueryParam
+	Request     any
+	Response    any
+	ContentType string
+	StatusCode  int
+}
+
+// Route describes a route registered with the application.
+type Route struct {
+	Method string
+	Path   string
+	Doc    Doc
 }
 
 // NewApp creates an App value that handle a set of routes for the application.
@@ -48,6 +76,21 @@ func (a *App) SignalShutdown() {
 	a.shutdown <- syscall.SIGTERM
 }
 
+// Routes returns the routes registered with the application in the order
+// they were registered.
+func (a *App) Routes() []Route {
+	routes := make([]Route, len(a.routes))
+	copy(routes, a.routes)
+	return routes
+}
+
+// HandleDoc sets a handler function for a given HTTP method and path pair
+// to the application server mux along with the documentation of the route.
+func (a *App) HandleDoc(method string, group string, path string, doc Doc, handler Handler, mw ...Middleware) {
+	a.Handle(method, group, path, handler, mw...)
+	a.routes[len(a.routes)-1].Doc = doc
+}
+
 // Handle sets a handler function for a given HTTP method and path pair
 // to the application server mux.
 func (a *App) Handle(method string, group string, path string, handler Handler, mw ...Middleware) {
@@ -58,6 +101,11 @@ func (a *App) Handle(method string, group string, path string, handler Handler,
 	// Add the application's general middleware to the handler chain.
 	handler = wrapMiddleware(a.mw, handler)
 
+	finalPath := path
+	if group != "" {
+		finalPath = "/" + group + path
+	}
+
 	// The function to execute for each request.
 	h := func(w http.ResponseWriter, r *http.Request) {
 
@@ -68,11 +116,24 @@ func (a *App) Handle(method string, group string, path string, handler Handler,
 		// Set the context with the required values to
 		// process the request.
 		v := Values{
-			TraceID: uuid.New().String(),
-			Now:     time.Now().UTC(),
+			SpanID: tracing.NewSpanID(),
+			Route:  finalPath,
+			Now:    time.Now().UTC(),
+		}
+
+		// Continue the trace of the caller when the request carries one,
+		// otherwise this request starts a new trace.
+		if traceID, parentID, ok := tracing.ParseTraceParent(r.Header.Get(tracing.Header)); ok {
+			v.TraceID = traceID
+			v.ParentSpanID = parentID
+		} else {
+			v.TraceID = tracing.NewTraceID()
 		}
 		ctx = context.WithValue(ctx, key, &v)
 
+		// Echo the trace so the caller can find this request in the logs.
+		w.Header().Set(tracing.Header, tracing.FormatTraceParent(v.TraceID, v.SpanID))
+
 		// Call the wrapped handler functions.
 		if err := handler(ctx, w, r); err != nil {
 			a.SignalShutdown()
@@ -80,9 +141,7 @@ func (a *App) Handle(method string, group string, path string, handler Handler,
 		}
 	}
 
-	finalPath := path
-	if group != "" {
-		finalPath = "/" + group + path
-	}
 	a.ContextMux.Handle(method, finalPath, h)
+
+	a.routes = append(a.routes, Route{Method: method, Path: finalPath})
 }